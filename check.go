@@ -0,0 +1,43 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+
+	"github.com/voidwyrm-2/reqproc/check"
+)
+
+// reqproc check files...
+func checkCommand(args []string) error {
+	fs := flag.NewFlagSet("check", flag.ContinueOnError)
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if fs.NArg() == 0 {
+		return errors.New("expected 'reqproc check <file>...'")
+	}
+
+	found := 0
+
+	for _, path := range fs.Args() {
+		diags, err := check.File(path)
+		if err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+
+		for _, d := range diags {
+			fmt.Printf("%s: %s\n", path, d.Error())
+		}
+
+		found += len(diags)
+	}
+
+	if found > 0 {
+		return fmt.Errorf("found %d problems", found)
+	}
+
+	return nil
+}
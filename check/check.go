@@ -0,0 +1,702 @@
+/*
+Package check finds stack-shape mistakes in ReqProc code before it's run
+
+it infers the stack effect of every word and quotation from the signatures of natives and functions, and reports underflows,
+functions which don't match their signature, labels reached with different amounts of values, and values of the wrong type
+*/
+package check
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+
+	"github.com/voidwyrm-2/reqproc/lexer"
+	"github.com/voidwyrm-2/reqproc/lexer/tokens"
+	"github.com/voidwyrm-2/reqproc/runtime/stdlib"
+	"github.com/voidwyrm-2/reqproc/runtime/types"
+	"github.com/voidwyrm-2/reqproc/runtime/types/functiontype"
+	"github.com/voidwyrm-2/reqproc/runtime/types/stringtype"
+	"github.com/voidwyrm-2/reqproc/runtime/types/tabletype"
+)
+
+// A problem found in the code
+type Diagnostic struct {
+	Line, Col int
+	Message   string
+}
+
+func (d Diagnostic) Error() string {
+	return fmt.Sprintf("error on line %d, col %d: %s", d.Line, d.Col, d.Message)
+}
+
+// Checks lexed code; globals are values it can use besides the standard library, e.g. the functions a host registers
+func Check(toks []tokens.Token, globals map[string]types.ReqType) []Diagnostic {
+	c := &checker{seen: map[Diagnostic]bool{}, declared: map[string]bool{}}
+	c.declare(toks)
+
+	names := map[string]value{}
+	for name, v := range globals {
+		names[name] = known(v)
+	}
+
+	c.block(toks, stack{}, names)
+
+	slices.SortFunc(c.diags, func(a, b Diagnostic) int {
+		if a.Line != b.Line {
+			return a.Line - b.Line
+		}
+
+		return a.Col - b.Col
+	})
+
+	return c.diags
+}
+
+// Lexes and checks code, only returning an error if it can't be lexed
+func Source(text string, globals map[string]types.ReqType) ([]Diagnostic, error) {
+	l := lexer.New(text)
+
+	toks, err := l.Lex()
+	if err != nil {
+		return nil, err
+	}
+
+	return Check(toks, globals), nil
+}
+
+func File(path string) ([]Diagnostic, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return Source(string(content), nil)
+}
+
+// what's known about a value; known is the value itself if it's known before running, e.g. a native or a module
+type value struct {
+	t     types.ReqVarType
+	known types.ReqType
+}
+
+func known(v types.ReqType) value {
+	return value{t: v.Type(), known: v}
+}
+
+func anyValue() value {
+	return value{t: types.TypeAny}
+}
+
+/*
+The shape of the stack at a point in the code
+
+an open stack has an unknown amount of values below the known ones, e.g. after calling a function whose effect isn't known,
+so popping from it never underflows
+*/
+type stack struct {
+	values []value
+	open   bool
+}
+
+func (st stack) clone() stack {
+	return stack{values: slices.Clone(st.values), open: st.open}
+}
+
+func (st *stack) push(values ...value) {
+	st.values = append(st.values, values...)
+}
+
+func (st *stack) pop() value {
+	if len(st.values) == 0 {
+		return anyValue()
+	}
+
+	v := st.values[len(st.values)-1]
+	st.values = st.values[:len(st.values)-1]
+
+	return v
+}
+
+func (st stack) equal(other stack) bool {
+	if st.open != other.open || len(st.values) != len(other.values) {
+		return false
+	}
+
+	for i, v := range st.values {
+		if v.t != other.values[i].t || (v.known == nil) != (other.values[i].known == nil) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// merges the shapes of two paths which meet, reporting whether they have the same amount of values
+func merge(a, b stack) (stack, bool) {
+	if a.open || b.open || len(a.values) != len(b.values) {
+		short, long := a.values, b.values
+		if len(short) > len(long) {
+			short, long = long, short
+		}
+
+		merged := stack{open: true}
+		for i, v := range short {
+			merged.push(value{t: v.t | long[len(long)-len(short)+i].t})
+		}
+
+		return merged, a.open || b.open
+	}
+
+	merged := stack{}
+	for i, v := range a.values {
+		if o := b.values[i]; v.known != nil && o.known != nil && v.t == o.t {
+			merged.push(v)
+		} else {
+			merged.push(value{t: v.t | o.t})
+		}
+	}
+
+	return merged, true
+}
+
+type checker struct {
+	// the shape of the stack if the call just checked fails, since a failed call takes its inputs but leaves nothing
+	failed *stack
+
+	// whether the call just checked stops the program, so nothing after it is reached
+	exited bool
+
+	diags    []Diagnostic
+	seen     map[Diagnostic]bool
+	declared map[string]bool
+
+	// how many function bodies deep the code being checked is
+	depth int
+}
+
+func (c *checker) report(t tokens.Token, format string, a ...any) {
+	d := Diagnostic{Line: t.Line(), Col: t.Col(), Message: fmt.Sprintf(format, a...)}
+
+	// paths through loops are walked more than once
+	if !c.seen[d] {
+		c.seen[d] = true
+		c.diags = append(c.diags, d)
+	}
+}
+
+// reports an error from parsing the code, at the position it has if it's a tokens.Error
+func (c *checker) reportErr(t tokens.Token, err error) {
+	var terr *tokens.Error
+	if errors.As(err, &terr) {
+		t = tokens.New(t.Kind(), t.Lit(), terr.Col, terr.Line)
+		err = terr.Err
+	}
+
+	c.report(t, "%s", err.Error())
+}
+
+// collects every name the code defines anywhere, so names used by functions before they're defined aren't reported
+func (c *checker) declare(toks []tokens.Token) {
+	for i, t := range toks {
+		if t.Iskind(tokens.Const) || t.Iskind(tokens.Assign) {
+			c.declared[t.Lit()] = true
+		} else if t.Iskind(tokens.Params) {
+			for _, name := range strings.Fields(t.Lit()) {
+				c.declared[name] = true
+			}
+		} else if (t.Is(tokens.Ident, "def") || t.Is(tokens.Ident, "as")) && i+1 < len(toks) {
+			c.declared[toks[i+1].Lit()] = true
+		} else if t.Is(tokens.Ident, "import") {
+			// modules are bound to their name, and members imported with only to theirs
+			if i > 0 && toks[i-1].Iskind(tokens.String) {
+				c.declared[strings.TrimSuffix(filepath.Base(toks[i-1].Lit()), ".req")] = true
+			}
+
+			if i+2 < len(toks) && toks[i+1].Is(tokens.Ident, "only") && toks[i+2].Iskind(tokens.BracketOpen) {
+				for j := i + 3; j < len(toks) && !toks[j].Iskind(tokens.BracketClose); j++ {
+					if toks[j].Iskind(tokens.String) {
+						c.declared[toks[j].Lit()] = true
+					}
+				}
+			}
+		}
+	}
+}
+
+// the index of the token closing the pair opened at toks[i], or -1
+func matching(toks []tokens.Token, i int, open, close tokens.TokenKind) int {
+	nest := 0
+
+	for j := i + 1; j < len(toks); j++ {
+		if toks[j].Iskind(open) {
+			nest++
+		} else if toks[j].Iskind(close) {
+			if nest == 0 {
+				return j
+			}
+
+			nest--
+		}
+	}
+
+	return -1
+}
+
+func describe(t types.ReqVarType) string {
+	if t == types.TypeAny {
+		return "any type"
+	}
+
+	return "a " + t.String()
+}
+
+type path struct {
+	at int
+	st stack
+}
+
+/*
+Checks a list of tokens run by one interpreter (a file or a function body), returning the shape of the stack at its end
+
+labels are where paths meet, since `err label` can jump to them
+*/
+func (c *checker) block(toks []tokens.Token, entry stack, names map[string]value) stack {
+	labels := map[string]int{}
+
+	for i := 0; i < len(toks); i++ {
+		if toks[i].Iskind(tokens.Label) {
+			labels[toks[i].Lit()] = i
+		} else if toks[i].Iskind(tokens.ParenOpen) {
+			if j := matching(toks, i, tokens.ParenOpen, tokens.ParenClose); j != -1 {
+				i = j
+			}
+		}
+	}
+
+	states := map[int]stack{}
+	work := []path{{at: 0, st: entry}}
+
+	var exit *stack
+
+	// merges a path into the state of a label, reporting whether it needs to be walked again
+	arrive := func(at int, st stack, via tokens.Token) (stack, bool) {
+		prev, ok := states[at]
+		if !ok {
+			states[at] = st
+			return st, true
+		}
+
+		merged, same := merge(prev, st)
+		if !same {
+			c.report(via, "label '%s' is reached with %d values on the stack here, but with %d elsewhere", toks[at].Lit(), len(st.values), len(prev.values))
+		}
+
+		if merged.equal(prev) {
+			return prev, false
+		}
+
+		states[at] = merged
+
+		return merged, true
+	}
+
+	for len(work) > 0 {
+		p := work[0]
+		work = work[1:]
+
+		st := p.st.clone()
+		i := p.at
+
+	walk:
+		for i < len(toks) {
+			cur := toks[i]
+
+			if cur.Iskind(tokens.Label) && i != p.at {
+				merged, again := arrive(i, st, cur)
+				if !again {
+					break walk
+				}
+
+				st = merged.clone()
+			}
+
+			if cur.Is(tokens.Ident, "err") {
+				if i+1 >= len(toks) || !toks[i+1].Iskind(tokens.Ident) {
+					c.report(cur, "expected a label name after 'err'")
+					i++
+					continue
+				}
+
+				// err only jumps if there's an error, which is usually from the call right before it
+				jump := st.clone()
+				if c.failed != nil {
+					jump = *c.failed
+				}
+
+				if l, ok := labels[toks[i+1].Lit()]; !ok {
+					c.report(toks[i+1], "label '%s' is not defined", toks[i+1].Lit())
+				} else if merged, again := arrive(l, jump, cur); again {
+					work = append(work, path{at: l, st: merged})
+				}
+
+				i += 2
+				continue
+			}
+
+			c.failed, c.exited = nil, false
+			if i = c.step(toks, i, &st, names); c.exited {
+				break walk
+			}
+		}
+
+		if i >= len(toks) && !c.exited {
+			if exit == nil {
+				exit = &st
+			} else {
+				merged, _ := merge(*exit, st)
+				exit = &merged
+			}
+		}
+	}
+
+	if exit == nil {
+		return stack{open: true}
+	}
+
+	return *exit
+}
+
+// checks that the top of the stack has the given kinds (top first) and pops them, reporting whether it could
+func (c *checker) expect(st *stack, kinds []types.ReqVarType, t tokens.Token, word string) bool {
+	for k, kind := range kinds {
+		idx := len(st.values) - 1 - k
+		if idx < 0 {
+			if st.open {
+				break
+			}
+
+			c.report(t, "stack underflow: '%s' takes %d values, but there are only %d on the stack", word, len(kinds), len(st.values))
+			*st = stack{open: true}
+
+			return false
+		}
+
+		if vt := st.values[idx].t; vt&kind == 0 {
+			c.report(t, "'%s' expects %s, but found %s", word, describe(kind), describe(vt))
+		}
+	}
+
+	for range kinds {
+		st.pop()
+	}
+
+	return true
+}
+
+// applies the effect of calling a function to the stack
+func (c *checker) call(fn value, st *stack, t tokens.Token, word string) {
+	if fn.t&types.TypeFunction == 0 {
+		c.report(t, "'%s' is not callable, it's %s", word, describe(fn.t))
+		return
+	}
+
+	rft, ok := fn.known.(functiontype.ReqFunctionType)
+	if !ok {
+		// nothing is known about what it does to the stack
+		*st = stack{open: true}
+		return
+	}
+
+	if rft.Variadic() {
+		c.variadic(rft, st, t, word)
+		return
+	}
+
+	if !c.expect(st, rft.Input(), t, word) {
+		return
+	}
+
+	failed := st.clone()
+	c.failed = &failed
+	c.exited = sameNative(rft, stdlib.Stdlib["__init__"]["exit"])
+
+	for i := len(rft.Output()) - 1; i > -1; i-- {
+		st.push(value{t: rft.Output()[i]})
+	}
+}
+
+func sameNative(rft functiontype.ReqFunctionType, other types.ReqType) bool {
+	a, b := reflect.ValueOf(rft.Literal()), reflect.ValueOf(other.Literal())
+	return a.Kind() == reflect.Func && b.Kind() == reflect.Func && a.Pointer() == b.Pointer()
+}
+
+// applies the effect of natives whose effect depends on the function they're given
+func (c *checker) variadic(rft functiontype.ReqFunctionType, st *stack, t tokens.Token, word string) {
+	top, under := anyValue(), anyValue()
+	if n := len(st.values); n > 1 {
+		top, under = st.values[n-1], st.values[n-2]
+	} else if n == 1 {
+		top = st.values[0]
+	}
+
+	fn, isKnown := top.known.(functiontype.ReqFunctionType)
+
+	if !c.expect(st, rft.Input(), t, word) {
+		return
+	}
+
+	if sameNative(rft, stdlib.Stdlib["__init__"]["clear"]) {
+		*st = stack{}
+		return
+	}
+
+	if isKnown {
+		if sameNative(rft, stdlib.Stdlib["__init__"]["dip"]) {
+			// the value under the function was popped along with it, and is put back after the function is called
+			c.call(known(fn), st, t, word)
+			st.push(under)
+
+			return
+		} else if sameNative(rft, stdlib.Stdlib["task"]["spawn"]) {
+			if c.expect(st, fn.Input(), t, word) {
+				st.push(value{t: types.TypeNative})
+			}
+
+			return
+		}
+	}
+
+	*st = stack{open: true}
+}
+
+// looks up a name, reporting it if it can't exist
+func (c *checker) lookup(t tokens.Token, names map[string]value) value {
+	path := strings.Split(t.Lit(), ".")
+
+	v, ok := names[path[0]]
+	if !ok {
+		if native, isNative := stdlib.Stdlib["__init__"][path[0]]; isNative {
+			v = known(native)
+		} else if c.depth > 0 && c.declared[path[0]] {
+			// defined somewhere the checker doesn't follow, e.g. by the caller of a function or after it's defined;
+			// top-level code runs in order, so a name it uses before defining is an error
+			return anyValue()
+		} else {
+			c.report(t, "'%s' is not defined", path[0])
+			return anyValue()
+		}
+	}
+
+	for n, member := range path[1:] {
+		if v.known == nil {
+			if v.t&types.TypeTable == 0 {
+				c.report(t, "'%s' is not dot indexable, it's %s", strings.Join(path[:n+1], "."), describe(v.t))
+			}
+
+			return anyValue()
+		} else if v.t != types.TypeTable {
+			c.report(t, "'%s' is not dot indexable, it's %s", strings.Join(path[:n+1], "."), describe(v.t))
+			return anyValue()
+		}
+
+		m, exists := v.known.Literal().(map[string]types.ReqType)[member]
+		if !exists {
+			c.report(t, "'%s' has no member '%s'", strings.Join(path[:n+1], "."), member)
+			return anyValue()
+		}
+
+		v = known(m)
+	}
+
+	return v
+}
+
+// binds what an import statement at toks[i] imports, returning the index after it
+func (c *checker) bindImport(toks []tokens.Token, i int, mod value, names map[string]value) int {
+	name := ""
+	if mod.known != nil && mod.t == types.TypeString {
+		name = mod.known.Literal().(string)
+	}
+
+	module := value{t: types.TypeTable}
+	if members, ok := stdlib.Stdlib[name]; ok && name != "__init__" {
+		module = known(tabletype.New(members))
+	}
+
+	if i+2 < len(toks) && toks[i+1].Is(tokens.Ident, "as") && toks[i+2].Iskind(tokens.Ident) {
+		names[toks[i+2].Lit()] = module
+		return i + 3
+	} else if i+2 < len(toks) && toks[i+1].Is(tokens.Ident, "only") && toks[i+2].Iskind(tokens.BracketOpen) {
+		end := matching(toks, i+2, tokens.BracketOpen, tokens.BracketClose)
+		if end == -1 {
+			c.report(toks[i+2], "no '%s' to match '%s'", tokens.BracketClose.PublicString(), tokens.BracketOpen.PublicString())
+			return len(toks)
+		}
+
+		for _, t := range toks[i+3 : end] {
+			if module.known == nil {
+				names[t.Lit()] = anyValue()
+			} else if m, ok := module.known.Literal().(map[string]types.ReqType)[t.Lit()]; ok {
+				names[t.Lit()] = known(m)
+			} else {
+				c.report(t, "module '%s' has no member '%s'", name, t.Lit())
+			}
+		}
+
+		return end + 1
+	}
+
+	if name != "" {
+		names[strings.TrimSuffix(filepath.Base(name), ".req")] = module
+	}
+
+	return i + 1
+}
+
+// checks a function literal at toks[i], returning the index after it
+func (c *checker) function(toks []tokens.Token, i int, st *stack, names map[string]value) int {
+	end := matching(toks, i, tokens.ParenOpen, tokens.ParenClose)
+	if end == -1 {
+		c.report(toks[i], "no '%s' to match '%s'", tokens.ParenClose.PublicString(), tokens.ParenOpen.PublicString())
+		return len(toks)
+	}
+
+	fn, err := functiontype.Parse(toks[i+1 : end])
+	if err != nil {
+		c.reportErr(toks[i], err)
+		st.push(value{t: types.TypeFunction})
+
+		return end + 1
+	}
+
+	sig, body := toks[i+1], fn.Literal().([]tokens.Token)
+
+	// the function's inputs are at the bottom of its stack
+	entry := stack{}
+	for k := len(fn.Input()) - 1; k > -1; k-- {
+		entry.push(value{t: fn.Input()[k]})
+	}
+
+	// named inputs are taken off the stack before the body runs
+	inner := maps.Clone(names)
+	for _, name := range fn.Params() {
+		inner[name] = entry.values[0]
+		entry.values = entry.values[1:]
+	}
+
+	c.depth++
+	exit := c.block(body, entry, inner)
+	c.depth--
+
+	if !exit.open && len(exit.values) != len(fn.Output()) {
+		c.report(sig, "function declared |%s but leaves %d values", sig.Lit(), len(exit.values))
+	} else if fn.Typed() {
+		for k, want := range fn.Output() {
+			idx := len(exit.values) - 1 - k
+			if idx < 0 {
+				break
+			}
+
+			if got := exit.values[idx].t; got&want == 0 {
+				c.report(sig, "function declared to return %s, but leaves %s", describe(want), describe(got))
+			}
+		}
+	}
+
+	st.push(known(fn))
+
+	return end + 1
+}
+
+// checks the token at toks[i], returning the index of the next one
+func (c *checker) step(toks []tokens.Token, i int, st *stack, names map[string]value) int {
+	cur := toks[i]
+
+	switch cur.Kind() {
+	case tokens.Label:
+	case tokens.String:
+		st.push(known(stringtype.New(cur.Lit())))
+	case tokens.Number:
+		st.push(value{t: types.TypeNumber})
+	case tokens.Ident:
+		switch cur.Lit() {
+		case "def", "export":
+			if i+1 >= len(toks) || !toks[i+1].Iskind(tokens.Ident) {
+				c.report(cur, "expected a name after '%s'", cur.Lit())
+				return i + 1
+			}
+
+			if cur.Lit() == "def" {
+				names[toks[i+1].Lit()] = anyValue()
+			}
+
+			return i + 2
+		case "geterr":
+			st.push(value{t: types.TypeString})
+		case "errcl", "try", "notry":
+		case "true", "false":
+			st.push(value{t: types.TypeNumber})
+		case "import":
+			mod := st.pop()
+			c.expect(&stack{values: []value{mod}, open: true}, []types.ReqVarType{types.TypeString}, cur, "import")
+
+			return c.bindImport(toks, i, mod, names)
+		default:
+			c.call(c.lookup(cur, names), st, cur, cur.Lit())
+		}
+	case tokens.GetValue:
+		st.push(c.lookup(cur, names))
+	case tokens.Assign, tokens.Const:
+		top := anyValue()
+		if len(st.values) > 0 {
+			top = st.values[len(st.values)-1]
+		}
+
+		if c.expect(st, []types.ReqVarType{types.TypeAny}, cur, cur.Lit()) {
+			names[cur.Lit()] = top
+		}
+	case tokens.GetIndex:
+		if c.expect(st, []types.ReqVarType{types.TypeAny, types.TypeAny}, cur, "@#") {
+			st.push(anyValue())
+		}
+	case tokens.AssignIndex:
+		indexable := anyValue()
+		if len(st.values) > 2 {
+			indexable = value{t: st.values[len(st.values)-3].t}
+		}
+
+		if c.expect(st, []types.ReqVarType{types.TypeAny, types.TypeAny, types.TypeAny}, cur, "!#") {
+			st.push(indexable)
+		}
+	case tokens.ParenOpen:
+		return c.function(toks, i, st, names)
+	case tokens.BracketOpen:
+		end := matching(toks, i, tokens.BracketOpen, tokens.BracketClose)
+		if end == -1 {
+			c.report(cur, "no '%s' to match '%s'", tokens.BracketClose.PublicString(), tokens.BracketOpen.PublicString())
+			return len(toks)
+		}
+
+		for _, t := range toks[i+1 : end] {
+			if t.Iskind(tokens.GetValue) {
+				c.lookup(t, names)
+			} else if !t.Iskind(tokens.String) && !t.Iskind(tokens.Number) {
+				c.report(t, "unexpected token '%s' in a list", t.Lit())
+			}
+		}
+
+		st.push(value{t: types.TypeList})
+
+		return end + 1
+	default:
+		c.report(cur, "unexpected token '%s'", cur.Lit())
+	}
+
+	return i + 1
+}
@@ -0,0 +1,33 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"os"
+
+	"github.com/voidwyrm-2/reqproc/debug"
+)
+
+// reqproc debug [-dap] file
+func debugCommand(args []string) error {
+	fs := flag.NewFlagSet("debug", flag.ContinueOnError)
+	dap := fs.Bool("dap", false, "Speak the Debug Adapter Protocol over stdio, so an editor can drive the debugger; the file can be given by its launch request instead")
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if *dap {
+		if fs.NArg() > 1 {
+			return errors.New("expected 'reqproc debug -dap [file]'")
+		}
+
+		return debug.DAP(fs.Arg(0), os.Stdin, os.Stdout)
+	}
+
+	if fs.NArg() != 1 {
+		return errors.New("expected 'reqproc debug [-dap] <file>'")
+	}
+
+	return debug.Console(fs.Arg(0), os.Stdin, os.Stdout)
+}
@@ -0,0 +1,338 @@
+package debug
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/voidwyrm-2/reqproc/lexer"
+	"github.com/voidwyrm-2/reqproc/lexer/tokens"
+	"github.com/voidwyrm-2/reqproc/runtime/interpreter"
+	"github.com/voidwyrm-2/reqproc/runtime/scope"
+	"github.com/voidwyrm-2/reqproc/runtime/types"
+)
+
+const consoleHelp = `commands, which can be shortened to what's in brackets:
+  (s)tep              go on to the next token
+  (n)ext              go on to the next token, stepping over function calls
+  (o)ut               go on until the current function returns
+  (c)ontinue          go on until a breakpoint is reached
+  (b)reak [line|:label|file:line]
+                      stop at a line or label, or list the breakpoints
+  (d)elete line|:label|file:line
+                      remove a breakpoint
+  (bt) frames         list the functions being executed
+  (f)rame n           inspect the nth frame of 'frames' instead of the innermost one
+  (st)ack             print the stack of the frame
+  (v)ars              print the variables and constants of the frame
+  (p)rint code        print what code leaves on an empty stack in the scope of the frame
+  (w)atch [code]      print code like 'print' whenever the code stops, or list the watch expressions
+  unwatch n           remove the nth watch expression
+  (l)ist              print the code around the current token
+  (q)uit              stop the code
+  (h)elp              print this
+an empty line repeats the last command`
+
+// reads a file to be debugged, returning an interpreter for it and its tokens
+func load(path string) (*interpreter.Interpreter, []tokens.Token, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	l := lexer.New(string(content))
+
+	toks, err := l.Lex()
+	if err != nil {
+		return nil, nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	interp, err := interpreter.New(scope.New(nil, map[string]types.ReqType{}))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if err = interp.SetFile(path); err != nil {
+		return nil, nil, err
+	}
+
+	return &interp, toks, nil
+}
+
+type console struct {
+	d    *Debugger
+	in   *bufio.Scanner
+	out  io.Writer
+	file string
+
+	// the frame being inspected, counted from the innermost one
+	frame int
+
+	// the last command, which an empty line repeats
+	last string
+
+	// the lines of the files shown so far
+	sources map[string][]string
+}
+
+/*
+Debugs a file from a terminal, reading commands from in and writing to out, which is also where the code prints to
+
+the code stops before its first token; the error it stopped with is returned, unless it was quit
+*/
+func Console(path string, in io.Reader, out io.Writer) error {
+	interp, toks, err := load(path)
+	if err != nil {
+		return err
+	}
+
+	interp.GetEnv().Stdout = out
+
+	c := &console{in: bufio.NewScanner(in), out: out, file: path, sources: map[string][]string{}}
+	c.d = New(c.stopped)
+
+	fmt.Fprintf(out, "debugging '%s', type 'help' for the commands\n", path)
+
+	err = c.d.Run(interp, toks, true)
+	if errors.Is(err, ErrQuit) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	fmt.Fprintln(out, "the code finished")
+
+	return nil
+}
+
+func (c *console) stopped(reason string) error {
+	c.frame = 0
+
+	frames := c.d.Frames()
+	f := frames[0]
+
+	fmt.Fprintf(c.out, "stopped at %s:%d:%d in %s (%s)\n", f.File, f.Token.Line(), column(f.Token), f.Name, reason)
+	c.show(f, f.Token.Line(), f.Token.Line())
+
+	for n, expr := range c.d.Watches() {
+		fmt.Fprintf(c.out, "watch %d: %s = %s\n", n+1, expr, c.evaluate(expr, f))
+	}
+
+	for {
+		fmt.Fprint(c.out, "(debug) ")
+
+		if !c.in.Scan() {
+			// there's nobody left to tell the code to go on
+			fmt.Fprintln(c.out)
+			c.d.Quit()
+
+			return nil
+		}
+
+		line := strings.TrimSpace(c.in.Text())
+		if line == "" {
+			line = c.last
+		}
+
+		c.last = line
+
+		if c.command(line) {
+			return nil
+		}
+	}
+}
+
+// runs a command, reporting whether the code should go on
+func (c *console) command(line string) bool {
+	name, arg, _ := strings.Cut(line, " ")
+	arg = strings.TrimSpace(arg)
+
+	frames := c.d.Frames()
+	f := frames[min(c.frame, len(frames)-1)]
+
+	switch name {
+	case "":
+	case "s", "step":
+		c.d.Step()
+		return true
+	case "n", "next":
+		c.d.Next()
+		return true
+	case "o", "out":
+		c.d.Out()
+		return true
+	case "c", "continue":
+		c.d.Continue()
+		return true
+	case "q", "quit":
+		c.d.Quit()
+		return true
+	case "b", "break":
+		if arg == "" {
+			c.breakpoints()
+		} else if err := c.breakpoint(arg, true); err != nil {
+			fmt.Fprintln(c.out, err.Error())
+		}
+	case "d", "delete":
+		if err := c.breakpoint(arg, false); err != nil {
+			fmt.Fprintln(c.out, err.Error())
+		}
+	case "bt", "frames":
+		for n, f := range frames {
+			marker := " "
+			if n == c.frame {
+				marker = "*"
+			}
+
+			if f.interp == nil {
+				fmt.Fprintf(c.out, "%s %d: %s (native)\n", marker, n, f.Name)
+			} else {
+				fmt.Fprintf(c.out, "%s %d: %s at %s:%d:%d\n", marker, n, f.Name, f.File, f.Token.Line(), column(f.Token))
+			}
+		}
+	case "f", "frame":
+		n, err := strconv.Atoi(arg)
+		if err != nil || n < 0 || n >= len(frames) {
+			fmt.Fprintf(c.out, "there is no frame '%s'\n", arg)
+		} else {
+			c.frame = n
+			fmt.Fprintf(c.out, "frame %d: %s\n", n, frames[n].Name)
+		}
+	case "st", "stack":
+		fmt.Fprintln(c.out, formatValues(c.d.Stack(f)))
+	case "v", "vars":
+		for _, v := range c.d.Scope(f) {
+			kind := "var"
+			if v.Const {
+				kind = "const"
+			}
+
+			value := "(no value)"
+			if v.Value != nil {
+				value = v.Value.String()
+			}
+
+			fmt.Fprintf(c.out, "%s %s = %s\n", kind, v.Name, value)
+		}
+	case "p", "print":
+		fmt.Fprintln(c.out, c.evaluate(arg, f))
+	case "w", "watch":
+		if arg != "" {
+			c.d.Watch(arg)
+		}
+
+		for n, expr := range c.d.Watches() {
+			fmt.Fprintf(c.out, "watch %d: %s\n", n+1, expr)
+		}
+	case "unwatch":
+		n, err := strconv.Atoi(arg)
+		if err == nil {
+			err = c.d.Unwatch(n)
+		}
+
+		if err != nil {
+			fmt.Fprintf(c.out, "there is no watch expression '%s'\n", arg)
+		}
+	case "l", "list":
+		c.show(f, f.Token.Line()-3, f.Token.Line()+3)
+	case "h", "help":
+		fmt.Fprintln(c.out, consoleHelp)
+	default:
+		fmt.Fprintf(c.out, "unknown command '%s', type 'help' for the commands\n", name)
+	}
+
+	return false
+}
+
+// adds or removes a breakpoint written as a line, a file and a line, or a label starting with ':'
+func (c *console) breakpoint(spec string, add bool) error {
+	if label, ok := strings.CutPrefix(spec, ":"); ok {
+		labels := slices.DeleteFunc(c.d.Labels(), func(l string) bool { return l == label })
+
+		if add {
+			labels = append(labels, label)
+		}
+
+		c.d.SetLabels(labels)
+
+		return nil
+	}
+
+	file, lineText := c.file, spec
+	if i := strings.LastIndexByte(spec, ':'); i != -1 {
+		file, lineText = spec[:i], spec[i+1:]
+	}
+
+	line, err := strconv.Atoi(lineText)
+	if err != nil || line < 1 {
+		return fmt.Errorf("invalid breakpoint '%s', expected a line, a file and a line, or a label", spec)
+	}
+
+	lines := slices.DeleteFunc(c.d.Lines(file), func(l int) bool { return l == line })
+
+	if add {
+		lines = append(lines, line)
+	}
+
+	c.d.SetLines(file, lines)
+
+	return nil
+}
+
+func (c *console) breakpoints() {
+	for _, point := range c.d.Breakpoints() {
+		fmt.Fprintln(c.out, point)
+	}
+}
+
+func (c *console) evaluate(expr string, f Frame) string {
+	values, err := c.d.Evaluate(expr, f)
+	if err != nil {
+		return err.Error()
+	}
+
+	return formatValues(values)
+}
+
+// prints the lines from start to end of the file of a frame, pointing at the one with the frame's token
+func (c *console) show(f Frame, start, end int) {
+	if f.interp == nil || f.File == "" {
+		return
+	}
+
+	lines, ok := c.sources[f.File]
+	if !ok {
+		content, err := os.ReadFile(f.File)
+		if err != nil {
+			return
+		}
+
+		lines = strings.Split(string(content), "\n")
+		c.sources[f.File] = lines
+	}
+
+	for n := max(start, 1); n <= min(end, len(lines)); n++ {
+		marker := " "
+		if n == f.Token.Line() {
+			marker = ">"
+		}
+
+		fmt.Fprintf(c.out, "%s %4d | %s\n", marker, n, lines[n-1])
+	}
+}
+
+// writes values bottom to top
+func formatValues(values []types.ReqType) string {
+	formatted := make([]string, 0, len(values))
+
+	for _, v := range values {
+		formatted = append(formatted, v.String())
+	}
+
+	return "[" + strings.Join(formatted, " ") + "]"
+}
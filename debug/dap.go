@@ -0,0 +1,499 @@
+package debug
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/textproto"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/voidwyrm-2/reqproc/lexer/tokens"
+	"github.com/voidwyrm-2/reqproc/runtime/interpreter"
+)
+
+// a request sent by the client
+type dapRequest struct {
+	Seq       int             `json:"seq"`
+	Command   string          `json:"command"`
+	Arguments json.RawMessage `json:"arguments"`
+}
+
+type dapResponse struct {
+	Seq        int    `json:"seq"`
+	Type       string `json:"type"`
+	RequestSeq int    `json:"request_seq"`
+	Success    bool   `json:"success"`
+	Command    string `json:"command"`
+	Message    string `json:"message,omitempty"`
+	Body       any    `json:"body,omitempty"`
+}
+
+type dapEvent struct {
+	Seq   int    `json:"seq"`
+	Type  string `json:"type"`
+	Event string `json:"event"`
+	Body  any    `json:"body,omitempty"`
+}
+
+// the code is debugged as a single thread, since the frames of tasks aren't told apart
+const threadID = 1
+
+type adapter struct {
+	d   *Debugger
+	w   io.Writer
+	mu  sync.Mutex
+	seq int
+
+	// the file given to DAP, which launch can replace
+	program string
+
+	interp *interpreter.Interpreter
+	toks   []tokens.Token
+	entry  bool
+
+	// whether the code is waiting on resume to go on
+	stopped bool
+	resume  chan struct{}
+
+	// closed once the client is gone, so the code doesn't wait for it anymore
+	quitting chan struct{}
+
+	// closed once the code finishes, if it was started
+	done chan struct{}
+}
+
+/*
+Debugs a file for an editor, speaking the Debug Adapter Protocol by reading requests from r and writing responses and events to w
+
+the file can also be given as the "program" of the launch request, which replaces path; what the code prints is sent as output events
+*/
+func DAP(path string, r io.Reader, w io.Writer) error {
+	a := &adapter{w: w, program: path, resume: make(chan struct{}), quitting: make(chan struct{})}
+	a.d = New(a.stop)
+	br := bufio.NewReader(r)
+
+	for {
+		req, err := readRequest(br)
+		if errors.Is(err, io.EOF) {
+			a.quit()
+			return nil
+		} else if err != nil {
+			return err
+		}
+
+		body, err := a.handle(req)
+
+		resp := dapResponse{Type: "response", RequestSeq: req.Seq, Success: err == nil, Command: req.Command, Body: body}
+		if err != nil {
+			resp.Message = err.Error()
+		}
+
+		if err = a.send(&resp.Seq, resp); err != nil {
+			return err
+		}
+
+		switch req.Command {
+		case "initialize":
+			if err = a.event("initialized", nil); err != nil {
+				return err
+			}
+		case "disconnect", "terminate":
+			return nil
+		}
+	}
+}
+
+func (a *adapter) handle(req dapRequest) (any, error) {
+	switch req.Command {
+	case "initialize":
+		return map[string]any{
+			"supportsConfigurationDoneRequest": true,
+			"supportsFunctionBreakpoints":      true,
+			"supportsTerminateRequest":         true,
+		}, nil
+	case "launch":
+		var args struct {
+			Program     string `json:"program"`
+			StopOnEntry bool   `json:"stopOnEntry"`
+		}
+
+		if err := unmarshalArguments(req, &args); err != nil {
+			return nil, err
+		}
+
+		if args.Program != "" {
+			a.program = args.Program
+		} else if a.program == "" {
+			return nil, errors.New("expected the file to debug as 'program'")
+		}
+
+		interp, toks, err := load(a.program)
+		if err != nil {
+			return nil, err
+		}
+
+		interp.GetEnv().Stdout = outputWriter{a, "stdout"}
+		interp.GetEnv().Stderr = outputWriter{a, "stderr"}
+		a.interp, a.toks, a.entry = interp, toks, args.StopOnEntry
+
+		return nil, nil
+	case "setBreakpoints":
+		var args struct {
+			Source struct {
+				Path string `json:"path"`
+			} `json:"source"`
+			Breakpoints []struct {
+				Line int `json:"line"`
+			} `json:"breakpoints"`
+		}
+
+		if err := unmarshalArguments(req, &args); err != nil {
+			return nil, err
+		}
+
+		lines := []int{}
+		verified := []map[string]any{}
+
+		for _, b := range args.Breakpoints {
+			lines = append(lines, b.Line)
+			verified = append(verified, map[string]any{"verified": true, "line": b.Line})
+		}
+
+		a.d.SetLines(args.Source.Path, lines)
+
+		return map[string]any{"breakpoints": verified}, nil
+	case "setFunctionBreakpoints":
+		var args struct {
+			Breakpoints []struct {
+				Name string `json:"name"`
+			} `json:"breakpoints"`
+		}
+
+		if err := unmarshalArguments(req, &args); err != nil {
+			return nil, err
+		}
+
+		// functions aren't where ReqProc code jumps to, labels are
+		labels := []string{}
+		verified := []map[string]any{}
+
+		for _, b := range args.Breakpoints {
+			labels = append(labels, strings.TrimPrefix(b.Name, ":"))
+			verified = append(verified, map[string]any{"verified": true})
+		}
+
+		a.d.SetLabels(labels)
+
+		return map[string]any{"breakpoints": verified}, nil
+	case "setExceptionBreakpoints":
+		return map[string]any{"breakpoints": []any{}}, nil
+	case "configurationDone":
+		if a.interp == nil {
+			return nil, errors.New("there is no code to run, since it wasn't launched")
+		}
+
+		a.start()
+
+		return nil, nil
+	case "threads":
+		return map[string]any{"threads": []map[string]any{{"id": threadID, "name": "main"}}}, nil
+	case "stackTrace":
+		frames := []map[string]any{}
+
+		for n, f := range a.d.Frames() {
+			frame := map[string]any{"id": n, "name": f.Name, "line": f.Token.Line(), "column": column(f.Token)}
+
+			if f.File != "" {
+				frame["source"] = map[string]any{"name": filepath.Base(f.File), "path": absolute(f.File)}
+			}
+
+			frames = append(frames, frame)
+		}
+
+		return map[string]any{"stackFrames": frames, "totalFrames": len(frames)}, nil
+	case "scopes":
+		var args struct {
+			FrameID int `json:"frameId"`
+		}
+
+		if err := unmarshalArguments(req, &args); err != nil {
+			return nil, err
+		}
+
+		// each frame has two references, odd ones for its stack and even ones for its scope
+		return map[string]any{"scopes": []map[string]any{
+			{"name": "Stack", "variablesReference": args.FrameID*2 + 1, "expensive": false},
+			{"name": "Variables", "variablesReference": args.FrameID*2 + 2, "expensive": false},
+		}}, nil
+	case "variables":
+		var args struct {
+			VariablesReference int `json:"variablesReference"`
+		}
+
+		if err := unmarshalArguments(req, &args); err != nil {
+			return nil, err
+		}
+
+		f, ok := a.frame((args.VariablesReference - 1) / 2)
+		if !ok || args.VariablesReference < 1 {
+			return nil, fmt.Errorf("there are no variables '%d'", args.VariablesReference)
+		}
+
+		vars := []map[string]any{}
+
+		if args.VariablesReference%2 == 1 {
+			// the top of the stack is shown first, as 0
+			st := a.d.Stack(f)
+
+			for n := len(st) - 1; n > -1; n-- {
+				vars = append(vars, variable(strconv.Itoa(len(st)-1-n), st[n].String(), st[n].Type().String()))
+			}
+		} else {
+			for _, v := range a.d.Scope(f) {
+				if v.Value == nil {
+					vars = append(vars, variable(v.Name, "(no value)", ""))
+				} else {
+					vars = append(vars, variable(v.Name, v.Value.String(), v.Value.Type().String()))
+				}
+			}
+		}
+
+		return map[string]any{"variables": vars}, nil
+	case "evaluate":
+		var args struct {
+			Expression string `json:"expression"`
+			FrameID    int    `json:"frameId"`
+		}
+
+		if err := unmarshalArguments(req, &args); err != nil {
+			return nil, err
+		}
+
+		f, _ := a.frame(args.FrameID)
+
+		values, err := a.d.Evaluate(args.Expression, f)
+		if err != nil {
+			return nil, err
+		}
+
+		return map[string]any{"result": formatValues(values), "variablesReference": 0}, nil
+	case "continue":
+		a.d.Continue()
+		a.release()
+
+		return map[string]any{"allThreadsContinued": true}, nil
+	case "next":
+		a.d.Next()
+		a.release()
+
+		return nil, nil
+	case "stepIn":
+		a.d.Step()
+		a.release()
+
+		return nil, nil
+	case "stepOut":
+		a.d.Out()
+		a.release()
+
+		return nil, nil
+	case "pause":
+		a.d.Pause()
+
+		return nil, nil
+	case "disconnect", "terminate":
+		a.quit()
+
+		return nil, nil
+	}
+
+	return nil, fmt.Errorf("command '%s' is not supported", req.Command)
+}
+
+func unmarshalArguments(req dapRequest, v any) error {
+	if len(req.Arguments) == 0 {
+		return nil
+	}
+
+	if err := json.Unmarshal(req.Arguments, v); err != nil {
+		return fmt.Errorf("invalid arguments for '%s': %w", req.Command, err)
+	}
+
+	return nil
+}
+
+func variable(name, value, typ string) map[string]any {
+	return map[string]any{"name": name, "value": value, "type": typ, "variablesReference": 0}
+}
+
+// returns the nth frame from the innermost one, or the zero frame if there isn't one
+func (a *adapter) frame(n int) (Frame, bool) {
+	frames := a.d.Frames()
+	if n < 0 || n >= len(frames) {
+		return Frame{}, false
+	}
+
+	return frames[n], true
+}
+
+// runs the code in the background, telling the client once it finishes
+func (a *adapter) start() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.done != nil {
+		return
+	}
+
+	a.done = make(chan struct{})
+
+	go func() {
+		defer close(a.done)
+
+		err := a.d.Run(a.interp, a.toks, a.entry)
+		if errors.Is(err, ErrQuit) {
+			err = nil
+		} else if err != nil && !exitPattern.MatchString(err.Error()) {
+			a.event("output", map[string]any{"category": "stderr", "output": err.Error() + "\n"})
+		}
+
+		a.event("exited", map[string]any{"exitCode": exitCode(err)})
+		a.event("terminated", nil)
+	}()
+}
+
+// called by the debugger from the goroutine running the code, which waits until the client tells it to go on
+func (a *adapter) stop(reason string) error {
+	a.mu.Lock()
+	a.stopped = true
+	a.mu.Unlock()
+
+	if err := a.event("stopped", map[string]any{"reason": reason, "threadId": threadID, "allThreadsStopped": true}); err != nil {
+		return err
+	}
+
+	select {
+	case <-a.resume:
+	case <-a.quitting:
+	}
+
+	return nil
+}
+
+// lets the code go on if it's stopped
+func (a *adapter) release() {
+	a.mu.Lock()
+	stopped := a.stopped
+	a.stopped = false
+	a.mu.Unlock()
+
+	if stopped {
+		select {
+		case a.resume <- struct{}{}:
+		case <-a.quitting:
+		}
+	}
+}
+
+// stops the code and waits for it to finish
+func (a *adapter) quit() {
+	a.d.Quit()
+
+	a.mu.Lock()
+	done := a.done
+	select {
+	case <-a.quitting:
+	default:
+		close(a.quitting)
+	}
+	a.mu.Unlock()
+
+	if done != nil {
+		<-done
+	}
+}
+
+func (a *adapter) event(name string, body any) error {
+	e := dapEvent{Type: "event", Event: name, Body: body}
+
+	return a.send(&e.Seq, &e)
+}
+
+// numbers and writes a response or event, whose seq field is given so it can be set before it's written
+func (a *adapter) send(seq *int, msg any) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.seq++
+	*seq = a.seq
+
+	body, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+
+	_, err = fmt.Fprintf(a.w, "Content-Length: %d\r\n\r\n%s", len(body), body)
+
+	return err
+}
+
+// sends what the code prints to the client
+type outputWriter struct {
+	a        *adapter
+	category string
+}
+
+func (o outputWriter) Write(p []byte) (int, error) {
+	if err := o.a.event("output", map[string]any{"category": o.category, "output": string(p)}); err != nil {
+		return 0, err
+	}
+
+	return len(p), nil
+}
+
+// reads a request framed by a Content-Length header
+func readRequest(r *bufio.Reader) (dapRequest, error) {
+	header, err := textproto.NewReader(r).ReadMIMEHeader()
+	if err != nil {
+		return dapRequest{}, err
+	}
+
+	length, err := strconv.Atoi(header.Get("Content-Length"))
+	if err != nil {
+		return dapRequest{}, fmt.Errorf("invalid Content-Length '%s'", header.Get("Content-Length"))
+	}
+
+	body := make([]byte, length)
+	if _, err = io.ReadFull(r, body); err != nil {
+		return dapRequest{}, err
+	}
+
+	var req dapRequest
+	if err = json.Unmarshal(body, &req); err != nil {
+		return dapRequest{}, err
+	}
+
+	return req, nil
+}
+
+var exitPattern = regexp.MustCompile(`EXIT CODE (-?\d+)`)
+
+// the code the exit native was called with, 1 for other errors, or 0 if the code finished
+func exitCode(err error) int {
+	if err == nil {
+		return 0
+	}
+
+	if m := exitPattern.FindStringSubmatch(err.Error()); m != nil {
+		code, _ := strconv.Atoi(m[1])
+		return code
+	}
+
+	return 1
+}
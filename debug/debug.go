@@ -0,0 +1,469 @@
+/*
+Package debug runs ReqProc code under a debugger, which stops it at breakpoints or after a step and lets the stacks, scopes
+and watch expressions of the functions being executed be inspected while it's stopped
+
+the debugger is driven from a terminal with Console, or by an editor through the Debug Adapter Protocol with DAP
+*/
+package debug
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"slices"
+	"sync"
+
+	"github.com/voidwyrm-2/reqproc/lexer/tokens"
+	"github.com/voidwyrm-2/reqproc/runtime/interpreter"
+	"github.com/voidwyrm-2/reqproc/runtime/scope"
+	"github.com/voidwyrm-2/reqproc/runtime/types"
+)
+
+// Stops the code being debugged; like a cancelled context, try doesn't catch it
+var ErrQuit = fmt.Errorf("stopped by the debugger: %w", context.Canceled)
+
+// how the code goes on after it stopped
+type mode int
+
+const (
+	modeContinue mode = iota
+	// stop at the next token
+	modeStep
+	// stop at the next token which isn't in a function called from the one stopped in
+	modeNext
+	// stop at the next token after the function stopped in returns
+	modeOut
+	modeQuit
+)
+
+// A function being executed, or the code the debugger was started with
+type Frame struct {
+	// the name the function was called by, or the name of the file being debugged
+	Name string
+
+	// the token about to be executed, which is the zero token for natives
+	Token tokens.Token
+
+	// the file the function's code is in, or an empty string if it's unknown
+	File string
+
+	// how many function calls deep the frame is, 0 being the code the debugger was started with
+	Depth int
+
+	interp *interpreter.Interpreter
+
+	// the line of the last token executed, so a line breakpoint only stops once whenever it's reached
+	line int
+}
+
+// A variable or constant in the scope of a frame
+type Variable struct {
+	Name string
+
+	// nil if a variable hasn't been given a value yet
+	Value types.ReqType
+
+	Const bool
+}
+
+/*
+Debuggers are interpreter.Hooks which stop the code they're set on, see New
+
+they're safe to use from multiple goroutines, but tasks share the frames of the code that spawned them
+*/
+type Debugger struct {
+	mu      sync.Mutex
+	lines   map[string][]int
+	labels  []string
+	watches []string
+	mode    mode
+
+	// why the code stops before its next token whatever it is, if it does
+	pending string
+
+	// the depth the code last stopped at, which Next and Out are relative to
+	from int
+
+	// held while stop is called, so tasks stopping at the same time wait for each other
+	stopping sync.Mutex
+
+	frames     []Frame
+	evaluating bool
+	stop       func(reason string) error
+}
+
+/*
+Creates a debugger which calls stop whenever the code stops, from the goroutine executing it, with the reason it stopped:
+"entry", "breakpoint", "step" or "pause"
+
+once stop returns, the code goes on as set by Continue, Step, Next or Out, which continues if none were called,
+and if stop returns an error the code stops with it
+*/
+func New(stop func(reason string) error) *Debugger {
+	return &Debugger{lines: map[string][]int{}, stop: stop}
+}
+
+// Executes tokens with the debugger, stopping before the first one if stopOnEntry
+func (d *Debugger) Run(interp *interpreter.Interpreter, toks []tokens.Token, stopOnEntry bool) error {
+	file := interp.GetScope().File()
+	name := "main"
+	if file != "" {
+		name = filepath.Base(file)
+	}
+
+	d.mu.Lock()
+	d.frames = []Frame{{Name: name, File: file, interp: interp}}
+	if stopOnEntry {
+		d.pending = "entry"
+	}
+	d.mu.Unlock()
+
+	interp.SetHook(d)
+	defer interp.SetHook(nil)
+
+	_, err := interp.ExecuteTokens(toks)
+
+	return err
+}
+
+func (d *Debugger) Before(s interpreter.Step) error {
+	d.mu.Lock()
+
+	if d.evaluating {
+		d.mu.Unlock()
+		return nil
+	} else if d.mode == modeQuit {
+		d.mu.Unlock()
+		return ErrQuit
+	}
+
+	d.resize(s.Depth + 1)
+
+	f := &d.frames[s.Depth]
+	newLine := s.Token.Line() != f.line
+	f.Token, f.File, f.interp, f.line = s.Token, s.Interpreter.GetScope().File(), s.Interpreter, s.Token.Line()
+
+	reason := ""
+
+	switch {
+	case d.pending != "":
+		reason = d.pending
+	case s.Token.Iskind(tokens.Label) && slices.Contains(d.labels, s.Token.Lit()):
+		reason = "breakpoint"
+	case newLine && slices.Contains(d.lines[absolute(f.File)], s.Token.Line()):
+		reason = "breakpoint"
+	case d.mode == modeStep, d.mode == modeNext && s.Depth <= d.from, d.mode == modeOut && s.Depth < d.from:
+		reason = "step"
+	}
+
+	if reason == "" {
+		d.mu.Unlock()
+		return nil
+	}
+
+	d.mode, d.pending, d.from = modeContinue, "", s.Depth
+	stop := d.stop
+	d.mu.Unlock()
+
+	d.stopping.Lock()
+	err := stop(reason)
+	d.stopping.Unlock()
+
+	if err != nil {
+		return err
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.mode == modeQuit {
+		return ErrQuit
+	}
+
+	return nil
+}
+
+func (d *Debugger) After(s interpreter.Step, err error) {}
+
+func (d *Debugger) Enter(c interpreter.Call) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.evaluating {
+		return
+	}
+
+	d.resize(c.Depth)
+
+	// natives aren't named, so they're called what the token calling them was
+	name := c.Function.Name()
+	if caller := d.frames[c.Depth-1].Token; name == "" && caller.Iskind(tokens.Ident) {
+		name = caller.Lit()
+	} else if name == "" {
+		name = "<function>"
+	}
+
+	d.frames = append(d.frames, Frame{Name: name, Depth: c.Depth})
+}
+
+func (d *Debugger) Exit(c interpreter.Call, err error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if !d.evaluating {
+		d.resize(c.Depth)
+	}
+}
+
+// makes the frames exactly n long, in case the hook missed a call
+func (d *Debugger) resize(n int) {
+	if len(d.frames) > n {
+		d.frames = d.frames[:n]
+	}
+
+	for len(d.frames) < n {
+		d.frames = append(d.frames, Frame{Name: "<function>", Depth: len(d.frames)})
+	}
+}
+
+// Goes on until a breakpoint is reached
+func (d *Debugger) Continue() {
+	d.resume(modeContinue)
+}
+
+// Goes on to the next token, which may be in a function called by the current one
+func (d *Debugger) Step() {
+	d.resume(modeStep)
+}
+
+// Goes on to the next token which isn't in a function called by the current one
+func (d *Debugger) Next() {
+	d.resume(modeNext)
+}
+
+// Goes on to the next token after the current function returns
+func (d *Debugger) Out() {
+	d.resume(modeOut)
+}
+
+// Stops the code with ErrQuit before its next token
+func (d *Debugger) Quit() {
+	d.resume(modeQuit)
+}
+
+func (d *Debugger) resume(m mode) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.mode = m
+}
+
+// Stops the code before its next token, while it's running
+func (d *Debugger) Pause() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.pending = "pause"
+}
+
+// Replaces the lines of a file the code stops at
+func (d *Debugger) SetLines(file string, lines []int) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.lines[absolute(file)] = slices.Clone(lines)
+}
+
+// Returns the lines of a file the code stops at
+func (d *Debugger) Lines(file string) []int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	return slices.Clone(d.lines[absolute(file)])
+}
+
+// Replaces the labels the code stops at, which are written without their ':'
+func (d *Debugger) SetLabels(labels []string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.labels = slices.Clone(labels)
+}
+
+func (d *Debugger) Labels() []string {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	return slices.Clone(d.labels)
+}
+
+// Returns every breakpoint, written as a file and a line or as a label starting with ':'
+func (d *Debugger) Breakpoints() []string {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	points := []string{}
+
+	files := make([]string, 0, len(d.lines))
+	for file := range d.lines {
+		files = append(files, file)
+	}
+
+	slices.Sort(files)
+
+	for _, file := range files {
+		lines := slices.Clone(d.lines[file])
+		slices.Sort(lines)
+
+		for _, line := range lines {
+			points = append(points, fmt.Sprintf("%s:%d", file, line))
+		}
+	}
+
+	for _, label := range d.labels {
+		points = append(points, ":"+label)
+	}
+
+	return points
+}
+
+// Adds an expression which is evaluated whenever the code stops, see Evaluate
+func (d *Debugger) Watch(expr string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.watches = append(d.watches, expr)
+}
+
+// Removes the nth watch expression, starting from 1
+func (d *Debugger) Unwatch(n int) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if n < 1 || n > len(d.watches) {
+		return fmt.Errorf("there is no watch expression %d", n)
+	}
+
+	d.watches = slices.Delete(d.watches, n-1, n)
+
+	return nil
+}
+
+func (d *Debugger) Watches() []string {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	return slices.Clone(d.watches)
+}
+
+// Returns the frames being executed, starting from the innermost one
+func (d *Debugger) Frames() []Frame {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	frames := slices.Clone(d.frames)
+	slices.Reverse(frames)
+
+	return frames
+}
+
+// Returns a copy of the stack of a frame, bottom to top, or nil for natives
+func (d *Debugger) Stack(f Frame) []types.ReqType {
+	if f.interp == nil {
+		return nil
+	}
+
+	st := f.interp.GetStack()
+
+	return slices.Clone(st.Slice())
+}
+
+// Returns the variables and constants a frame can use, sorted by name, except for builtins and imported modules
+func (d *Debugger) Scope(f Frame) []Variable {
+	if f.interp == nil {
+		return nil
+	}
+
+	seen := map[string]struct{}{}
+	vars := []Variable{}
+
+	add := func(name string, value types.ReqType, isConst bool) {
+		if _, ok := seen[name]; !ok {
+			seen[name] = struct{}{}
+			vars = append(vars, Variable{Name: name, Value: value, Const: isConst})
+		}
+	}
+
+	for sc := f.interp.GetScope(); sc != nil; sc = sc.Parent() {
+		for name, value := range sc.Consts() {
+			if !sc.Foreign(name) {
+				add(name, value, true)
+			}
+		}
+
+		for name, value := range sc.Vars() {
+			add(name, value, false)
+		}
+	}
+
+	slices.SortFunc(vars, func(a, b Variable) int {
+		if a.Name < b.Name {
+			return -1
+		} else if a.Name > b.Name {
+			return 1
+		}
+
+		return 0
+	})
+
+	return vars
+}
+
+/*
+Executes code in the scope of a frame on an empty stack, returning the stack it leaves
+
+the code can't reassign the frame's variables, and the debugger doesn't stop in it
+*/
+func (d *Debugger) Evaluate(expr string, f Frame) ([]types.ReqType, error) {
+	d.mu.Lock()
+	d.evaluating = true
+	d.mu.Unlock()
+
+	defer func() {
+		d.mu.Lock()
+		d.evaluating = false
+		d.mu.Unlock()
+	}()
+
+	var parent *scope.Scope
+	if f.interp != nil {
+		parent = f.interp.GetScope()
+	}
+
+	interp, err := interpreter.New(scope.NewIsolated(parent))
+	if err != nil {
+		return nil, err
+	}
+
+	return interp.Execute(expr)
+}
+
+// the column of a token, starting from 1 on every line rather than only the first one like the lexer's
+func column(t tokens.Token) int {
+	if t.Line() > 1 {
+		return t.Col() - 1
+	}
+
+	return t.Col()
+}
+
+// so breakpoints are found whichever way the path of a file was written
+func absolute(file string) string {
+	if abs, err := filepath.Abs(file); err == nil {
+		return abs
+	}
+
+	return file
+}
+
+var _ interpreter.Hook = (*Debugger)(nil)
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/voidwyrm-2/reqproc/format"
+)
+
+// reqproc fmt [-w] files...
+func fmtCommand(args []string) error {
+	fs := flag.NewFlagSet("fmt", flag.ContinueOnError)
+	write := fs.Bool("w", false, "Write the formatted code back to the files instead of printing it")
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if fs.NArg() == 0 {
+		return errors.New("expected 'reqproc fmt [-w] <file>...'")
+	}
+
+	for _, path := range fs.Args() {
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+
+		formatted, err := format.Source(string(content))
+		if err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+
+		if !*write {
+			fmt.Print(formatted)
+		} else if formatted != string(content) {
+			info, err := os.Stat(path)
+			if err != nil {
+				return err
+			}
+
+			if err = os.WriteFile(path, []byte(formatted), info.Mode().Perm()); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,232 @@
+/*
+Package format rewrites ReqProc code in one consistent style
+
+the code keeps its lines and comments, but the words of a line are separated by single spaces, runs of blank lines are
+shortened to one, code inside '(' and '[' is indented by a tab for every line which leaves one open, labels starting a line
+are aligned with the code around them while the code after them is indented once more, and so is the code between a line ending
+with `try` and a line starting with `notry`
+
+formatting code which is already formatted doesn't change it
+*/
+package format
+
+import (
+	"os"
+	"strings"
+	"unicode"
+
+	"github.com/voidwyrm-2/reqproc/lexer"
+	"github.com/voidwyrm-2/reqproc/lexer/tokens"
+)
+
+const indent = "\t"
+
+// Formats code, only returning an error if it can't be lexed
+func Source(text string) (string, error) {
+	l := lexer.New(text)
+
+	toks, err := l.LexComments()
+	if err != nil {
+		return "", err
+	}
+
+	f := formatter{text: text, toks: toks}
+
+	return f.format(), nil
+}
+
+func File(path string) (string, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+
+	return Source(string(content))
+}
+
+// a '(' or '[' which hasn't been closed yet
+type frame struct {
+	// the indentation of the line it was opened on, and of the code inside it
+	open, base int
+
+	// whether a label started a line inside it, and whether a line inside it ended with try, which indent the code after them
+	labelled, trying bool
+}
+
+type formatter struct {
+	text string
+	toks []tokens.Token
+}
+
+func (f formatter) format() string {
+	lines := f.lines()
+	out := []string{}
+	frames := []frame{{}}
+
+	for _, line := range lines {
+		if line == nil {
+			out = append(out, "")
+			continue
+		}
+
+		top := &frames[len(frames)-1]
+		level := top.base
+
+		if closers := f.leadingClosers(line); closers > 0 && len(frames) > 1 {
+			level = frames[len(frames)-min(closers, len(frames)-1)].open
+		} else if first := f.toks[line[0]]; first.Iskind(tokens.Label) {
+			top.labelled = true
+		} else {
+			if first.Is(tokens.Ident, "notry") {
+				top.trying = false
+			}
+
+			if top.labelled {
+				level++
+			}
+
+			if top.trying {
+				level++
+			}
+		}
+
+		if last := f.lastWord(line); last != -1 && f.toks[last].Is(tokens.Ident, "try") {
+			top.trying = true
+		}
+
+		for _, i := range line {
+			switch f.toks[i].Kind() {
+			case tokens.ParenOpen, tokens.BracketOpen:
+				frames = append(frames, frame{open: level, base: level + 1})
+			case tokens.ParenClose, tokens.BracketClose:
+				if len(frames) > 1 {
+					frames = frames[:len(frames)-1]
+				}
+			}
+		}
+
+		out = append(out, strings.Repeat(indent, level)+f.join(line))
+	}
+
+	if len(out) == 0 {
+		return ""
+	}
+
+	return strings.Join(out, "\n") + "\n"
+}
+
+// groups the indexes of the tokens by the line they start on, with nil for a blank line between two lines
+func (f formatter) lines() [][]int {
+	lines := [][]int{}
+	end := 0
+
+	for i, t := range f.toks {
+		if len(lines) == 0 || t.Line() > end {
+			if len(lines) > 0 && t.Line() > end+1 {
+				lines = append(lines, nil)
+			}
+
+			lines = append(lines, []int{})
+		}
+
+		lines[len(lines)-1] = append(lines[len(lines)-1], i)
+		end = t.Line() + strings.Count(f.spell(i), "\n")
+	}
+
+	return lines
+}
+
+// the index of the last token of a line which isn't a comment, or -1
+func (f formatter) lastWord(line []int) int {
+	for n := len(line) - 1; n > -1; n-- {
+		if !f.toks[line[n]].Iskind(tokens.Comment) {
+			return line[n]
+		}
+	}
+
+	return -1
+}
+
+func (f formatter) leadingClosers(line []int) int {
+	for n, i := range line {
+		if !f.toks[i].Iskind(tokens.ParenClose) && !f.toks[i].Iskind(tokens.BracketClose) {
+			return n
+		}
+	}
+
+	return len(line)
+}
+
+// writes the tokens of a line separated by spaces, except on the inside of parentheses and brackets
+func (f formatter) join(line []int) string {
+	var sb strings.Builder
+
+	for n, i := range line {
+		t := f.toks[i]
+
+		if n > 0 {
+			prev := f.toks[line[n-1]]
+			opened := prev.Iskind(tokens.ParenOpen) || prev.Iskind(tokens.BracketOpen)
+			closing := t.Iskind(tokens.ParenClose) || t.Iskind(tokens.BracketClose)
+
+			if t.Iskind(tokens.Comment) || !opened && !closing {
+				sb.WriteByte(' ')
+			}
+		}
+
+		sb.WriteString(f.spell(i))
+	}
+
+	return sb.String()
+}
+
+// writes a token the way it's written in code
+func (f formatter) spell(i int) string {
+	t := f.toks[i]
+
+	switch t.Kind() {
+	case tokens.String:
+		return f.quoted(t)
+	case tokens.Signature:
+		// the outputs of a typed signature would take a type name after them, unless they're ended with a lone '|'
+		if next := i + 1; strings.Contains(t.Lit(), "->") && next < len(f.toks) && f.toks[next].Iskind(tokens.Ident) && lexer.IsTypeName(f.toks[next].Lit()) {
+			return "|" + t.Lit() + " |"
+		}
+
+		return "|" + t.Lit()
+	case tokens.Params:
+		if t.Lit() == "" {
+			return "-> ;"
+		}
+
+		return "-> " + t.Lit() + " ;"
+	case tokens.Comment:
+		return ";" + strings.TrimRightFunc(t.Lit(), unicode.IsSpace)
+	case tokens.Label:
+		return ":" + t.Lit()
+	case tokens.Assign:
+		return "!" + t.Lit()
+	case tokens.Const:
+		return "$" + t.Lit()
+	case tokens.GetValue:
+		return "@" + t.Lit()
+	}
+
+	return t.Lit()
+}
+
+// returns a string as it was written, so escapes and the kind of quotes are kept
+func (f formatter) quoted(t tokens.Token) string {
+	start := lexer.Offset(f.text, t.Line(), t.Col())
+	quote := f.text[start]
+
+	for i := start + 1; i < len(f.text); i++ {
+		if f.text[i] == '\\' && quote == '"' {
+			i++
+		} else if f.text[i] == quote {
+			return f.text[start : i+1]
+		}
+	}
+
+	return f.text[start:]
+}
@@ -1,6 +1,7 @@
 package lexer
 
 import (
+	"strings"
 	"unicode"
 
 	"github.com/voidwyrm-2/reqproc/lexer/tokens"
@@ -32,6 +33,7 @@ type Lexer struct {
 	text         string
 	idx, col, ln int
 	ch           rune
+	comments     bool
 }
 
 func New(text string) Lexer {
@@ -40,6 +42,31 @@ func New(text string) Lexer {
 	return l
 }
 
+/*
+Returns the index in text of the character at a token's position
+
+columns start at 1 on the first line, but at 2 on the others, since the newline before a line is counted as its first column
+*/
+func Offset(text string, line, col int) int {
+	if line <= 1 {
+		return min(col-1, len(text))
+	}
+
+	// the newline before the line
+	newline := -1
+
+	for range line - 1 {
+		next := strings.IndexByte(text[newline+1:], '\n')
+		if next == -1 {
+			return len(text)
+		}
+
+		newline += 1 + next
+	}
+
+	return min(newline+col-1, len(text))
+}
+
 func (l *Lexer) advance() {
 	l.idx++
 	l.col++
@@ -150,8 +177,8 @@ func (l *Lexer) collectNumber(signature, negative bool) tokens.Token {
 		l.advance()
 	}
 
-	for l.ch != -1 && (l.isNumber() || l.ch == '.') {
-		if l.ch == '.' {
+	for l.ch != -1 && (l.isNumber() || l.ch == '.' || signature && l.ch == ':') {
+		if l.ch == '.' || l.ch == ':' {
 			if dot {
 				break
 			}
@@ -174,6 +201,139 @@ func (l *Lexer) collectNumber(signature, negative bool) tokens.Token {
 	return tokens.New(tokens.Number, lit, start, startln)
 }
 
+// reads the next word of a typed signature, which ends at whitespace or a parenthesis
+func (l *Lexer) collectWord() (string, int, int) {
+	for l.ch != -1 && unicode.IsSpace(l.ch) {
+		l.advance()
+	}
+
+	start, startln := l.col, l.ln
+	word := ""
+
+	for l.ch != -1 && l.isIdent() && l.ch != ';' {
+		word += string(l.ch)
+		l.advance()
+	}
+
+	return word, start, startln
+}
+
+// the names of types, which are only checked by functiontype.ParseTypedSignature; the lexer just needs them to find where outputs end
+var typeNames = map[string]struct{}{
+	"any":      {},
+	"string":   {},
+	"number":   {},
+	"list":     {},
+	"table":    {},
+	"function": {},
+	"native":   {},
+}
+
+// Reports whether a word is a type in a typed signature, which can be a union like `string|number`
+func IsTypeName(word string) bool {
+	if word == "" {
+		return false
+	}
+
+	for _, name := range strings.Split(word, "|") {
+		if _, ok := typeNames[name]; !ok {
+			return false
+		}
+	}
+
+	return true
+}
+
+/*
+Collects a typed signature like `|string number -> list`, the inputs being written bottom to top like the stack
+
+the outputs end at the first word which isn't a type, or at a lone '|' (e.g. `|list -> table | table`)
+*/
+func (l *Lexer) collectTypedSignature() (tokens.Token, error) {
+	start := l.col
+	startln := l.ln
+	words := []string{}
+
+	l.advance()
+
+	for {
+		word, _, _ := l.collectWord()
+		if word == "->" {
+			break
+		} else if word == "" {
+			return tokens.Token{}, l.errfp(start, startln, "expected '->' in signature")
+		}
+
+		words = append(words, word)
+	}
+
+	words = append(words, "->")
+
+	for {
+		save := *l
+
+		word, _, _ := l.collectWord()
+		if word == "|" {
+			break
+		} else if !IsTypeName(word) {
+			*l = save
+			break
+		}
+
+		words = append(words, word)
+	}
+
+	return tokens.New(tokens.Signature, strings.Join(words, " "), start, startln), nil
+}
+
+/*
+Collects the names a function gives its inputs, written after its signature like `|2:1 -> a b ;`
+
+the ';' ends the names instead of starting a comment
+*/
+func (l *Lexer) collectParams() (tokens.Token, bool, error) {
+	save := *l
+
+	word, start, startln := l.collectWord()
+	if word != "->" {
+		*l = save
+		return tokens.Token{}, false, nil
+	}
+
+	names := []string{}
+
+	for {
+		name, col, ln := l.collectWord()
+		if name == "" {
+			if l.ch != ';' {
+				return tokens.Token{}, false, l.errfp(start, startln, "expected ';' after the names of the function's inputs")
+			}
+
+			l.advance()
+
+			return tokens.New(tokens.Params, strings.Join(names, " "), start, startln), true, nil
+		} else if strings.ContainsAny(name, ".#:;|") {
+			return tokens.Token{}, false, l.errfp(col, ln, "invalid input name '%s'", name)
+		}
+
+		names = append(names, name)
+	}
+}
+
+// reports whether the digits at the current character are the start of a name like `2dup`, rather than a number
+func (l Lexer) startsName() bool {
+	for i := l.idx; i < len(l.text); i++ {
+		ch := rune(l.text[i])
+		if _, ok := singleIdents[ch]; ok || !isIdent(ch) || ch == ';' {
+			return false
+		} else if unicode.IsLetter(ch) {
+			return true
+		}
+	}
+
+	return false
+}
+
 func (l *Lexer) collectIdent(kind tokens.TokenKind, adv bool) tokens.Token {
 	start := l.col
 	startln := l.ln
@@ -183,12 +343,13 @@ func (l *Lexer) collectIdent(kind tokens.TokenKind, adv bool) tokens.Token {
 		l.advance()
 	}
 
-	if _, ok := singleIdents[l.ch]; ok {
+	// a '-' starting a name (e.g. `-rot`) is part of it, otherwise operators are names on their own
+	if _, ok := singleIdents[l.ch]; ok && !(l.ch == '-' && unicode.IsLetter(l.peek())) {
 		lit += string(l.ch)
 		l.advance()
 	} else {
 		for l.ch != -1 && l.isIdent() {
-			if _, ok := singleIdents[l.ch]; ok {
+			if _, ok := singleIdents[l.ch]; ok && lit != "" {
 				break
 			}
 			lit += string(l.ch)
@@ -199,15 +360,31 @@ func (l *Lexer) collectIdent(kind tokens.TokenKind, adv bool) tokens.Token {
 	return tokens.New(kind, lit, start, startln)
 }
 
+// Lexes the text like Lex, but keeps comments as Comment tokens, whose literal is the text after the ';'
+func (l *Lexer) LexComments() ([]tokens.Token, error) {
+	l.comments = true
+	return l.Lex()
+}
+
 func (l *Lexer) Lex() ([]tokens.Token, error) {
 	toks := []tokens.Token{}
 
 	for l.ch != -1 {
 		switch l.ch {
 		case ';':
+			start, startln := l.col, l.ln
+			lit := ""
+
+			l.advance()
+
 			for l.ch != -1 && l.ch != '\n' {
+				lit += string(l.ch)
 				l.advance()
 			}
+
+			if l.comments {
+				toks = append(toks, tokens.New(tokens.Comment, lit, start, startln))
+			}
 		case '!':
 			if l.peek() == '#' {
 				toks = append(toks, tokens.New(tokens.AssignIndex, string(l.ch)+"#", l.col, l.ln))
@@ -254,14 +431,27 @@ func (l *Lexer) Lex() ([]tokens.Token, error) {
 				toks = append(toks, t)
 			}
 		case '|':
-			{
-				toks = append(toks, l.collectNumber(true, l.peek() == '-'))
+			if next := l.peek(); isNumber(next) || next == '.' || next == ':' || next == '-' && l.idx+2 < len(l.text) && isNumber(rune(l.text[l.idx+2])) {
+				toks = append(toks, l.collectNumber(true, next == '-'))
+			} else {
+				t, err := l.collectTypedSignature()
+				if err != nil {
+					return []tokens.Token{}, err
+				}
+
+				toks = append(toks, t)
+			}
+
+			if t, ok, err := l.collectParams(); err != nil {
+				return []tokens.Token{}, err
+			} else if ok {
+				toks = append(toks, t)
 			}
 		default:
 			if kind, ok := charTokenMap[l.ch]; ok {
 				toks = append(toks, tokens.New(kind, string(l.ch), l.col, l.ln))
 				l.advance()
-			} else if l.isNumber() {
+			} else if l.isNumber() && !l.startsName() {
 				toks = append(toks, l.collectNumber(false, false))
 			} else if l.ch == '-' && isNumber(l.peek()) {
 				toks = append(toks, l.collectNumber(false, true))
@@ -1,6 +1,7 @@
 package lexer
 
 import (
+	"strings"
 	"testing"
 
 	"github.com/voidwyrm-2/reqproc/lexer/tokens"
@@ -34,6 +35,54 @@ func TestLexer(t *testing.T) {
 				{tokens.ParenClose, ")"},
 			},
 		},
+		{
+			"(|string number|list -> table | table)",
+			[]expectedToken{
+				{tokens.ParenOpen, "("},
+				{tokens.Signature, "string number|list -> table"},
+				{tokens.Ident, "table"},
+				{tokens.ParenClose, ")"},
+			},
+		},
+		{
+			"(|2:1 *)",
+			[]expectedToken{
+				{tokens.ParenOpen, "("},
+				{tokens.Signature, "2:1"},
+				{tokens.Ident, "*"},
+				{tokens.ParenClose, ")"},
+			},
+		},
+		{
+			"(|-> number 5)",
+			[]expectedToken{
+				{tokens.ParenOpen, "("},
+				{tokens.Signature, "-> number"},
+				{tokens.Number, "5"},
+				{tokens.ParenClose, ")"},
+			},
+		},
+		{
+			"(|2.1 -> a b ; @a @b + ) ; comment",
+			[]expectedToken{
+				{tokens.ParenOpen, "("},
+				{tokens.Signature, "2.1"},
+				{tokens.Params, "a b"},
+				{tokens.GetValue, "a"},
+				{tokens.GetValue, "b"},
+				{tokens.Ident, "+"},
+				{tokens.ParenClose, ")"},
+			},
+		},
+		{
+			"(|string number -> string -> s n;)",
+			[]expectedToken{
+				{tokens.ParenOpen, "("},
+				{tokens.Signature, "string number -> string"},
+				{tokens.Params, "s n"},
+				{tokens.ParenClose, ")"},
+			},
+		},
 		{
 			"-1 20 30 40 + -",
 			[]expectedToken{
@@ -68,6 +117,20 @@ func TestLexer(t *testing.T) {
 				{tokens.Ident, "each"},
 			},
 		},
+		{
+			"1 2 3 -rot 2dup 2drop - 4 -5",
+			[]expectedToken{
+				{tokens.Number, "1"},
+				{tokens.Number, "2"},
+				{tokens.Number, "3"},
+				{tokens.Ident, "-rot"},
+				{tokens.Ident, "2dup"},
+				{tokens.Ident, "2drop"},
+				{tokens.Ident, "-"},
+				{tokens.Number, "4"},
+				{tokens.Number, "-5"},
+			},
+		},
 		{
 			"+++",
 			[]expectedToken{
@@ -119,3 +182,37 @@ func TestLexer(t *testing.T) {
 		}
 	}
 }
+
+func TestLexComments(t *testing.T) {
+	input := "1 ; one\n(|1:1 -> a ; @a) ;two"
+	expected := []expectedToken{
+		{tokens.Number, "1"},
+		{tokens.Comment, " one"},
+		{tokens.ParenOpen, "("},
+		{tokens.Signature, "1:1"},
+		{tokens.Params, "a"},
+		{tokens.GetValue, "a"},
+		{tokens.ParenClose, ")"},
+		{tokens.Comment, "two"},
+	}
+
+	l := New(input)
+
+	actual, err := l.LexComments()
+	if err != nil {
+		t.Fatal(err.Error())
+	} else if len(actual) != len(expected) {
+		t.Fatalf("expected %d tokens, but found %d instead\noutput: %v", len(expected), len(actual), actual)
+	}
+
+	for i, a := range actual {
+		if e := expected[i]; !a.Is(e.kind, e.lit) {
+			t.Fatalf("expected (%s, '%s') but found (%s, '%s') instead", e.kind.String(), e.lit, a.Kind().String(), a.Lit())
+		}
+
+		// every token's position leads back to where it's written
+		if offset := Offset(input, a.Line(), a.Col()); !strings.HasPrefix(input[offset:], a.Kind().PublicString()) && !strings.HasPrefix(input[offset:], a.Lit()) {
+			t.Fatalf("expected %s to be at offset %d, but found `%s` there", a, offset, input[offset:])
+		}
+	}
+}
@@ -26,6 +26,8 @@ const (
 	Hyphen
 	Asterisk
 	ForwardSlash
+	Params
+	Comment
 )
 
 var kindLitMap = map[TokenKind][2]string{
@@ -41,8 +43,11 @@ var kindLitMap = map[TokenKind][2]string{
 	GetIndex:     {"GetIndex", "@#"},
 	ParenOpen:    {"ParenOpen", "("},
 	ParenClose:   {"ParenClose", ")"},
+	BracketOpen:  {"BracketOpen", "["},
 	BracketClose: {"BracketClose", "]"},
 	Signature:    {"Signature", "|"},
+	Params:       {"Params", "->"},
+	Comment:      {"Comment", ";"},
 }
 
 func (tk TokenKind) PublicString() string {
@@ -87,8 +92,30 @@ func (t Token) Lit() string {
 	return t.lit
 }
 
+func (t Token) Line() int {
+	return t.ln
+}
+
+func (t Token) Col() int {
+	return t.col
+}
+
+// An error at the position of a token
+type Error struct {
+	Line, Col int
+	Err       error
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("error on line %d, col %d: %s", e.Line, e.Col, e.Err.Error())
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
 func (t Token) Errf(format string, a ...any) error {
-	return fmt.Errorf("error on line %d, col %d: %s", t.ln, t.col, fmt.Sprintf(format, a...))
+	return &Error{Line: t.ln, Col: t.col, Err: fmt.Errorf(format, a...)}
 }
 
 func (t Token) Err(err error) error {
@@ -96,7 +123,7 @@ func (t Token) Err(err error) error {
 		return nil
 	}
 
-	return t.Errf(err.Error())
+	return &Error{Line: t.ln, Col: t.col, Err: err}
 }
 
 func (t Token) String() string {
@@ -0,0 +1,24 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"os"
+
+	"github.com/voidwyrm-2/reqproc/lsp"
+)
+
+// reqproc lsp
+func lspCommand(args []string) error {
+	fs := flag.NewFlagSet("lsp", flag.ContinueOnError)
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if fs.NArg() != 0 {
+		return errors.New("expected 'reqproc lsp', which speaks the Language Server Protocol over stdio")
+	}
+
+	return lsp.Serve(os.Stdin, os.Stdout)
+}
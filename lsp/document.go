@@ -0,0 +1,590 @@
+package lsp
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"slices"
+	"strings"
+	"unicode"
+
+	"github.com/voidwyrm-2/reqproc/check"
+	"github.com/voidwyrm-2/reqproc/lexer"
+	"github.com/voidwyrm-2/reqproc/lexer/tokens"
+	"github.com/voidwyrm-2/reqproc/runtime/stdlib"
+	"github.com/voidwyrm-2/reqproc/runtime/types"
+	"github.com/voidwyrm-2/reqproc/runtime/types/functiontype"
+)
+
+// the words handled by the interpreter itself rather than the stdlib
+var keywords = []string{"as", "def", "err", "errcl", "export", "false", "geterr", "import", "notry", "only", "true", "try"}
+
+// A document the client has open
+type document struct {
+	text string
+
+	// the tokens of the text, including comments; nil if it can't be lexed
+	toks []tokens.Token
+}
+
+func newDocument(text string) *document {
+	l := lexer.New(text)
+
+	toks, err := l.LexComments()
+	if err != nil {
+		toks = nil
+	}
+
+	return &document{text: text, toks: toks}
+}
+
+// the errors of the lexer and the problems the checker finds, which are what running the code would run into
+func (d *document) diagnostics() []Diagnostic {
+	diagnostic := func(line, col int, msg string) Diagnostic {
+		start := lexer.Offset(d.text, line, col)
+		return Diagnostic{Range: d.word(start), Severity: severityError, Source: "reqproc", Message: msg}
+	}
+
+	found, err := check.Source(d.text, nil)
+	if err != nil {
+		if terr := (*tokens.Error)(nil); errors.As(err, &terr) {
+			return []Diagnostic{diagnostic(terr.Line, terr.Col, terr.Err.Error())}
+		}
+
+		return []Diagnostic{diagnostic(1, 1, err.Error())}
+	}
+
+	diags := make([]Diagnostic, 0, len(found))
+
+	for _, f := range found {
+		diags = append(diags, diagnostic(f.Line, f.Col, f.Message))
+	}
+
+	return diags
+}
+
+// the range of the word starting at an index of the text
+func (d *document) word(start int) Range {
+	end := start
+
+	for end < len(d.text) && !unicode.IsSpace(rune(d.text[end])) {
+		end++
+	}
+
+	return Range{Start: position(d.text, start), End: position(d.text, end)}
+}
+
+// the part of a token's name which is written before it
+func sigil(t tokens.Token) string {
+	switch t.Kind() {
+	case tokens.Label:
+		return ":"
+	case tokens.Assign:
+		return "!"
+	case tokens.Const:
+		return "$"
+	case tokens.GetValue:
+		return "@"
+	}
+
+	return ""
+}
+
+// reports whether a token names something, like `swap`, `@x`, `$x`, `!x` or `:x`
+func isName(t tokens.Token) bool {
+	return t.Iskind(tokens.Ident) || t.Iskind(tokens.GetValue) || t.Iskind(tokens.Const) || t.Iskind(tokens.Assign) || t.Iskind(tokens.Label)
+}
+
+// the indexes in the text where a token starts and ends
+func (d *document) span(t tokens.Token) (int, int) {
+	start := lexer.Offset(d.text, t.Line(), t.Col())
+
+	if isName(t) {
+		return start, start + len(sigil(t)) + len(t.Lit())
+	} else if t.Iskind(tokens.String) && start < len(d.text) {
+		// strings are written with quotes and escapes
+		quote := d.text[start]
+
+		for i := start + 1; i < len(d.text); i++ {
+			if d.text[i] == '\\' && quote == '"' {
+				i++
+			} else if d.text[i] == quote {
+				return start, i + 1
+			}
+		}
+
+		return start, len(d.text)
+	}
+
+	return start, start + len(t.Lit())
+}
+
+func (d *document) rangeOf(t tokens.Token) Range {
+	start, end := d.span(t)
+	return Range{Start: position(d.text, start), End: position(d.text, end)}
+}
+
+// the index of the name token at an index of the text, or -1
+func (d *document) nameAt(at int) int {
+	for i, t := range d.toks {
+		if start, end := d.span(t); isName(t) && start <= at && at <= end {
+			return i
+		}
+	}
+
+	return -1
+}
+
+// the token before toks[i] which isn't a comment, or a token of kind None
+func (d *document) before(i int) tokens.Token {
+	for i--; i > -1; i-- {
+		if !d.toks[i].Iskind(tokens.Comment) {
+			return d.toks[i]
+		}
+	}
+
+	return tokens.Token{}
+}
+
+// the index of the token after toks[i] which isn't a comment, or -1
+func (d *document) next(i int) int {
+	for i++; i < len(d.toks); i++ {
+		if !d.toks[i].Iskind(tokens.Comment) {
+			return i
+		}
+	}
+
+	return -1
+}
+
+// the token after toks[i] which isn't a comment, or a token of kind None
+func (d *document) after(i int) tokens.Token {
+	if next := d.next(i); next != -1 {
+		return d.toks[next]
+	}
+
+	return tokens.Token{}
+}
+
+// the modules the document imports, by the name they're bound to
+func (d *document) imports() map[string]string {
+	modules := map[string]string{}
+
+	for i, t := range d.toks {
+		if !t.Iskind(tokens.String) || !d.after(i).Is(tokens.Ident, "import") {
+			continue
+		}
+
+		name := strings.TrimSuffix(filepath.Base(t.Lit()), ".req")
+
+		// `"io" import as out`
+		if as := d.next(d.next(i)); as != -1 && d.toks[as].Is(tokens.Ident, "as") {
+			name = d.after(as).Lit()
+		}
+
+		modules[name] = t.Lit()
+	}
+
+	return modules
+}
+
+// a place where the document defines a name
+type definition struct {
+	name string
+	kind int
+
+	// the index of the token which names it, and of the token its value starts at
+	at, value int
+}
+
+// every name the document defines, in the order they're defined
+func (d *document) definitions() []definition {
+	defs := []definition{}
+
+	for i, t := range d.toks {
+		switch {
+		case t.Iskind(tokens.Const):
+			def := definition{name: t.Lit(), kind: symbolConstant, at: i, value: i}
+
+			// `(|2:1 +) $add`
+			if open := d.literalBefore(i); open != -1 {
+				def.kind, def.value = symbolFunction, open
+			}
+
+			defs = append(defs, def)
+		case t.Iskind(tokens.Label):
+			defs = append(defs, definition{name: t.Lit(), kind: symbolKey, at: i, value: i})
+		case t.Iskind(tokens.Params):
+			for _, name := range strings.Fields(t.Lit()) {
+				defs = append(defs, definition{name: name, kind: symbolVariable, at: i, value: i})
+			}
+		case t.Is(tokens.Ident, "def"):
+			if next := d.next(i); next != -1 && d.toks[next].Iskind(tokens.Ident) {
+				defs = append(defs, definition{name: d.toks[next].Lit(), kind: symbolVariable, at: next, value: i})
+			}
+		case t.Iskind(tokens.String) && d.after(i).Is(tokens.Ident, "import"):
+			for name, path := range d.imports() {
+				if path == t.Lit() {
+					defs = append(defs, definition{name: name, kind: symbolModule, at: i, value: i})
+				}
+			}
+		}
+	}
+
+	return defs
+}
+
+// the index of the '(' of the function literal which ends right before toks[i], or -1
+func (d *document) literalBefore(i int) int {
+	j := i - 1
+	for j > -1 && d.toks[j].Iskind(tokens.Comment) {
+		j--
+	}
+
+	if j < 0 || !d.toks[j].Iskind(tokens.ParenClose) {
+		return -1
+	}
+
+	depth := 0
+
+	for ; j > -1; j-- {
+		if d.toks[j].Iskind(tokens.ParenClose) {
+			depth++
+		} else if d.toks[j].Iskind(tokens.ParenOpen) {
+			if depth--; depth == 0 {
+				return j
+			}
+		}
+	}
+
+	return -1
+}
+
+// parses the function literal starting at toks[open]
+func (d *document) literal(open int) (functiontype.ReqFunctionType, bool) {
+	content := []tokens.Token{}
+	depth := 0
+
+	for _, t := range d.toks[open+1:] {
+		if t.Iskind(tokens.ParenOpen) {
+			depth++
+		} else if t.Iskind(tokens.ParenClose) {
+			if depth--; depth < 0 {
+				break
+			}
+		}
+
+		if !t.Iskind(tokens.Comment) {
+			content = append(content, t)
+		}
+	}
+
+	rft, err := functiontype.Parse(content)
+
+	return rft, err == nil
+}
+
+// the comments on the lines right above toks[i], which document what it defines
+func (d *document) comments(i int) string {
+	lines := []string{}
+	line := d.toks[i].Line()
+
+	for j := i - 1; j > -1 && d.toks[j].Iskind(tokens.Comment) && d.toks[j].Line() == line-1; j-- {
+		// only comments which are alone on their line
+		if j > 0 && d.toks[j-1].Line() == d.toks[j].Line() {
+			break
+		}
+
+		lines = append(lines, strings.TrimSpace(d.toks[j].Lit()))
+		line--
+	}
+
+	slices.Reverse(lines)
+
+	return strings.Join(lines, "\n")
+}
+
+// the definition of the name at toks[i] which is in effect there, if the document defines it
+func (d *document) definitionOf(i int) (definition, bool) {
+	t := d.toks[i]
+	name, isLabel := t.Lit(), t.Iskind(tokens.Label) || d.before(i).Is(tokens.Ident, "err")
+
+	// `io.putl` is defined where io is
+	if !isLabel {
+		name, _, _ = strings.Cut(name, ".")
+	}
+
+	var found *definition
+
+	for _, def := range d.definitions() {
+		if def.name != name || (def.kind == symbolKey) != isLabel {
+			continue
+		}
+
+		// the closest definition before the name, otherwise the first one after it
+		if def.at <= i || found == nil {
+			found = &def
+		}
+	}
+
+	if found == nil {
+		return definition{}, false
+	}
+
+	return *found, true
+}
+
+// what a name refers to in the stdlib, looking through the modules the document imports
+func (d *document) lookup(name string) (types.ReqType, bool) {
+	if v, ok := stdlib.Stdlib["__init__"][name]; ok {
+		return v, true
+	}
+
+	path := strings.Split(name, ".")
+
+	module, ok := d.imports()[path[0]]
+	if !ok {
+		module = path[0]
+	}
+
+	members, ok := stdlib.Stdlib[module]
+	if !ok || strings.HasPrefix(module, "__") {
+		return nil, false
+	}
+
+	for n, member := range path[1:] {
+		v, ok := members[member]
+		if !ok {
+			return nil, false
+		} else if n == len(path)-2 {
+			return v, true
+		} else if members, ok = v.Literal().(map[string]types.ReqType); !ok {
+			return nil, false
+		}
+	}
+
+	return nil, false
+}
+
+// describes a value for hovers and completions, and returns its documentation
+func describe(v types.ReqType) (string, string) {
+	if rft, ok := v.(functiontype.ReqFunctionType); ok {
+		return rft.String(), rft.Doc()
+	} else if v.Type() == types.TypeTable {
+		return "table", ""
+	}
+
+	return v.Type().String(), ""
+}
+
+func markdown(signature, doc string) MarkupContent {
+	value := "```reqproc\n" + signature + "\n```"
+	if doc != "" {
+		value += "\n\n" + doc
+	}
+
+	return MarkupContent{Kind: "markdown", Value: value}
+}
+
+func (d *document) hover(at int) *Hover {
+	i := d.nameAt(at)
+	if i == -1 {
+		return nil
+	}
+
+	t := d.toks[i]
+	r := d.rangeOf(t)
+
+	if def, ok := d.definitionOf(i); ok && (def.kind != symbolModule || !strings.Contains(t.Lit(), ".")) {
+		signature, doc := fmt.Sprintf("%s%s", sigil(d.toks[def.at]), def.name), d.comments(def.value)
+
+		switch def.kind {
+		case symbolFunction:
+			if rft, ok := d.literal(def.value); ok {
+				signature = fmt.Sprintf("%s %s", def.name, rft.String())
+			}
+		case symbolModule:
+			signature = fmt.Sprintf("module %s", d.imports()[def.name])
+		case symbolKey:
+			signature = ":" + def.name
+		}
+
+		return &Hover{Contents: markdown(signature, doc), Range: &r}
+	}
+
+	if v, ok := d.lookup(t.Lit()); ok {
+		signature, doc := describe(v)
+		return &Hover{Contents: markdown(t.Lit()+" "+signature, doc), Range: &r}
+	} else if slices.Contains(keywords, t.Lit()) {
+		return &Hover{Contents: markdown(t.Lit()+" keyword", ""), Range: &r}
+	}
+
+	return nil
+}
+
+func (d *document) definition(at int) *Range {
+	i := d.nameAt(at)
+	if i == -1 {
+		return nil
+	}
+
+	def, ok := d.definitionOf(i)
+	if !ok {
+		return nil
+	}
+
+	r := d.rangeOf(d.toks[def.at])
+
+	return &r
+}
+
+// the part of a name which is written before an index of the text, without its sigil
+func (d *document) prefix(at int) string {
+	start := at
+	for start > 0 && lexerIdent(d.text[start-1]) {
+		start--
+	}
+
+	return strings.TrimLeft(d.text[start:at], "@$!:")
+}
+
+func lexerIdent(ch byte) bool {
+	return !unicode.IsSpace(rune(ch)) && !strings.ContainsRune("()[]\"`;", rune(ch))
+}
+
+func (d *document) completion(at int) []CompletionItem {
+	prefix := d.prefix(at)
+	items := []CompletionItem{}
+
+	add := func(label string, kind int, v types.ReqType) {
+		if !strings.HasPrefix(label, prefix) && !strings.Contains(prefix, ".") {
+			return
+		}
+
+		item := CompletionItem{Label: label, Kind: kind}
+
+		if v != nil {
+			signature, doc := describe(v)
+			item.Detail = signature
+
+			if doc != "" {
+				item.Documentation = &MarkupContent{Kind: "markdown", Value: doc}
+			}
+
+			if _, ok := v.(functiontype.ReqFunctionType); ok {
+				item.Kind = completionFunction
+			} else if v.Type() == types.TypeTable {
+				item.Kind = completionModule
+			}
+		}
+
+		items = append(items, item)
+	}
+
+	// the members of a module, e.g. after `io.`
+	if base, member, ok := cutLast(prefix, "."); ok {
+		var members map[string]types.ReqType
+
+		if v, found := d.lookup(base); found {
+			members, _ = v.Literal().(map[string]types.ReqType)
+		} else if module, imported := d.imports()[base]; imported && !strings.HasPrefix(module, "__") {
+			members = stdlib.Stdlib[module]
+		} else if !strings.HasPrefix(base, "__") && !strings.Contains(base, ".") {
+			members = stdlib.Stdlib[base]
+		}
+
+		for _, name := range sortedKeys(members) {
+			if strings.HasPrefix(name, member) {
+				add(name, completionConstant, members[name])
+			}
+		}
+
+		return items
+	}
+
+	for _, name := range sortedKeys(stdlib.Stdlib["__init__"]) {
+		add(name, completionFunction, stdlib.Stdlib["__init__"][name])
+	}
+
+	for _, name := range keywords {
+		add(name, completionKeyword, nil)
+	}
+
+	seen := map[string]bool{}
+
+	for _, def := range d.definitions() {
+		if seen[def.name] || def.kind == symbolKey {
+			continue
+		}
+
+		seen[def.name] = true
+
+		switch def.kind {
+		case symbolModule:
+			add(def.name, completionModule, nil)
+		case symbolFunction:
+			add(def.name, completionFunction, nil)
+		default:
+			add(def.name, completionVariable, nil)
+		}
+	}
+
+	for _, name := range sortedKeys(stdlib.Stdlib) {
+		if !strings.HasPrefix(name, "__") && !seen[name] {
+			add(name, completionModule, nil)
+		}
+	}
+
+	return items
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+
+	for k := range m {
+		keys = append(keys, k)
+	}
+
+	slices.Sort(keys)
+
+	return keys
+}
+
+// like strings.Cut, but at the last instance of sep
+func cutLast(s, sep string) (string, string, bool) {
+	if i := strings.LastIndex(s, sep); i != -1 {
+		return s[:i], s[i+len(sep):], true
+	}
+
+	return s, "", false
+}
+
+func (d *document) symbols() []DocumentSymbol {
+	symbols := []DocumentSymbol{}
+
+	for _, def := range d.definitions() {
+		// inputs are only names inside their function
+		if d.toks[def.at].Iskind(tokens.Params) {
+			continue
+		}
+
+		start, _ := d.span(d.toks[def.value])
+		_, end := d.span(d.toks[def.at])
+		symbol := DocumentSymbol{
+			Name:           def.name,
+			Kind:           def.kind,
+			Range:          Range{Start: position(d.text, start), End: position(d.text, end)},
+			SelectionRange: d.rangeOf(d.toks[def.at]),
+		}
+
+		if def.kind == symbolFunction {
+			if rft, ok := d.literal(def.value); ok {
+				symbol.Detail = rft.String()
+			}
+		}
+
+		symbols = append(symbols, symbol)
+	}
+
+	return symbols
+}
@@ -0,0 +1,184 @@
+/*
+Package lsp is a Language Server Protocol server for ReqProc, which editors run as `reqproc lsp` and talk to over stdio
+
+it reports the errors of the lexer and the problems found by the check package as diagnostics, shows the signature and
+documentation of words on hover, completes stdlib words and module members, finds where constants, variables and labels are
+defined, and lists the symbols of a document; the code itself is never run
+*/
+package lsp
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"io"
+)
+
+type server struct {
+	w        io.Writer
+	docs     map[string]*document
+	shutdown bool
+}
+
+// Serves one client reading requests from r and writing responses to w, until it sends exit or r is closed
+func Serve(r io.Reader, w io.Writer) error {
+	s := &server{w: w, docs: map[string]*document{}}
+	br := bufio.NewReader(r)
+
+	for {
+		msg, err := readMessage(br)
+		if errors.Is(err, io.EOF) {
+			return nil
+		} else if jerr := (errInvalidJSON{}); errors.As(err, &jerr) {
+			if err = s.reply(nil, nil, &responseError{Code: codeParseError, Message: err.Error()}); err != nil {
+				return err
+			}
+
+			continue
+		} else if err != nil {
+			return err
+		}
+
+		if msg.Method == "exit" {
+			if !s.shutdown {
+				return errors.New("the client exited without shutting down the server")
+			}
+
+			return nil
+		}
+
+		result, rerr := s.handle(msg)
+
+		// notifications don't get a response
+		if msg.ID == nil {
+			continue
+		}
+
+		if err = s.reply(msg.ID, result, rerr); err != nil {
+			return err
+		}
+	}
+}
+
+func (s *server) reply(id *json.RawMessage, result any, rerr *responseError) error {
+	if rerr != nil {
+		return writeMessage(s.w, errorResponse{JSONRPC: "2.0", ID: id, Error: *rerr})
+	}
+
+	return writeMessage(s.w, response{JSONRPC: "2.0", ID: id, Result: result})
+}
+
+func (s *server) notify(method string, params any) error {
+	return writeMessage(s.w, notification{JSONRPC: "2.0", Method: method, Params: params})
+}
+
+func (s *server) handle(msg message) (any, *responseError) {
+	switch msg.Method {
+	case "initialize":
+		return map[string]any{
+			"capabilities": map[string]any{
+				// the client sends the whole document whenever it changes
+				"textDocumentSync":       1,
+				"hoverProvider":          true,
+				"completionProvider":     map[string]any{"triggerCharacters": []string{".", "@", "$", "!"}},
+				"definitionProvider":     true,
+				"documentSymbolProvider": true,
+			},
+			"serverInfo": map[string]any{"name": "reqproc"},
+		}, nil
+	case "shutdown":
+		s.shutdown = true
+		return nil, nil
+	case "textDocument/didOpen":
+		var params didOpenParams
+		if err := json.Unmarshal(msg.Params, &params); err != nil {
+			return nil, invalidParams(err)
+		}
+
+		return nil, s.open(params.TextDocument.URI, params.TextDocument.Text)
+	case "textDocument/didChange":
+		var params didChangeParams
+		if err := json.Unmarshal(msg.Params, &params); err != nil {
+			return nil, invalidParams(err)
+		} else if len(params.ContentChanges) == 0 {
+			return nil, nil
+		}
+
+		return nil, s.open(params.TextDocument.URI, params.ContentChanges[len(params.ContentChanges)-1].Text)
+	case "textDocument/didClose":
+		var params textDocumentParams
+		if err := json.Unmarshal(msg.Params, &params); err != nil {
+			return nil, invalidParams(err)
+		}
+
+		delete(s.docs, params.TextDocument.URI)
+
+		// the client shouldn't keep showing the problems of a document which isn't open
+		return nil, s.publish(params.TextDocument.URI, []Diagnostic{})
+	case "textDocument/hover", "textDocument/completion", "textDocument/definition":
+		var params textDocumentPositionParams
+		if err := json.Unmarshal(msg.Params, &params); err != nil {
+			return nil, invalidParams(err)
+		}
+
+		doc, ok := s.docs[params.TextDocument.URI]
+		if !ok {
+			return nil, nil
+		}
+
+		at := offset(doc.text, params.Position)
+
+		switch msg.Method {
+		case "textDocument/hover":
+			if hover := doc.hover(at); hover != nil {
+				return hover, nil
+			}
+		case "textDocument/completion":
+			return doc.completion(at), nil
+		case "textDocument/definition":
+			if r := doc.definition(at); r != nil {
+				return Location{URI: params.TextDocument.URI, Range: *r}, nil
+			}
+		}
+
+		return nil, nil
+	case "textDocument/documentSymbol":
+		var params textDocumentParams
+		if err := json.Unmarshal(msg.Params, &params); err != nil {
+			return nil, invalidParams(err)
+		}
+
+		if doc, ok := s.docs[params.TextDocument.URI]; ok {
+			return doc.symbols(), nil
+		}
+
+		return []DocumentSymbol{}, nil
+	}
+
+	// requests the server doesn't know have to be answered, but notifications can be ignored
+	if msg.ID != nil {
+		return nil, &responseError{Code: codeMethodNotFound, Message: "method '" + msg.Method + "' is not supported"}
+	}
+
+	return nil, nil
+}
+
+func invalidParams(err error) *responseError {
+	return &responseError{Code: codeInvalidParams, Message: err.Error()}
+}
+
+// stores the text of a document and publishes its diagnostics
+func (s *server) open(uri, text string) *responseError {
+	doc := newDocument(text)
+	s.docs[uri] = doc
+
+	return s.publish(uri, doc.diagnostics())
+}
+
+func (s *server) publish(uri string, diags []Diagnostic) *responseError {
+	if err := s.notify("textDocument/publishDiagnostics", publishDiagnosticsParams{URI: uri, Diagnostics: diags}); err != nil {
+		return &responseError{Code: codeInternalError, Message: err.Error()}
+	}
+
+	return nil
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"bufio"
 	_ "embed"
 	"errors"
 	"flag"
@@ -13,6 +14,7 @@ import (
 	"github.com/voidwyrm-2/reqproc/runtime/interpreter"
 	"github.com/voidwyrm-2/reqproc/runtime/scope"
 	"github.com/voidwyrm-2/reqproc/runtime/types"
+	"github.com/voidwyrm-2/reqproc/trace"
 	// "github.com/voidwyrm-2/vcheck"
 	// "github.com/voidwyrm-2/vcheck/version"
 )
@@ -22,10 +24,28 @@ var localVersionString string
 
 const versionURL = "https://github.com/voidwyrm-2/reqproc/blob/main/example/version.txt"
 
+// subcommands, run as 'reqproc <name> args...'
+var commands = map[string]func(args []string) error{
+	"mod":   modCommand,
+	"check": checkCommand,
+	"fmt":   fmtCommand,
+	"lsp":   lspCommand,
+	"debug": debugCommand,
+}
+
 func _main() error {
+	if len(os.Args) > 1 {
+		if cmd, ok := commands[os.Args[1]]; ok {
+			return cmd(os.Args[2:])
+		}
+	}
+
 	fpath := flag.String("f", "", "The file to interpret")
 	showVersion := flag.Bool("v", false, "Prints the interpreter version and exits")
 	showTokens := flag.Bool("t", false, "Show the generated tokens")
+	traceExecution := flag.Bool("trace", false, "Log every token executed and every function called, with the stack around them")
+	traceFile := flag.String("trace-file", "", "The file -trace writes to instead of stderr")
+	traceFormat := flag.String("trace-format", "human", "The format of -trace, either 'human' or 'json' (one JSON object per line)")
 	runREPL := flag.Bool("repl", false, "Run the repl instead of a file")
 	// noVersionChecks := flag.Bool("nvc", false, "Do not check for a newer version; useful if internet is not available")
 
@@ -93,6 +113,36 @@ func _main() error {
 		return err
 	}
 
+	if err = interp.SetFile(*fpath); err != nil {
+		return err
+	}
+
+	if *traceExecution {
+		format, err := trace.FormatFromString(*traceFormat)
+		if err != nil {
+			return err
+		}
+
+		var out io.Writer = os.Stderr
+
+		if *traceFile != "" {
+			f, err := os.Create(*traceFile)
+			if err != nil {
+				return err
+			}
+
+			defer f.Close()
+
+			// traces are long, so files are written in chunks rather than a line at a time
+			w := bufio.NewWriter(f)
+			defer w.Flush()
+
+			out = w
+		}
+
+		interp.SetHook(trace.New(out, format))
+	}
+
 	_, err = interp.ExecuteTokens(tokens)
 
 	return err
@@ -0,0 +1,151 @@
+package manifest
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// A vendored package, Dir is relative to the lockfile's directory and Sum is what Sum returned for it once it was vendored
+type Locked struct {
+	Name, Version, Dir, Sum string
+}
+
+// Returns the directory of the package, which has to be inside the vendor directory of root
+func (p Locked) Path(root string) (string, error) {
+	clean := path.Clean(filepath.ToSlash(p.Dir))
+
+	if path.IsAbs(clean) || filepath.IsAbs(p.Dir) || !strings.HasPrefix(clean, VendorDir+"/") {
+		return "", fmt.Errorf("the directory '%s' of package '%s' is not inside '%s'", p.Dir, p.Name, VendorDir)
+	}
+
+	return filepath.Join(root, filepath.FromSlash(clean)), nil
+}
+
+/*
+The contents of a reqproc.lock file, written by Vendor
+
+each line is `<name> <version> <dir> <sum>`
+*/
+type Lock struct {
+	// the directory the lockfile is in
+	Root     string
+	Packages []Locked
+}
+
+const lockHeader = "; generated by `reqproc mod`, do not edit\n"
+
+func ParseLock(root, text string) (Lock, error) {
+	l := Lock{Root: root, Packages: []Locked{}}
+
+	for n, line := range strings.Split(text, "\n") {
+		if i := strings.Index(line, ";"); i != -1 {
+			line = line[:i]
+		}
+
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		} else if len(fields) != 4 {
+			return Lock{}, fmt.Errorf("%s:%d: expected '<name> <version> <dir> <sum>'", filepath.Join(root, LockName), n+1)
+		}
+
+		p := Locked{Name: fields[0], Version: fields[1], Dir: fields[2], Sum: fields[3]}
+		if _, err := p.Path(root); err != nil {
+			return Lock{}, fmt.Errorf("%s:%d: %s", filepath.Join(root, LockName), n+1, err.Error())
+		}
+
+		l.Packages = append(l.Packages, p)
+	}
+
+	return l, nil
+}
+
+func ReadLock(root string) (Lock, error) {
+	content, err := os.ReadFile(filepath.Join(root, LockName))
+	if err != nil {
+		return Lock{}, err
+	}
+
+	return ParseLock(root, string(content))
+}
+
+func (l Lock) String() string {
+	b := strings.Builder{}
+	b.WriteString(lockHeader)
+
+	for _, p := range l.Packages {
+		fmt.Fprintf(&b, "%s %s %s %s\n", p.Name, p.Version, p.Dir, p.Sum)
+	}
+
+	return b.String()
+}
+
+func (l Lock) Write() error {
+	return os.WriteFile(filepath.Join(l.Root, LockName), []byte(l.String()), 0o644)
+}
+
+// Looks for a reqproc.lock in dir and each of its parents
+func FindLock(dir string) (Lock, bool, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return Lock{}, false, err
+	}
+
+	for {
+		l, err := ReadLock(dir)
+		if err == nil {
+			return l, true, nil
+		} else if !errors.Is(err, os.ErrNotExist) {
+			return Lock{}, false, err
+		}
+
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return Lock{}, false, nil
+		}
+
+		dir = parent
+	}
+}
+
+/*
+Maps an import like "util/strings.req" to the file inside the vendored package it names, reporting whether it names one
+
+the package is checked against its sum first, so an error is returned if it was changed since it was vendored
+*/
+func (l Lock) Resolve(importPath string) (string, bool, error) {
+	name, rest, ok := strings.Cut(filepath.ToSlash(importPath), "/")
+	if !ok {
+		return "", false, nil
+	}
+
+	for _, p := range l.Packages {
+		if p.Name != name {
+			continue
+		}
+
+		dir, err := p.Path(l.Root)
+		if err != nil {
+			return "", true, err
+		}
+
+		rest = path.Clean(rest)
+		if rest == ".." || strings.HasPrefix(rest, "../") || path.IsAbs(rest) {
+			return "", true, fmt.Errorf("import '%s' escapes the directory of package '%s'", importPath, p.Name)
+		}
+
+		if sum, err := Sum(dir); err != nil {
+			return "", true, err
+		} else if sum != p.Sum {
+			return "", true, fmt.Errorf("package '%s' has been changed since it was vendored (its sum is %s instead of %s), run `reqproc mod` to vendor it again", p.Name, sum, p.Sum)
+		}
+
+		return filepath.Join(dir, filepath.FromSlash(rest)), true, nil
+	}
+
+	return "", false, nil
+}
@@ -0,0 +1,140 @@
+package manifest
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	ManifestName = "reqproc.mod"
+	LockName     = "reqproc.lock"
+	VendorDir    = "vendor"
+)
+
+/*
+A dependency declared with `require <name> <source>`
+
+the source is either a version, which is looked up in the registry, or a path (starting with './', '../' or '/') to a directory or a .zip/.tar.gz archive
+*/
+type Requirement struct {
+	Name, Source string
+}
+
+func (r Requirement) IsPath() bool {
+	return strings.HasPrefix(r.Source, "./") || strings.HasPrefix(r.Source, "../") || filepath.IsAbs(r.Source)
+}
+
+/*
+The contents of a reqproc.mod file, e.g.
+
+	package mylib
+	version 1.0.0
+
+	require util 1.2.0
+	require helpers ../helpers
+	require parser ./archives/parser.zip
+
+comments start with ';', like in ReqProc
+*/
+type Manifest struct {
+	Package, Version string
+	Requires         []Requirement
+}
+
+func isPackageName(name string) bool {
+	if name == "" || name == "." || name == ".." {
+		return false
+	}
+
+	for _, ch := range name {
+		if !(ch >= 'a' && ch <= 'z' || ch >= 'A' && ch <= 'Z' || ch >= '0' && ch <= '9' || ch == '_' || ch == '-' || ch == '.') {
+			return false
+		}
+	}
+
+	return true
+}
+
+func Parse(name, text string) (Manifest, error) {
+	m := Manifest{Requires: []Requirement{}}
+
+	for n, line := range strings.Split(text, "\n") {
+		if i := strings.Index(line, ";"); i != -1 {
+			line = line[:i]
+		}
+
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+
+		errf := func(format string, a ...any) error {
+			return fmt.Errorf("%s:%d: %s", name, n+1, fmt.Sprintf(format, a...))
+		}
+
+		switch fields[0] {
+		case "package", "version":
+			if len(fields) != 2 {
+				return Manifest{}, errf("expected '%s <%s>'", fields[0], fields[0])
+			}
+
+			if fields[0] == "package" {
+				if !isPackageName(fields[1]) {
+					return Manifest{}, errf("'%s' is not a valid package name", fields[1])
+				}
+
+				m.Package = fields[1]
+			} else {
+				m.Version = fields[1]
+			}
+		case "require":
+			if len(fields) != 3 {
+				return Manifest{}, errf("expected 'require <name> <version or path>'")
+			} else if !isPackageName(fields[1]) {
+				return Manifest{}, errf("'%s' is not a valid package name", fields[1])
+			}
+
+			for _, r := range m.Requires {
+				if r.Name == fields[1] {
+					return Manifest{}, errf("'%s' is already required", fields[1])
+				}
+			}
+
+			m.Requires = append(m.Requires, Requirement{Name: fields[1], Source: fields[2]})
+		default:
+			return Manifest{}, errf("unknown directive '%s'", fields[0])
+		}
+	}
+
+	if m.Package == "" {
+		return Manifest{}, fmt.Errorf("%s: missing 'package' directive", name)
+	}
+
+	return m, nil
+}
+
+// Reads the reqproc.mod in a directory; the error wraps os.ErrNotExist if there isn't one
+func Load(dir string) (Manifest, error) {
+	p := filepath.Join(dir, ManifestName)
+
+	content, err := os.ReadFile(p)
+	if err != nil {
+		return Manifest{}, err
+	}
+
+	return Parse(p, string(content))
+}
+
+func loadOptional(dir string) (Manifest, bool, error) {
+	m, err := Load(dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return Manifest{}, false, nil
+	} else if err != nil {
+		return Manifest{}, false, err
+	}
+
+	return m, true, nil
+}
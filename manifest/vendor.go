@@ -0,0 +1,437 @@
+package manifest
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// Returns $REQPROC_REGISTRY, or ~/.reqproc/registry if it isn't set
+func DefaultRegistry() string {
+	if r := os.Getenv("REQPROC_REGISTRY"); r != "" {
+		return r
+	}
+
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return filepath.Join(".reqproc", "registry")
+	}
+
+	return filepath.Join(home, ".reqproc", "registry")
+}
+
+func isArchive(p string) bool {
+	return strings.HasSuffix(p, ".zip") || strings.HasSuffix(p, ".tar.gz") || strings.HasSuffix(p, ".tgz")
+}
+
+/*
+Finds where a requirement comes from; from is the directory relative paths are resolved against
+
+registry packages are stored as <registry>/<name>/<version>, either as a directory or with a .zip, .tar.gz or .tgz extension
+*/
+func locate(req Requirement, from, registry string) (string, error) {
+	candidates := []string{}
+
+	if req.IsPath() {
+		p := req.Source
+		if !filepath.IsAbs(p) {
+			p = filepath.Join(from, p)
+		}
+
+		candidates = append(candidates, p)
+	} else {
+		base := filepath.Join(registry, req.Name, req.Source)
+		candidates = append(candidates, base, base+".zip", base+".tar.gz", base+".tgz")
+	}
+
+	for _, c := range candidates {
+		info, err := os.Stat(c)
+		if err != nil {
+			continue
+		} else if info.IsDir() || isArchive(c) {
+			return filepath.Abs(c)
+		}
+	}
+
+	if req.IsPath() {
+		return "", fmt.Errorf("cannot find package '%s' at '%s'", req.Name, candidates[0])
+	}
+
+	return "", fmt.Errorf("cannot find package '%s' version %s in registry '%s'", req.Name, req.Source, registry)
+}
+
+func copyFile(src, dst string, mode fs.FileMode) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+
+	defer in.Close()
+
+	return writeFile(dst, in, mode)
+}
+
+func writeFile(dst string, r io.Reader, mode fs.FileMode) error {
+	if err := os.MkdirAll(filepath.Dir(dst), 0o755); err != nil {
+		return err
+	}
+
+	out, err := os.OpenFile(dst, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, mode.Perm()|0o200)
+	if err != nil {
+		return err
+	}
+
+	defer out.Close()
+
+	_, err = io.Copy(out, r)
+	return err
+}
+
+// copies a package directory, leaving out its own lockfile and vendored packages since those are flattened into the root's
+func copyPackage(src, dst string) error {
+	return filepath.WalkDir(src, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		rel, err := filepath.Rel(src, p)
+		if err != nil {
+			return err
+		}
+
+		if rel == VendorDir || rel == LockName || d.Name() == ".git" {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+
+			return nil
+		} else if d.IsDir() {
+			return os.MkdirAll(filepath.Join(dst, rel), 0o755)
+		} else if !d.Type().IsRegular() {
+			return nil
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+
+		return copyFile(p, filepath.Join(dst, rel), info.Mode())
+	})
+}
+
+// guards against archive entries like "../../etc/passwd"
+func archiveEntryPath(dst, name string) (string, error) {
+	clean := filepath.Clean(filepath.FromSlash(name))
+
+	if filepath.IsAbs(clean) || clean == ".." || strings.HasPrefix(clean, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("archive entry '%s' escapes the package directory", name)
+	} else if clean == "." {
+		return "", nil
+	}
+
+	return filepath.Join(dst, clean), nil
+}
+
+func extractZip(src, dst string) error {
+	r, err := zip.OpenReader(src)
+	if err != nil {
+		return err
+	}
+
+	defer r.Close()
+
+	for _, f := range r.File {
+		p, err := archiveEntryPath(dst, f.Name)
+		if err != nil {
+			return err
+		} else if p == "" {
+			continue
+		}
+
+		if f.FileInfo().IsDir() {
+			if err = os.MkdirAll(p, 0o755); err != nil {
+				return err
+			}
+
+			continue
+		}
+
+		rc, err := f.Open()
+		if err != nil {
+			return err
+		}
+
+		err = writeFile(p, rc, f.Mode())
+		rc.Close()
+
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func extractTarGz(src, dst string) error {
+	f, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+
+	defer f.Close()
+
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		return err
+	}
+
+	defer gz.Close()
+
+	tr := tar.NewReader(gz)
+
+	for {
+		h, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			return nil
+		} else if err != nil {
+			return err
+		}
+
+		p, err := archiveEntryPath(dst, h.Name)
+		if err != nil {
+			return err
+		} else if p == "" {
+			continue
+		}
+
+		switch h.Typeflag {
+		case tar.TypeDir:
+			if err = os.MkdirAll(p, 0o755); err != nil {
+				return err
+			}
+		case tar.TypeReg:
+			if err = writeFile(p, tr, fs.FileMode(h.Mode)); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// archives usually wrap everything in a single directory like "util-1.0.0/", which is unwrapped here
+func unwrapSingleDir(dst string) error {
+	entries, err := os.ReadDir(dst)
+	if err != nil {
+		return err
+	} else if len(entries) != 1 || !entries[0].IsDir() {
+		return nil
+	}
+
+	inner := filepath.Join(dst, entries[0].Name())
+
+	children, err := os.ReadDir(inner)
+	if err != nil {
+		return err
+	}
+
+	for _, c := range children {
+		if err = os.Rename(filepath.Join(inner, c.Name()), filepath.Join(dst, c.Name())); err != nil {
+			return err
+		}
+	}
+
+	return os.Remove(inner)
+}
+
+func unpack(src, dst string) error {
+	if err := os.MkdirAll(dst, 0o755); err != nil {
+		return err
+	}
+
+	if !isArchive(src) {
+		return copyPackage(src, dst)
+	}
+
+	var err error
+	if strings.HasSuffix(src, ".zip") {
+		err = extractZip(src, dst)
+	} else {
+		err = extractTarGz(src, dst)
+	}
+
+	if err != nil {
+		return fmt.Errorf("cannot extract '%s': %s", src, err.Error())
+	}
+
+	return unwrapSingleDir(dst)
+}
+
+// Hashes the paths and contents of every file in a directory
+func Sum(dir string) (string, error) {
+	files := []string{}
+
+	err := filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		} else if d.Type().IsRegular() {
+			files = append(files, p)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+
+	slices.Sort(files)
+
+	h := sha256.New()
+
+	for _, f := range files {
+		rel, err := filepath.Rel(dir, f)
+		if err != nil {
+			return "", err
+		}
+
+		content, err := os.ReadFile(f)
+		if err != nil {
+			return "", err
+		}
+
+		fmt.Fprintf(h, "%s\x00%d\x00", filepath.ToSlash(rel), len(content))
+		h.Write(content)
+	}
+
+	return "h1:" + hex.EncodeToString(h.Sum(nil)), nil
+}
+
+type pendingRequirement struct {
+	req Requirement
+	// the directory relative paths in req are resolved against, and the package that required it
+	from, by string
+}
+
+/*
+Resolves the dependencies of the manifest in root (and theirs, recursively), copies them into root/vendor and writes root/reqproc.lock
+
+everything is read from the local filesystem, so it works offline; a package required from two different sources is an error
+*/
+func Vendor(root, registry string) (Lock, error) {
+	root, err := filepath.Abs(root)
+	if err != nil {
+		return Lock{}, err
+	}
+
+	m, err := Load(root)
+	if err != nil {
+		return Lock{}, err
+	}
+
+	// anything vendored by a previous run is replaced
+	if old, err := ReadLock(root); err == nil {
+		for _, p := range old.Packages {
+			// ParseLock made sure it's inside the vendor directory
+			dir, _ := p.Path(root)
+
+			if err = os.RemoveAll(dir); err != nil {
+				return Lock{}, err
+			}
+		}
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return Lock{}, err
+	}
+
+	queue := []pendingRequirement{}
+	for _, r := range m.Requires {
+		queue = append(queue, pendingRequirement{req: r, from: root, by: m.Package})
+	}
+
+	type source struct{ path, by string }
+
+	sources := map[string]source{}
+	locked := []Locked{}
+
+	for len(queue) > 0 {
+		p := queue[0]
+		queue = queue[1:]
+
+		if p.req.Name == m.Package {
+			return Lock{}, fmt.Errorf("package '%s' cannot depend on itself (required by '%s')", m.Package, p.by)
+		}
+
+		src, err := locate(p.req, p.from, registry)
+		if err != nil {
+			return Lock{}, fmt.Errorf("%s (required by '%s')", err.Error(), p.by)
+		}
+
+		if existing, ok := sources[p.req.Name]; ok {
+			if existing.path != src {
+				return Lock{}, fmt.Errorf("package '%s' is required from both '%s' (by '%s') and '%s' (by '%s')", p.req.Name, existing.path, existing.by, src, p.by)
+			}
+
+			continue
+		}
+
+		sources[p.req.Name] = source{path: src, by: p.by}
+
+		dir := path.Join(VendorDir, p.req.Name)
+		dst := filepath.Join(root, filepath.FromSlash(dir))
+
+		if err = os.RemoveAll(dst); err != nil {
+			return Lock{}, err
+		} else if err = unpack(src, dst); err != nil {
+			return Lock{}, err
+		}
+
+		version := "0.0.0"
+		if !p.req.IsPath() {
+			version = p.req.Source
+		}
+
+		dep, ok, err := loadOptional(dst)
+		if err != nil {
+			return Lock{}, err
+		} else if ok {
+			if dep.Package != p.req.Name {
+				return Lock{}, fmt.Errorf("package '%s' (required by '%s') declares itself as '%s'", p.req.Name, p.by, dep.Package)
+			} else if p.req.IsPath() && dep.Version != "" {
+				version = dep.Version
+			}
+
+			from := src
+			if isArchive(src) {
+				from = filepath.Dir(src)
+			}
+
+			for _, r := range dep.Requires {
+				queue = append(queue, pendingRequirement{req: r, from: from, by: dep.Package})
+			}
+		}
+
+		sum, err := Sum(dst)
+		if err != nil {
+			return Lock{}, err
+		}
+
+		locked = append(locked, Locked{Name: p.req.Name, Version: version, Dir: dir, Sum: sum})
+	}
+
+	slices.SortFunc(locked, func(a, b Locked) int {
+		return strings.Compare(a.Name, b.Name)
+	})
+
+	l := Lock{Root: root, Packages: locked}
+
+	return l, l.Write()
+}
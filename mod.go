@@ -0,0 +1,37 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+
+	"github.com/voidwyrm-2/reqproc/manifest"
+)
+
+// reqproc mod [-registry dir] [project dir]
+func modCommand(args []string) error {
+	fs := flag.NewFlagSet("mod", flag.ContinueOnError)
+	registry := fs.String("registry", manifest.DefaultRegistry(), "The directory registry packages are read from")
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	dir := "."
+	if fs.NArg() == 1 {
+		dir = fs.Arg(0)
+	} else if fs.NArg() > 1 {
+		return errors.New("expected 'reqproc mod [-registry dir] [dir]'")
+	}
+
+	lock, err := manifest.Vendor(dir, *registry)
+	if err != nil {
+		return err
+	}
+
+	for _, p := range lock.Packages {
+		fmt.Println("vendored", p.Name, p.Version)
+	}
+
+	return nil
+}
@@ -0,0 +1,193 @@
+/*
+Package reqproc is the API for embedding ReqProc in Go programs
+
+	vm, err := reqproc.New()
+	if err != nil {
+		return err
+	}
+
+	vm.RegisterFunc("greet", func(name string) string {
+		return "hello, " + name
+	})
+
+	if _, err = vm.Execute(`(|1.1 greet) $welcome`); err != nil {
+		return err
+	}
+
+	results, err := vm.Call("welcome", "world")
+*/
+package reqproc
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/voidwyrm-2/reqproc/runtime/env"
+	"github.com/voidwyrm-2/reqproc/runtime/interpreter"
+	"github.com/voidwyrm-2/reqproc/runtime/scope"
+	"github.com/voidwyrm-2/reqproc/runtime/stack"
+	"github.com/voidwyrm-2/reqproc/runtime/types"
+	"github.com/voidwyrm-2/reqproc/runtime/types/functiontype"
+)
+
+/*
+A ReqProc interpreter with its own globals and stack
+
+values given to a VM are converted with types.FromGo, and the values it gives back can be converted with types.ToGo
+
+a VM must not be used from multiple goroutines at once
+*/
+type VM struct {
+	interp interpreter.Interpreter
+}
+
+func New() (*VM, error) {
+	interp, err := interpreter.New(scope.New(nil, map[string]types.ReqType{}))
+	if err != nil {
+		return nil, err
+	}
+
+	return &VM{interp: interp}, nil
+}
+
+// Returns the host settings of the VM, e.g. for setting the module search path or HTTP transport
+func (vm *VM) Env() *env.Env {
+	return vm.interp.GetEnv()
+}
+
+func (vm *VM) SetStdout(w io.Writer) {
+	vm.Env().Stdout = w
+}
+
+func (vm *VM) SetStderr(w io.Writer) {
+	vm.Env().Stderr = w
+}
+
+// Restricts what scripts run by the VM can do, see env.Sandbox; nil removes the restrictions
+func (vm *VM) SetSandbox(s *env.Sandbox) {
+	vm.interp.SetSandbox(s)
+}
+
+// Limits the resources each run of the VM can use (an Execute or Call), see env.Limits
+func (vm *VM) SetLimits(l env.Limits) {
+	vm.interp.SetLimits(l)
+}
+
+// Executes code, returning the contents of the stack afterwards; the stack is kept between calls
+func (vm *VM) Execute(text string) ([]types.ReqType, error) {
+	return vm.ExecuteContext(context.Background(), text)
+}
+
+func (vm *VM) ExecuteContext(ctx context.Context, text string) (result []types.ReqType, err error) {
+	err = vm.interp.Run(ctx, func() error {
+		result, err = vm.interp.Execute(text)
+		return err
+	})
+
+	return result, err
+}
+
+// Executes a file, which .req imports are then resolved relative to
+func (vm *VM) ExecuteFile(path string) ([]types.ReqType, error) {
+	return vm.ExecuteFileContext(context.Background(), path)
+}
+
+func (vm *VM) ExecuteFileContext(ctx context.Context, path string) ([]types.ReqType, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	} else if err = vm.interp.SetFile(path); err != nil {
+		return nil, err
+	}
+
+	return vm.ExecuteContext(ctx, string(content))
+}
+
+/*
+Makes a Go function callable from ReqProc by name
+
+its arguments are taken off the stack (the last argument being the top) and its results are pushed in order;
+if its last result is an error, it's returned as a ReqProc error instead of being pushed
+*/
+func (vm *VM) RegisterFunc(name string, fn any) error {
+	rft, err := functiontype.FromGo(fn)
+	if err != nil {
+		return fmt.Errorf("cannot register '%s': %s", name, err.Error())
+	}
+
+	return vm.interp.GetScope().WriteForeignConst(name, rft)
+}
+
+// Makes a module importable with `"name" import`; members are converted like the values given to SetGlobal
+func (vm *VM) RegisterModule(name string, members map[string]any) error {
+	converted := map[string]types.ReqType{}
+
+	for k, m := range members {
+		v, err := types.FromGo(m)
+		if err != nil {
+			return fmt.Errorf("member '%s' of module '%s': %s", k, name, err.Error())
+		}
+
+		converted[k] = v
+	}
+
+	vm.Env().RegisterModule(name, converted)
+
+	return nil
+}
+
+func (vm *VM) GetGlobal(name string) (types.ReqType, error) {
+	return vm.interp.GetScope().Read(name)
+}
+
+// Assigns a global variable, defining it if it doesn't exist
+func (vm *VM) SetGlobal(name string, value any) error {
+	v, err := types.FromGo(value)
+	if err != nil {
+		return err
+	}
+
+	sc := vm.interp.GetScope()
+
+	if err = sc.NameExists(name); err == nil {
+		return sc.Write(name, v)
+	} else if _, illegal := types.IllegalVariableNames[name]; illegal {
+		return err
+	}
+
+	return sc.Update(name, v)
+}
+
+// Calls a ReqProc function with the given arguments, returning the values it leaves
+func (vm *VM) Call(name string, args ...any) ([]types.ReqType, error) {
+	return vm.CallContext(context.Background(), name, args...)
+}
+
+func (vm *VM) CallContext(ctx context.Context, name string, args ...any) ([]types.ReqType, error) {
+	v, err := vm.GetGlobal(name)
+	if err != nil {
+		return nil, err
+	} else if v.Type() != types.TypeFunction {
+		return nil, fmt.Errorf("'%s' is not callable", name)
+	}
+
+	rft := v.(functiontype.ReqFunctionType)
+	if len(args) != len(rft.Input()) {
+		return nil, fmt.Errorf("'%s' takes %d arguments, but was given %d", name, len(rft.Input()), len(args))
+	}
+
+	values, err := types.FromGoAll(args...)
+	if err != nil {
+		return nil, err
+	}
+
+	st := stack.New(values...)
+
+	err = vm.interp.Run(ctx, func() error {
+		return interpreter.CallFunctionType(rft, vm.interp.GetScope(), &st, false)
+	})
+
+	return st.Slice(), err
+}
@@ -0,0 +1,128 @@
+package env
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+
+	"github.com/voidwyrm-2/reqproc/runtime/types"
+)
+
+/*
+Host-side settings shared by an interpreter and every interpreter created from it
+
+natives reach it through the scope they're called with, see scope.Scope.Env
+*/
+type Env struct {
+	// used by the web module; http.DefaultTransport is used if nil
+	Transport http.RoundTripper
+
+	// directories searched for .req modules after the importing file's directory; defaults to $REQPROC_PATH
+	SearchPath []string
+
+	// where io and other printing natives write to; os.Stdout and os.Stderr are used if nil
+	Stdout, Stderr io.Writer
+
+	// execution stops with its error once it's done; never cancelled if nil
+	Context context.Context
+
+	// restricts what scripts can do; nothing is restricted if nil
+	Sandbox *Sandbox
+
+	// limits on the resources scripts can use, see Limits
+	Limits Limits
+
+	// watches execution, e.g. to debug or trace it; it's an interpreter.Hook, set with Interpreter.SetHook
+	Hook any
+
+	instructions atomic.Int64
+
+	// the run the env was made for by Run, or nil
+	run *run
+
+	// what an env shares with the envs of its runs
+	*shared
+}
+
+type shared struct {
+	memory atomic.Int64
+
+	mu          sync.Mutex
+	modules     map[string]types.ReqType
+	hostModules map[string]map[string]types.ReqType
+}
+
+func New() *Env {
+	return &Env{
+		SearchPath: filepath.SplitList(os.Getenv("REQPROC_PATH")),
+		shared:     &shared{modules: map[string]types.ReqType{}, hostModules: map[string]map[string]types.ReqType{}},
+	}
+}
+
+func (e *Env) RoundTripper() http.RoundTripper {
+	if e == nil || e.Transport == nil {
+		return http.DefaultTransport
+	}
+
+	return e.Transport
+}
+
+func (e *Env) Out() io.Writer {
+	if e == nil || e.Stdout == nil {
+		return os.Stdout
+	}
+
+	return e.Stdout
+}
+
+func (e *Env) ErrOut() io.Writer {
+	if e == nil || e.Stderr == nil {
+		return os.Stderr
+	}
+
+	return e.Stderr
+}
+
+func (e *Env) Ctx() context.Context {
+	if e == nil || e.Context == nil {
+		return context.Background()
+	}
+
+	return e.Context
+}
+
+// Returns the module table cached for an absolute path by CacheModule
+func (e *Env) CachedModule(path string) (types.ReqType, bool) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	m, ok := e.modules[path]
+	return m, ok
+}
+
+func (e *Env) CacheModule(path string, module types.ReqType) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.modules[path] = module
+}
+
+// Makes a module provided by the host importable by name, like a standard library module; it takes precedence over one with the same name
+func (e *Env) RegisterModule(name string, members map[string]types.ReqType) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.hostModules[name] = members
+}
+
+func (e *Env) HostModule(name string) (map[string]types.ReqType, bool) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	m, ok := e.hostModules[name]
+	return m, ok
+}
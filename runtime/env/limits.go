@@ -0,0 +1,141 @@
+package env
+
+import (
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"github.com/voidwyrm-2/reqproc/runtime/stack"
+)
+
+type LimitKind int
+
+const (
+	InstructionLimit LimitKind = iota
+	StackLimit
+	CallDepthLimit
+	MemoryLimit
+	TimeLimit
+)
+
+func (k LimitKind) String() string {
+	switch k {
+	case InstructionLimit:
+		return "instruction"
+	case StackLimit:
+		return "stack size"
+	case CallDepthLimit:
+		return "call depth"
+	case MemoryLimit:
+		return "memory"
+	case TimeLimit:
+		return "time"
+	default:
+		return fmt.Sprintf("LimitKind(%d)", int(k))
+	}
+}
+
+/*
+Returned when a script goes over one of the limits of its env
+
+it can't be caught with try, and can be told apart with errors.Is, e.g. `errors.Is(err, env.ErrCallDepth)`
+*/
+type LimitError struct {
+	Kind  LimitKind
+	Limit int64
+}
+
+var (
+	ErrInstructions = LimitError{Kind: InstructionLimit}
+	ErrStackSize    = LimitError{Kind: StackLimit}
+	ErrCallDepth    = LimitError{Kind: CallDepthLimit}
+	ErrMemory       = LimitError{Kind: MemoryLimit}
+	ErrTimeout      = LimitError{Kind: TimeLimit}
+)
+
+func (e LimitError) Error() string {
+	if e.Kind == TimeLimit {
+		return fmt.Sprintf("%s limit of %s exceeded", e.Kind, time.Duration(e.Limit))
+	}
+
+	return fmt.Sprintf("%s limit of %d exceeded", e.Kind, e.Limit)
+}
+
+// limit errors match each other if they're of the same kind
+func (e LimitError) Is(target error) bool {
+	t, ok := target.(LimitError)
+	return ok && t.Kind == e.Kind
+}
+
+// Limits on what a script can use; zero means unlimited
+type Limits struct {
+	// the amount of tokens executed in a run, including those of called functions and tasks
+	Instructions int64
+
+	// the amount of values a stack can hold
+	StackSize int
+
+	// how deeply functions can call each other
+	CallDepth int
+
+	/*
+		the bytes held by the stacks and variables of the code at once, see stack.SizeOf; values count while they're on a
+		stack or held by a variable or constant, and the variables of a function stop counting once it returns
+	*/
+	Memory int64
+
+	// how long a run can take, see Env.Run
+	Timeout time.Duration
+}
+
+// Counts an executed instruction
+func (e *Env) Step() error {
+	if e == nil {
+		return nil
+	} else if n := e.instructions.Add(1); e.Limits.Instructions > 0 && n > e.Limits.Instructions {
+		return LimitError{Kind: InstructionLimit, Limit: e.Limits.Instructions}
+	}
+
+	return nil
+}
+
+// Counts the values on a stack against the memory limit until they're popped or the stack is released, if there's a limit
+func (e *Env) Track(st *stack.Stack) {
+	if usage := e.MemoryUsage(); usage != nil {
+		st.Track(usage)
+	}
+}
+
+// Returns what the values held by stacks and scopes add up to, for them to add to, or nil if there's no memory limit
+func (e *Env) MemoryUsage() *atomic.Int64 {
+	if e == nil || e.Limits.Memory <= 0 {
+		return nil
+	}
+
+	return &e.memory
+}
+
+// Checks the size of the values held by the tracked stacks and scopes against the memory limit
+func (e *Env) CheckMemory() error {
+	if e != nil && e.Limits.Memory > 0 && e.memory.Load() > e.Limits.Memory {
+		return LimitError{Kind: MemoryLimit, Limit: e.Limits.Memory}
+	}
+
+	return nil
+}
+
+func (e *Env) CheckStack(size int) error {
+	if e != nil && e.Limits.StackSize > 0 && size > e.Limits.StackSize {
+		return LimitError{Kind: StackLimit, Limit: int64(e.Limits.StackSize)}
+	}
+
+	return nil
+}
+
+func (e *Env) CheckDepth(depth int) error {
+	if e != nil && e.Limits.CallDepth > 0 && depth > e.Limits.CallDepth {
+		return LimitError{Kind: CallDepthLimit, Limit: int64(e.Limits.CallDepth)}
+	}
+
+	return nil
+}
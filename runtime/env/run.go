@@ -0,0 +1,69 @@
+package env
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+)
+
+type run struct {
+	cancel context.CancelFunc
+
+	// the run itself and whatever still holds it, see Hold
+	holds atomic.Int64
+}
+
+/*
+Returns an env for a single run of code (e.g. an Execute), which is stopped once ctx is done or the time limit is reached
+
+the run counts its instructions from zero, and has the same settings, module cache and memory usage as e;
+End must be called once the run is done, and its context is cancelled once that's been called and every Hold released
+*/
+func (e *Env) Run(ctx context.Context) *Env {
+	r := &run{cancel: func() {}}
+	r.holds.Store(1)
+
+	if e.Limits.Timeout > 0 {
+		ctx, r.cancel = context.WithTimeoutCause(ctx, e.Limits.Timeout, LimitError{Kind: TimeLimit, Limit: int64(e.Limits.Timeout)})
+	}
+
+	return &Env{
+		Transport:  e.Transport,
+		SearchPath: e.SearchPath,
+		Stdout:     e.Stdout,
+		Stderr:     e.Stderr,
+		Context:    ctx,
+		Sandbox:    e.Sandbox,
+		Limits:     e.Limits,
+		Hook:       e.Hook,
+		run:        r,
+		shared:     e.shared,
+	}
+}
+
+// Reports whether the env was made by Run
+func (e *Env) Running() bool {
+	return e != nil && e.run != nil
+}
+
+// Keeps the context of the run going after End is called until release is, for tasks which outlive the run
+func (e *Env) Hold() (release func()) {
+	if !e.Running() {
+		return func() {}
+	}
+
+	e.run.holds.Add(1)
+
+	var once sync.Once
+
+	return func() {
+		once.Do(e.End)
+	}
+}
+
+// Marks the run as done
+func (e *Env) End() {
+	if e.Running() && e.run.holds.Add(-1) == 0 {
+		e.run.cancel()
+	}
+}
@@ -0,0 +1,177 @@
+package env
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"net"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// Returned when a sandboxed script does something it wasn't allowed to; it can be caught with try like any other error
+type PermissionError struct {
+	msg string
+}
+
+func (e PermissionError) Error() string {
+	return "permission denied: " + e.msg
+}
+
+func denied(format string, a ...any) error {
+	return PermissionError{msg: fmt.Sprintf(format, a...)}
+}
+
+/*
+Restricts what scripts can do, for running code that isn't trusted
+
+everything not granted here is denied, so the zero value only allows pure computation (and the host's own modules and functions)
+*/
+type Sandbox struct {
+	// standard library modules which can be imported
+	Modules []string
+
+	// whether .req files can be imported
+	FileImports bool
+
+	// the directory io and os.fs are confined to; paths are resolved inside it, even absolute ones, and the filesystem can't be used at all if it's empty
+	FSRoot string
+
+	// hosts which web and net can connect to, either "host" for any port, "host:port", "*.domain" for its subdomains, or "*" for anything
+	Hosts []string
+
+	// whether web.serve, net.listen and net.listenPacket can be used
+	Listen bool
+}
+
+// Returns the sandbox, which is nil if the env isn't sandboxed
+func (e *Env) GetSandbox() *Sandbox {
+	if e == nil {
+		return nil
+	}
+
+	return e.Sandbox
+}
+
+func (s *Sandbox) CheckModule(name string) error {
+	if s == nil || slices.Contains(s.Modules, name) {
+		return nil
+	}
+
+	return denied("importing module '%s' is not allowed", name)
+}
+
+func (s *Sandbox) CheckFileImport(name string) error {
+	if s == nil || s.FileImports {
+		return nil
+	}
+
+	return denied("importing '%s' is not allowed, .req imports are disabled", name)
+}
+
+func (s *Sandbox) CheckListen(addr string) error {
+	if s == nil || s.Listen {
+		return nil
+	}
+
+	return denied("listening on '%s' is not allowed", addr)
+}
+
+func hostMatches(pattern, host, port string) bool {
+	if pattern == "*" {
+		return true
+	}
+
+	pHost, pPort, err := net.SplitHostPort(pattern)
+	if err != nil {
+		pHost, pPort = pattern, ""
+	}
+
+	if pPort != "" && pPort != port {
+		return false
+	}
+
+	if suffix, ok := strings.CutPrefix(pHost, "*."); ok {
+		return strings.HasSuffix(strings.ToLower(host), "."+strings.ToLower(suffix))
+	}
+
+	return strings.EqualFold(pHost, host)
+}
+
+// Checks a "host:port" address against the allowed hosts
+func (s *Sandbox) CheckHost(addr string) error {
+	if s == nil {
+		return nil
+	}
+
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		host, port = addr, ""
+	}
+
+	for _, pattern := range s.Hosts {
+		if hostMatches(pattern, host, port) {
+			return nil
+		}
+	}
+
+	return denied("connecting to '%s' is not allowed", addr)
+}
+
+// resolves the symlinks in the part of p which exists
+func resolveExisting(p string) (string, error) {
+	rest := []string{}
+
+	for {
+		resolved, err := filepath.EvalSymlinks(p)
+		if err == nil {
+			return filepath.Join(append([]string{resolved}, rest...)...), nil
+		} else if !errors.Is(err, fs.ErrNotExist) {
+			return "", err
+		}
+
+		parent := filepath.Dir(p)
+		if parent == p {
+			return p, nil
+		}
+
+		rest = append([]string{filepath.Base(p)}, rest...)
+		p = parent
+	}
+}
+
+/*
+Returns where a path given by a script is inside the filesystem root
+
+symlinks are followed, so a link inside the root can't be used to reach something outside of it
+*/
+func (s *Sandbox) Path(p string) (string, error) {
+	if s == nil {
+		return p, nil
+	} else if s.FSRoot == "" {
+		return "", denied("accessing '%s' is not allowed, the filesystem is disabled", p)
+	}
+
+	root, err := filepath.Abs(s.FSRoot)
+	if err != nil {
+		return "", err
+	} else if root, err = filepath.EvalSymlinks(root); err != nil {
+		return "", err
+	}
+
+	// cleaning it as an absolute path removes any leading '..'
+	joined := filepath.Join(root, filepath.Clean(string(os.PathSeparator)+p))
+
+	resolved, err := resolveExisting(joined)
+	if err != nil {
+		return "", err
+	}
+
+	if rel, err := filepath.Rel(root, resolved); err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(os.PathSeparator)) {
+		return "", denied("'%s' is outside of the filesystem root", p)
+	}
+
+	return resolved, nil
+}
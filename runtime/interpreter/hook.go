@@ -0,0 +1,64 @@
+package interpreter
+
+import (
+	"github.com/voidwyrm-2/reqproc/lexer/tokens"
+	"github.com/voidwyrm-2/reqproc/runtime/env"
+	"github.com/voidwyrm-2/reqproc/runtime/stack"
+	"github.com/voidwyrm-2/reqproc/runtime/types/functiontype"
+)
+
+/*
+Watches code being executed, e.g. to debug or trace it
+
+it's called from whichever goroutine runs the code, including tasks
+*/
+type Hook interface {
+	// called before a token is executed; returning an error stops the code with it
+	Before(s Step) error
+
+	// called after a token is executed, with the error it stopped the code with if it did
+	After(s Step, err error)
+
+	// called when a function is called, before its inputs are checked
+	Enter(c Call)
+
+	// called when a function returns, with its error if it failed; a tail call returns before calling the next function
+	Exit(c Call, err error)
+}
+
+// A token being executed
+type Step struct {
+	Token tokens.Token
+
+	// how many function calls deep the token is, 0 being the code which was executed
+	Depth int
+
+	// the interpreter executing the token, whose stack and scope can be inspected, but shouldn't be changed
+	Interpreter *Interpreter
+}
+
+// A function being called
+type Call struct {
+	Function functiontype.ReqFunctionType
+
+	// the depth of the tokens the function executes
+	Depth int
+
+	// the stack the function is called on
+	Stack *stack.Stack
+}
+
+// Sets the hook which watches code run by the interpreter, and any interpreters, tasks and modules created from it; nil removes it
+func (i *Interpreter) SetHook(h Hook) {
+	i.env.Hook = h
+}
+
+func hookOf(e *env.Env) Hook {
+	if e == nil {
+		return nil
+	}
+
+	h, _ := e.Hook.(Hook)
+
+	return h
+}
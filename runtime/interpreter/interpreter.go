@@ -1,15 +1,16 @@
 package interpreter
 
 import (
+	"context"
+	"errors"
 	"fmt"
-	"io"
-	"os"
-	"path"
-	"strconv"
+	"net/http"
+	"slices"
 	"strings"
 
 	"github.com/voidwyrm-2/reqproc/lexer"
 	"github.com/voidwyrm-2/reqproc/lexer/tokens"
+	"github.com/voidwyrm-2/reqproc/runtime/env"
 	"github.com/voidwyrm-2/reqproc/runtime/scope"
 	"github.com/voidwyrm-2/reqproc/runtime/stack"
 	"github.com/voidwyrm-2/reqproc/runtime/stdlib"
@@ -18,9 +19,21 @@ import (
 	"github.com/voidwyrm-2/reqproc/runtime/types/listtype"
 	"github.com/voidwyrm-2/reqproc/runtime/types/numbertype"
 	"github.com/voidwyrm-2/reqproc/runtime/types/stringtype"
-	"github.com/voidwyrm-2/reqproc/runtime/types/tabletype"
 )
 
+func init() {
+	// Go functions made by types.ToGo without a Caller run ReqProc functions in a scope of their own
+	types.Constructors.Call = func(fn types.ReqType, args []types.ReqType) ([]types.ReqType, error) {
+		st := stack.New(args...)
+
+		if err := CallFunctionType(fn.(functiontype.ReqFunctionType), scope.New(nil, map[string]types.ReqType{}), &st, true); err != nil {
+			return nil, err
+		}
+
+		return st.Slice(), nil
+	}
+}
+
 func expectKindsForwardInTokens(it int, toks []tokens.Token, kinds ...tokens.TokenKind) error {
 	if len(kinds) == 0 {
 		return nil
@@ -38,49 +51,377 @@ func expectKindsForwardInTokens(it int, toks []tokens.Token, kinds ...tokens.Tok
 }
 
 func CallFunctionType(rft functiontype.ReqFunctionType, sc *scope.Scope, st *stack.Stack, sameStack bool) error {
+	return callFunction(rft, sc, st, sameStack, 0)
+}
+
+// calls rft from a function call depth deep
+func callFunction(rft functiontype.ReqFunctionType, sc *scope.Scope, st *stack.Stack, sameStack bool, depth int) (err error) {
+	// rft changes with tail calls, so the function which returns is the one that was called last
+	hook := hookOf(sc.Env())
+	if hook != nil {
+		calls := depth + 1
+		hook.Enter(Call{Function: rft, Depth: calls, Stack: st})
+
+		defer func() {
+			hook.Exit(Call{Function: rft, Depth: calls, Stack: st}, err)
+		}()
+	}
+
 	if err := st.Expect(rft.Input()...); err != nil {
 		return err
 	}
 
+	e := sc.Env()
+
+	depth++
+	if err := e.CheckDepth(depth); err != nil {
+		return err
+	}
+
 	lit := rft.Literal()
 
+	// what's left above this is what the function made
+	base := st.Len() - len(rft.Input())
+
+	if parts, ok := lit.([]functiontype.Part); ok {
+		return callComposite(rft, parts, sc, st, sameStack, depth)
+	}
+
 	if fn, ok := lit.(functiontype.NativeFunction); ok {
-		return fn(sc, st, func(rft functiontype.ReqFunctionType, sc *scope.Scope, st *stack.Stack) error {
-			return CallFunctionType(rft, sc, st, true)
+		err := fn(sc, st, func(rft functiontype.ReqFunctionType, sc *scope.Scope, st *stack.Stack) error {
+			return callFunction(rft, sc, st, true, depth)
 		})
+		if err != nil {
+			return err
+		} else if err = checkArity(rft, st.Len()-base); err != nil {
+			return err
+		}
+
+		return e.CheckMemory()
+	}
+
+	if sameStack {
+		interp, err := newFrame(rft, sc, depth)
+		if err != nil {
+			return err
+		}
+
+		interp.stack = st
+		defer interp.scope.Release()
+
+		if err = interp.bindParams(rft); err != nil {
+			return err
+		}
+
+		if _, err = interp.ExecuteTokens(lit.([]tokens.Token)); err != nil {
+			return err
+		} else if err = checkArity(rft, st.Len()-base); err != nil {
+			return err
+		}
+
+		return checkOutput(rft, st)
+	}
+
+	// the function only gets its inputs, and hands back what it leaves on its own stack
+	args := st.PopN(len(rft.Input()))
+
+	/*
+		calls in tail position are handed back here instead of being made by the function, so that recursive loops don't
+		use up the Go stack; the calls which made them are only kept if they still have checks left to do, and count
+		towards the call depth if they are
+	*/
+	pending := []tailFrame{}
+
+	for {
+		interp, err := newFrame(rft, sc, depth)
+		if err != nil {
+			return err
+		}
+
+		interp.tail = true
+		interp.stack.Push(args...)
+
+		if err = interp.bindParams(rft); err != nil {
+			return err
+		}
+
+		res, err := interp.ExecuteTokens(rft.Literal().([]tokens.Token))
+
+		// what's left is handed back to the caller's stack, where it's counted again, and the function's variables are gone
+		interp.stack.Release()
+		interp.scope.Release()
+
+		if err != nil {
+			st.Push(unwindTailFrames(pending, res)...)
+			return err
+		}
+
+		next := interp.tailCall
+		if next == nil {
+			if err = checkArity(rft, len(res)); err != nil {
+				return err
+			} else if err = checkOutput(rft, interp.stack); err != nil {
+				return err
+			}
+
+			for k := len(pending) - 1; k > -1; k-- {
+				res = append(append([]types.ReqType{}, pending[k].below...), res...)
+				out := stack.New(res...)
+
+				if err = checkArity(pending[k].rft, len(res)); err != nil {
+					return err
+				} else if err = checkOutput(pending[k].rft, &out); err != nil {
+					return err
+				}
+			}
+
+			st.Push(res...)
+
+			return nil
+		}
+
+		split := len(res) - len(next.Input())
+
+		if split > 0 || !checkedBy(rft, *next) {
+			pending = append(pending, tailFrame{rft: rft, below: res[:split]})
+
+			// kept calls are still waiting on the ones they made, like calls that aren't in tail position
+			if err = e.CheckDepth(depth + len(pending)); err != nil {
+				st.Push(unwindTailFrames(pending, res[split:])...)
+				return err
+			}
+		}
+
+		// the caller's stack doesn't have what's passed between tail calls, so the hook is shown the values themselves
+		if hook != nil {
+			left, passed := stack.New(res...), stack.New(res[split:]...)
+
+			hook.Exit(Call{Function: rft, Depth: depth, Stack: &left}, nil)
+			hook.Enter(Call{Function: *next, Depth: depth, Stack: &passed})
+		}
+
+		rft, sc, args = *next, interp.scope, res[split:]
+	}
+}
+
+// reports whether the checks on what a tail call leaves are the same as the ones on what the call which made it does
+func checkedBy(rft, next functiontype.ReqFunctionType) bool {
+	if rft.Variadic() != next.Variadic() || rft.Arity().Out != next.Arity().Out {
+		return false
 	}
 
-	interp, err := New(sc)
+	return !rft.Typed() || next.Typed() && slices.Equal(rft.Output(), next.Output())
+}
+
+// a call which made a tail call, and what it had under the tail call's inputs
+type tailFrame struct {
+	rft   functiontype.ReqFunctionType
+	below []types.ReqType
+}
+
+// what the caller of a chain of tail calls would have been left with
+func unwindTailFrames(pending []tailFrame, res []types.ReqType) []types.ReqType {
+	for k := len(pending) - 1; k > -1; k-- {
+		res = append(append([]types.ReqType{}, pending[k].below...), res...)
+	}
+
+	return res
+}
+
+// creates the interpreter a ReqProc function runs in
+func newFrame(rft functiontype.ReqFunctionType, sc *scope.Scope, depth int) (Interpreter, error) {
+	// functions run in the scope they were defined in, not the one they're called from
+	parent := sc
+	if closure := rft.Scope(); closure != nil {
+		parent = closure
+
+		// tasks can't reassign anything outside of themselves through the functions they call
+		if sc.Isolated() && !closure.Isolated() {
+			parent = scope.NewIsolated(closure)
+		}
+	}
+
+	interp, err := New(parent)
 	if err != nil {
+		return Interpreter{}, err
+	}
+
+	interp.depth = depth
+
+	// functions are part of the run they're called in, wherever they were defined
+	if e := sc.Env(); e != nil && e != interp.env {
+		interp.env = e
+		interp.scope.SetEnv(e)
+		e.Track(interp.stack)
+		interp.scope.Track(e.MemoryUsage())
+	}
+
+	// a function can always call itself by the name it was given, unless one of its inputs has that name
+	if name := rft.Name(); name != "" && !slices.Contains(rft.Params(), name) {
+		if err = interp.scope.WriteForeignConst(name, rft); err != nil {
+			return Interpreter{}, err
+		}
+	}
+
+	return interp, nil
+}
+
+// how many bytes setting index of indexable to item adds to it, see stack.SizeOf
+func growth(indexable, index, item types.ReqType) int64 {
+	size := stack.SizeOf(item)
+
+	if old, err := indexable.GetIndex(index); err == nil {
+		size -= stack.SizeOf(old)
+	} else if key, ok := index.Literal().(string); ok {
+		size += int64(len(key))
+	}
+
+	return size
+}
+
+// gives a ReqProc function the name of the constant it's first assigned to, which it can always call itself by
+func nameFunction(v types.ReqType, name string) types.ReqType {
+	if fn, ok := v.(functiontype.ReqFunctionType); ok && fn.Name() == "" {
+		if _, isReqProc := fn.Literal().([]tokens.Token); isReqProc {
+			return fn.SetName(name)
+		}
+	}
+
+	return v
+}
+
+// calls a composite function made by combinators like curry and compose
+func callComposite(rft functiontype.ReqFunctionType, parts []functiontype.Part, sc *scope.Scope, st *stack.Stack, sameStack bool, depth int) error {
+	work, base := st, st.Len()-len(rft.Input())
+
+	if !sameStack {
+		sub := stack.New(st.PopN(len(rft.Input()))...)
+		work, base = &sub, 0
+
+		sc.Env().Track(work)
+		defer work.Release()
+	}
+
+	for _, p := range parts {
+		if !p.Call {
+			work.Push(p.Value)
+		} else if err := callFunction(p.Value.(functiontype.ReqFunctionType), sc, work, true, depth); err != nil {
+			if !sameStack {
+				st.Push(work.Slice()...)
+			}
+
+			return err
+		}
+	}
+
+	if err := checkArity(rft, work.Len()-base); err != nil {
 		return err
 	}
 
-	if sameStack {
-		interp.stack = st
+	if !sameStack {
+		st.Push(work.Slice()...)
 	}
 
-	res, err := interp.ExecuteTokens(lit.([]tokens.Token))
-	st.Push(res...)
+	return nil
+}
+
+// reports whether calling fn at toks[it] is the last thing the function being run does, so the call can be made by its caller instead
+func (i *Interpreter) isTailCall(fn functiontype.ReqFunctionType, toks []tokens.Token, it int) bool {
+	/*
+		calls made while trying have their errors caught by this function, so they have to be made from it; only ReqProc
+		functions can be tail called, since natives and composite functions (fn.Parts) aren't run by an interpreter, and
+		a call without enough inputs fails here, where its error points at
+	*/
+	if !i.tail || i.modeTry || fn.Parts() != nil {
+		return false
+	} else if _, isReqProc := fn.Literal().([]tokens.Token); !isReqProc || i.stack.Expect(fn.Input()...) != nil {
+		return false
+	}
+
+	for _, t := range toks[it+1:] {
+		if !t.Iskind(tokens.Label) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// pops the inputs a function named into constants of the scope it runs in
+func (i *Interpreter) bindParams(rft functiontype.ReqFunctionType) error {
+	params := rft.Params()
+	if len(params) == 0 {
+		return nil
+	}
+
+	for n, v := range i.stack.PopN(len(params)) {
+		if err := i.scope.WriteConst(params[n], v); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// checks that a function left as many values as its signature says, left being negative if it took more than its inputs
+func checkArity(rft functiontype.ReqFunctionType, left int) error {
+	arity := rft.Arity()
+
+	if rft.Variadic() {
+		return nil
+	} else if left < 0 {
+		return fmt.Errorf("function declared |%s but took %d values", arity, arity.In-left)
+	} else if left != arity.Out {
+		return fmt.Errorf("function declared |%s but left %d values", arity, left)
+	}
+
+	return nil
+}
+
+// checks what a function with a typed signature left on the stack
+func checkOutput(rft functiontype.ReqFunctionType, st *stack.Stack) error {
+	if !rft.Typed() {
+		return nil
+	} else if err := st.Expect(rft.Output()...); err != nil {
+		return fmt.Errorf("invalid output for %s: %w", rft.String(), err)
+	}
 
-	return err
+	return nil
+}
+
+// errors which try doesn't catch, so they stop the whole program
+func isUncatchable(err error) bool {
+	return strings.HasPrefix(err.Error(), "EXIT CODE ") || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) || errors.As(err, &env.LimitError{})
 }
 
 type Interpreter struct {
+	env     *env.Env
 	scope   *scope.Scope
 	stack   *stack.Stack
 	modeTry bool
 	err     string
+	depth   int
+
+	// whether calls in tail position are handed back to the caller through tailCall, see callFunction
+	tail     bool
+	tailCall *functiontype.ReqFunctionType
 }
 
 func New(parentScope *scope.Scope) (Interpreter, error) {
 	st := stack.New()
 	i := Interpreter{scope: scope.New(parentScope, map[string]types.ReqType{}), stack: &st, err: "", modeTry: false}
 
-	err := i.scope.LoadAllConst(stdlib.Stdlib["__init__"])
-	if err != nil {
-		return Interpreter{}, err
+	if i.scope.Env() == nil {
+		i.scope.SetEnv(env.New())
 	}
 
+	i.env = i.scope.Env()
+	i.env.Track(i.stack)
+	i.scope.Track(i.env.MemoryUsage())
+
+	// the stdlib is shared rather than copied, since every function call makes a new interpreter
+	i.scope.SetBuiltins(stdlib.Stdlib["__init__"])
+
 	return i, nil
 }
 
@@ -88,6 +429,48 @@ func (i Interpreter) GetScope() *scope.Scope {
 	return i.scope
 }
 
+func (i Interpreter) GetEnv() *env.Env {
+	return i.env
+}
+
+// Sets the RoundTripper used by the web module, mostly useful for testing against a httptest.Server
+func (i *Interpreter) SetTransport(rt http.RoundTripper) {
+	i.env.Transport = rt
+}
+
+// Limits the resources each run of the interpreter can use, see Run
+func (i *Interpreter) SetLimits(l env.Limits) {
+	i.env.Limits = l
+	i.env.Track(i.stack)
+	i.scope.Track(i.env.MemoryUsage())
+}
+
+/*
+Calls fn as a single run of the interpreter, which stops once ctx is done or the time limit is reached, see env.Env.Run
+
+ExecuteTokens starts a run with the context of the env itself when it isn't called in one
+*/
+func (i *Interpreter) Run(ctx context.Context, fn func() error) error {
+	prev := i.env
+	run := prev.Run(ctx)
+	defer run.End()
+
+	i.env = run
+	i.scope.SetEnv(run)
+
+	defer func() {
+		i.env = prev
+		i.scope.SetEnv(prev)
+	}()
+
+	return fn()
+}
+
+// Restricts what code run by the interpreter (and any interpreters, tasks and modules created from it) can do
+func (i *Interpreter) SetSandbox(s *env.Sandbox) {
+	i.env.Sandbox = s
+}
+
 func (i Interpreter) GetStack() stack.Stack {
 	return *i.stack
 }
@@ -124,7 +507,17 @@ func (i *Interpreter) SetModeTry(m bool) {
 	i.modeTry = m
 }
 
-func (i *Interpreter) ExecuteTokens(toks []tokens.Token) ([]types.ReqType, error) {
+// Executes tokens, as a run of their own (see Run) unless they're executed as part of one, e.g. by a function
+func (i *Interpreter) ExecuteTokens(toks []tokens.Token) (result []types.ReqType, err error) {
+	if !i.env.Running() {
+		err = i.Run(i.env.Ctx(), func() error {
+			result, err = i.ExecuteTokens(toks)
+			return err
+		})
+
+		return result, err
+	}
+
 	it := 0
 
 	labels := map[string]int{}
@@ -139,8 +532,43 @@ func (i *Interpreter) ExecuteTokens(toks []tokens.Token) ([]types.ReqType, error
 		}
 	}
 
+	ctx := i.env.Ctx()
+
+	// the token the hook was told about last, which is done once the next one starts or the tokens stop
+	hook := hookOf(i.env)
+	var step *Step
+
+	if hook != nil {
+		defer func() {
+			if step != nil {
+				hook.After(*step, err)
+			}
+		}()
+	}
+
 	for it < len(toks) {
 		cur := toks[it]
+
+		if ctx.Err() != nil {
+			return []types.ReqType{}, cur.Err(context.Cause(ctx))
+		} else if err := i.env.Step(); err != nil {
+			return []types.ReqType{}, cur.Err(err)
+		} else if err = i.env.CheckStack(i.stack.Len()); err != nil {
+			return []types.ReqType{}, cur.Err(err)
+		}
+
+		if hook != nil {
+			if step != nil {
+				hook.After(*step, nil)
+			}
+
+			step = &Step{Token: cur, Depth: i.depth, Interpreter: i}
+
+			if err := hook.Before(*step); err != nil {
+				return []types.ReqType{}, cur.Err(err)
+			}
+		}
+
 		next := tokens.Token{}
 		if it+1 < len(toks) {
 			next = toks[it+1]
@@ -182,6 +610,11 @@ func (i *Interpreter) ExecuteTokens(toks []tokens.Token) ([]types.ReqType, error
 			it++
 		case tokens.String:
 			i.stack.Push(stringtype.New(cur.Lit()))
+
+			if err := i.env.CheckMemory(); err != nil {
+				return []types.ReqType{}, cur.Err(err)
+			}
+
 			it++
 		case tokens.Number:
 			if val, err := numbertype.FromString(toks[it].Lit()); err != nil {
@@ -199,6 +632,13 @@ func (i *Interpreter) ExecuteTokens(toks []tokens.Token) ([]types.ReqType, error
 					return []types.ReqType{}, next.Err(err)
 				}
 				it += 2
+			case "export":
+				if err := expectKindsForward(tokens.Ident); err != nil {
+					return []types.ReqType{}, cur.Err(err)
+				} else if err = i.scope.Export(next.Lit()); err != nil {
+					return []types.ReqType{}, next.Err(err)
+				}
+				it += 2
 			case "err":
 				if err := expectKindsForward(tokens.Ident); err != nil {
 					return []types.ReqType{}, cur.Err(err)
@@ -224,52 +664,26 @@ func (i *Interpreter) ExecuteTokens(toks []tokens.Token) ([]types.ReqType, error
 			case "import":
 				if err := i.stack.Expect(types.TypeString); err != nil {
 					return []types.ReqType{}, cur.Err(err)
-				} else {
-					modname := i.stack.Pop().Literal().(string)
-
-					if path.Ext(modname) == ".req" {
-						err := func() error {
-							mod, err := os.Open(modname)
-							defer mod.Close()
-							if err != nil {
-								return err
-							}
-
-							content, err := io.ReadAll(mod)
-							if err != nil {
-								return err
-							}
-
-							interp, err := New(nil)
-							if err != nil {
-								return err
-							}
-
-							_, err = interp.Execute(string(content))
-							if err != nil {
-								return err
-							}
-
-							return i.scope.WriteConst(modname, tabletype.New(interp.scope.Consts()))
-						}()
-						if err != nil {
-							if i.modeTry {
-								i.err = cur.Err(err).Error()
-							} else {
-								return []types.ReqType{}, err
-							}
-						}
+				}
+
+				alias, only, consumed, err := parseImportBinding(toks, it)
+				if err != nil {
+					return []types.ReqType{}, err
+				}
+
+				modname := i.stack.Pop().Literal().(string)
+
+				if mod, err := i.loadModule(modname); err != nil {
+					if i.modeTry && !isUncatchable(err) {
+						i.err = cur.Err(err).Error()
 					} else {
-						if mod, ok := stdlib.Stdlib[modname]; ok && !strings.HasPrefix(modname, "__") {
-							if err := i.scope.WriteConst(modname, tabletype.New(mod)); err != nil {
-								return []types.ReqType{}, cur.Err(err)
-							}
-						} else {
-							return []types.ReqType{}, cur.Errf("module '%s' does not exist in the standard library", modname)
-						}
+						return []types.ReqType{}, cur.Err(err)
 					}
+				} else if err = i.bindModule(modname, mod, alias, only); err != nil {
+					return []types.ReqType{}, cur.Err(err)
 				}
-				it++
+
+				it += 1 + consumed
 			case "try":
 				i.modeTry = true
 				it++
@@ -281,9 +695,12 @@ func (i *Interpreter) ExecuteTokens(toks []tokens.Token) ([]types.ReqType, error
 					return []types.ReqType{}, cur.Err(err)
 				} else if v.Type() != types.TypeFunction { // can we call it?
 					return []types.ReqType{}, cur.Errf("'%s' is not callable", v.Type().String())
+				} else if fn := v.(functiontype.ReqFunctionType); i.isTailCall(fn, toks, it) {
+					i.tailCall = &fn
+					return i.stack.Slice(), nil
 				} else { // all good, let's call it
-					if err := CallFunctionType(v.(functiontype.ReqFunctionType), i.scope, i.stack, false); err != nil {
-						if strings.HasPrefix(err.Error(), "EXIT CODE ") { // special handling for the exit function
+					if err := callFunction(v.(functiontype.ReqFunctionType), i.scope, i.stack, false, i.depth); err != nil {
+						if isUncatchable(err) { // exit and cancellation aren't caught by try
 							return []types.ReqType{}, err
 						}
 
@@ -336,18 +753,29 @@ func (i *Interpreter) ExecuteTokens(toks []tokens.Token) ([]types.ReqType, error
 			} else {
 				item, index, indexable := i.stack.Pop(), i.stack.Pop(), i.stack.Pop()
 
+				grown := int64(0)
+				if i.env.MemoryUsage() != nil {
+					grown = growth(indexable, index, item)
+				}
+
 				err := indexable.SetIndex(index, item)
 				if err != nil {
 					return []types.ReqType{}, cur.Err(err)
 				}
 
+				// the variables holding the list or table were counted with the size it had before
+				i.scope.Grow(indexable, grown)
 				i.stack.Push(indexable)
+
+				if err = i.env.CheckMemory(); err != nil {
+					return []types.ReqType{}, cur.Err(err)
+				}
 			}
 			it++
 		case tokens.Const:
 			if err := i.stack.Expect(types.TypeAny); err != nil {
 				return []types.ReqType{}, cur.Err(err)
-			} else if err = i.scope.WriteConst(cur.Lit(), i.stack.Pop()); err != nil {
+			} else if err = i.scope.WriteConst(cur.Lit(), nameFunction(i.stack.Pop(), cur.Lit())); err != nil {
 				return []types.ReqType{}, cur.Err(err)
 			}
 			it++
@@ -363,16 +791,12 @@ func (i *Interpreter) ExecuteTokens(toks []tokens.Token) ([]types.ReqType, error
 					return []types.ReqType{}, err
 				}
 			} else {
-				if err = expectKindsForwardInTokens(-1, fcontent, tokens.Signature); err != nil {
-					return []types.ReqType{}, err
-				}
-
-				sig, err := strconv.ParseFloat(fcontent[0].Lit(), 32)
+				rft, err := functiontype.Parse(fcontent)
 				if err != nil {
-					return []types.ReqType{}, cur.Err(err)
+					return []types.ReqType{}, err
 				}
 
-				i.stack.Push(functiontype.New(fcontent[1:], float32(sig)))
+				i.stack.Push(rft.SetScope(i.scope))
 
 				it += len(fcontent) + 2
 			}
@@ -409,6 +833,11 @@ func (i *Interpreter) ExecuteTokens(toks []tokens.Token) ([]types.ReqType, error
 				}
 
 				i.stack.Push(listtype.New(list...))
+
+				if err := i.env.CheckMemory(); err != nil {
+					return []types.ReqType{}, cur.Err(err)
+				}
+
 				it += len(lcontent) + 2
 			}
 		default:
@@ -416,6 +845,10 @@ func (i *Interpreter) ExecuteTokens(toks []tokens.Token) ([]types.ReqType, error
 		}
 	}
 
+	if err := i.env.CheckStack(i.stack.Len()); err != nil && len(toks) > 0 {
+		return []types.ReqType{}, toks[len(toks)-1].Err(err)
+	}
+
 	return i.stack.Slice(), nil
 }
 
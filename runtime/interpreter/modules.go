@@ -0,0 +1,228 @@
+package interpreter
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/voidwyrm-2/reqproc/lexer/tokens"
+	"github.com/voidwyrm-2/reqproc/manifest"
+	"github.com/voidwyrm-2/reqproc/runtime/scope"
+	"github.com/voidwyrm-2/reqproc/runtime/stdlib"
+	"github.com/voidwyrm-2/reqproc/runtime/types"
+	"github.com/voidwyrm-2/reqproc/runtime/types/tabletype"
+)
+
+func isFileModule(name string) bool {
+	return filepath.Ext(name) == ".req"
+}
+
+func isFile(p string) bool {
+	info, err := os.Stat(p)
+	return err == nil && !info.IsDir()
+}
+
+/*
+Finds the file a .req module refers to
+
+absolute paths are used as-is, relative ones are looked for next to the importing file (or in the working directory if there isn't one),
+then in the packages of the closest reqproc.lock (as "<package>/<file>.req"), then in each directory of the search path
+*/
+func resolveModule(name, importer string, searchPath []string) (string, error) {
+	if filepath.IsAbs(name) {
+		return filepath.Clean(name), nil
+	}
+
+	dir := "."
+	if importer != "" {
+		dir = filepath.Dir(importer)
+	}
+
+	if candidate, err := filepath.Abs(filepath.Join(dir, name)); err != nil {
+		return "", err
+	} else if isFile(candidate) {
+		return candidate, nil
+	}
+
+	if lock, ok, err := manifest.FindLock(dir); err != nil {
+		return "", err
+	} else if ok {
+		if candidate, ok, err := lock.Resolve(name); err != nil {
+			return "", err
+		} else if ok {
+			if !isFile(candidate) {
+				return "", fmt.Errorf("cannot find module '%s' in the vendored packages of '%s'", name, filepath.Join(lock.Root, manifest.LockName))
+			}
+
+			return candidate, nil
+		}
+	}
+
+	for _, dir := range searchPath {
+		if candidate, err := filepath.Abs(filepath.Join(dir, name)); err != nil {
+			return "", err
+		} else if isFile(candidate) {
+			return candidate, nil
+		}
+	}
+
+	return "", fmt.Errorf("cannot find module '%s' (searched %s)", name, strings.Join(append([]string{dir}, searchPath...), ", "))
+}
+
+// the name a module is bound to when it's imported without 'as'
+func defaultModuleName(name string) string {
+	if isFileModule(name) {
+		return strings.TrimSuffix(filepath.Base(name), ".req")
+	}
+
+	return name
+}
+
+/*
+Parses what follows an import, which is either nothing, `as name`, or `only ["name" ...]`
+
+it is the index of the import token; returns how many tokens after it were used
+*/
+func parseImportBinding(toks []tokens.Token, it int) (alias string, only []string, consumed int, err error) {
+	if it+1 >= len(toks) || !toks[it+1].Iskind(tokens.Ident) {
+		return "", nil, 0, nil
+	}
+
+	switch toks[it+1].Lit() {
+	case "as":
+		if err := expectKindsForwardInTokens(it+1, toks, tokens.Ident); err != nil {
+			return "", nil, 0, err
+		}
+
+		return toks[it+2].Lit(), nil, 2, nil
+	case "only":
+		if err := expectKindsForwardInTokens(it+1, toks, tokens.BracketOpen); err != nil {
+			return "", nil, 0, err
+		}
+
+		only = []string{}
+
+		for n := it + 3; n < len(toks); n++ {
+			if toks[n].Iskind(tokens.BracketClose) {
+				return "", only, n - it, nil
+			} else if !toks[n].Iskind(tokens.String) {
+				return "", nil, 0, toks[n].Errf("expected the name of a module member, but found '%s' instead", toks[n].Lit())
+			}
+
+			only = append(only, toks[n].Lit())
+		}
+
+		return "", nil, 0, toks[it+2].Errf("no '%s' to match '%s'", tokens.BracketClose.PublicString(), tokens.BracketOpen.PublicString())
+	}
+
+	return "", nil, 0, nil
+}
+
+/*
+Returns the table for a host, standard library or .req module
+
+.req modules are only executed the first time they're imported, after which they're cached in the env by their absolute path
+*/
+func (i *Interpreter) loadModule(name string) (types.ReqType, error) {
+	e := i.scope.Env()
+
+	if !isFileModule(name) {
+		if mod, ok := e.HostModule(name); ok {
+			return tabletype.New(mod), nil
+		} else if mod, ok := stdlib.Stdlib[name]; ok && !strings.HasPrefix(name, "__") {
+			if err := e.GetSandbox().CheckModule(name); err != nil {
+				return nil, err
+			}
+
+			return tabletype.New(mod), nil
+		}
+
+		return nil, fmt.Errorf("module '%s' does not exist in the standard library", name)
+	}
+
+	if err := e.GetSandbox().CheckFileImport(name); err != nil {
+		return nil, err
+	}
+
+	resolved, err := resolveModule(name, i.scope.File(), e.SearchPath)
+	if err != nil {
+		return nil, err
+	}
+
+	chain := i.scope.ImportChain()
+	if slices.Contains(chain, resolved) {
+		return nil, fmt.Errorf("import cycle: %s", strings.Join(append(slices.Clone(chain), resolved), " -> "))
+	}
+
+	if mod, ok := e.CachedModule(resolved); ok {
+		return mod, nil
+	}
+
+	content, err := os.ReadFile(resolved)
+	if err != nil {
+		return nil, err
+	}
+
+	root := scope.New(nil, map[string]types.ReqType{})
+	root.SetEnv(e)
+	root.SetFile(resolved, append(slices.Clone(chain), resolved))
+
+	interp, err := New(root)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err = interp.Execute(string(content)); err != nil {
+		return nil, err
+	}
+
+	exports, err := interp.scope.Exports()
+	if err != nil {
+		return nil, fmt.Errorf("in module '%s': %s", resolved, err.Error())
+	}
+
+	mod := tabletype.New(exports)
+	e.CacheModule(resolved, mod)
+
+	return mod, nil
+}
+
+// Binds a module to its default name or alias, or binds only the given members of it
+func (i *Interpreter) bindModule(name string, mod types.ReqType, alias string, only []string) error {
+	if only != nil {
+		members := mod.Literal().(map[string]types.ReqType)
+
+		for _, n := range only {
+			v, ok := members[n]
+			if !ok {
+				return fmt.Errorf("module '%s' has no member '%s'", name, n)
+			}
+
+			if err := i.scope.WriteForeignConst(n, v); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}
+
+	if alias == "" {
+		alias = defaultModuleName(name)
+	}
+
+	return i.scope.WriteForeignConst(alias, mod)
+}
+
+// Sets the file being executed, which .req imports are resolved relative to
+func (i *Interpreter) SetFile(file string) error {
+	abs, err := filepath.Abs(file)
+	if err != nil {
+		return err
+	}
+
+	i.scope.SetFile(abs, []string{abs})
+
+	return nil
+}
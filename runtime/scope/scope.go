@@ -2,14 +2,34 @@ package scope
 
 import (
 	"fmt"
+	"maps"
+	"reflect"
+	"slices"
 	"strings"
+	"sync"
+	"sync/atomic"
 
+	"github.com/voidwyrm-2/reqproc/runtime/env"
+	"github.com/voidwyrm-2/reqproc/runtime/stack"
 	"github.com/voidwyrm-2/reqproc/runtime/types"
 )
 
+// Scopes are safe to use from multiple goroutines
 type Scope struct {
+	mu                                    sync.RWMutex
 	vars, consts, disallowedVariableNames map[string]types.ReqType
 	parent                                *Scope
+	env                                   *env.Env
+	isolated                              bool
+	file                                  string
+	importChain                           []string
+	foreign                               map[string]struct{}
+	builtins                              map[string]types.ReqType
+	exports                               []string
+
+	// the size of each variable and constant, and the counter they're added to while they're held, if the scope is tracked
+	sizes map[string]int64
+	usage *atomic.Int64
 }
 
 func New(parent *Scope, disallowedVariableNames map[string]types.ReqType) *Scope {
@@ -18,31 +38,168 @@ func New(parent *Scope, disallowedVariableNames map[string]types.ReqType) *Scope
 		consts:                  map[string]types.ReqType{},
 		disallowedVariableNames: disallowedVariableNames,
 		parent:                  parent,
+		foreign:                 map[string]struct{}{},
 	}
 }
 
-func (sc Scope) Vars() map[string]types.ReqType {
-	return sc.vars
+// Creates a scope which can read from its parent, but cannot reassign any of the parent's variables
+func NewIsolated(parent *Scope) *Scope {
+	sc := New(parent, map[string]types.ReqType{})
+	sc.isolated = true
+	return sc
 }
 
-func (sc Scope) Consts() map[string]types.ReqType {
-	return sc.consts
+/*
+Copies the scope and its parents, passing their values through value (e.g. to copy lists and tables),
+so the copy can be used from another goroutine while the original carries on being changed
+
+copies maps the scopes which have already been copied to their copies, so that a scope which is reached more than once
+(e.g. through the closure of a function defined in it) is only copied once
+*/
+func (sc *Scope) Copy(copies map[*Scope]*Scope, value func(types.ReqType) types.ReqType) *Scope {
+	if sc == nil {
+		return nil
+	} else if c, ok := copies[sc]; ok {
+		return c
+	}
+
+	c := New(nil, sc.disallowedVariableNames)
+	copies[sc] = c
+
+	sc.mu.RLock()
+	vars, consts := maps.Clone(sc.vars), maps.Clone(sc.consts)
+	c.env, c.isolated, c.builtins = sc.env, sc.isolated, sc.builtins
+	c.file, c.importChain = sc.file, slices.Clone(sc.importChain)
+	c.foreign, c.exports = maps.Clone(sc.foreign), slices.Clone(sc.exports)
+	sc.mu.RUnlock()
+
+	c.parent = sc.parent.Copy(copies, value)
+
+	for _, m := range []map[string]types.ReqType{vars, consts} {
+		for n, v := range m {
+			// variables which haven't been assigned yet are nil
+			if v != nil {
+				m[n] = value(v)
+			}
+		}
+	}
+
+	c.vars, c.consts = vars, consts
+
+	return c
+}
+
+// Reports whether this scope or any of its parents is isolated
+func (sc *Scope) Isolated() bool {
+	for ; sc != nil; sc = sc.parent {
+		if sc.isolated {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Returns a copy of the variables defined directly in this scope
+func (sc *Scope) Vars() map[string]types.ReqType {
+	sc.mu.RLock()
+	defer sc.mu.RUnlock()
+
+	return maps.Clone(sc.vars)
+}
+
+// Returns a copy of the constants defined directly in this scope
+func (sc *Scope) Consts() map[string]types.ReqType {
+	sc.mu.RLock()
+	defer sc.mu.RUnlock()
+
+	return maps.Clone(sc.consts)
+}
+
+// Returns the scope this one was created in, or nil if it's the outermost one
+func (sc *Scope) Parent() *Scope {
+	return sc.parent
+}
+
+// Returns the env of the closest scope that has one, or nil if none do
+func (sc *Scope) Env() *env.Env {
+	sc.mu.RLock()
+	defer sc.mu.RUnlock()
+
+	if sc.env != nil {
+		return sc.env
+	} else if sc.parent != nil {
+		return sc.parent.Env()
+	}
+
+	return nil
+}
+
+func (sc *Scope) SetEnv(e *env.Env) {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+
+	sc.env = e
+}
+
+// Returns the path of the file the closest scope with one was created for, or an empty string if none were
+func (sc *Scope) File() string {
+	sc.mu.RLock()
+	defer sc.mu.RUnlock()
+
+	if sc.file != "" {
+		return sc.file
+	} else if sc.parent != nil {
+		return sc.parent.File()
+	}
+
+	return ""
 }
 
-func (sc Scope) NameExists(name string) error {
+// Returns the files being imported to get to File, starting from the first one executed
+func (sc *Scope) ImportChain() []string {
+	sc.mu.RLock()
+	defer sc.mu.RUnlock()
+
+	if sc.file != "" {
+		return sc.importChain
+	} else if sc.parent != nil {
+		return sc.parent.ImportChain()
+	}
+
+	return []string{}
+}
+
+// Marks this scope as the top-level scope of a file; importChain should end with file
+func (sc *Scope) SetFile(file string, importChain []string) {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+
+	sc.file = file
+	sc.importChain = importChain
+}
+
+func (sc *Scope) NameExists(name string) error {
+	sc.mu.RLock()
+	defer sc.mu.RUnlock()
+
+	return sc.nameExists(name)
+}
+
+func (sc *Scope) nameExists(name string) error {
 	if _, kwOk := sc.disallowedVariableNames[name]; false {
 	} else if _, ok := types.IllegalVariableNames[name]; ok || kwOk {
 		return fmt.Errorf("'%s' is not a valid variable name", name)
 	} else if _, ok = sc.vars[name]; ok {
 		return fmt.Errorf("variable '%s' already exists", name)
-	} else if _, ok = sc.consts[name]; ok {
+	} else if _, ok = sc.constant(name); ok {
 		return fmt.Errorf("'%s' already exists as a constant", name)
 	}
 
 	return nil
 }
 
-func (sc Scope) Read(name string) (types.ReqType, error) {
+func (sc *Scope) Read(name string) (types.ReqType, error) {
 	if strings.Contains(name, ".") {
 		path := strings.Split(name, ".")
 
@@ -54,14 +211,19 @@ func (sc Scope) Read(name string) (types.ReqType, error) {
 		return sc.nestedRead(path[1:], v)
 	}
 
-	if v, ok := sc.vars[name]; ok {
+	sc.mu.RLock()
+	v, isVar := sc.vars[name]
+	c, isConst := sc.constant(name)
+	sc.mu.RUnlock()
+
+	if isVar {
 		if v == nil {
 			return nil, fmt.Errorf("variable '%s' has not had a value assigned to it yet", name)
 		}
 
 		return v, nil
-	} else if v, ok = sc.consts[name]; ok {
-		return v, nil
+	} else if isConst {
+		return c, nil
 	}
 
 	if sc.parent != nil {
@@ -71,7 +233,7 @@ func (sc Scope) Read(name string) (types.ReqType, error) {
 	return nil, fmt.Errorf("variable/constant '%s' does not exist", name)
 }
 
-func (sc Scope) nestedRead(path []string, tbl types.ReqType) (types.ReqType, error) {
+func (sc *Scope) nestedRead(path []string, tbl types.ReqType) (types.ReqType, error) {
 	if path[0] == "" {
 		return nil, fmt.Errorf("the dot indexed path cannot be empty")
 	} else if tbl.Type() != types.TypeTable {
@@ -112,40 +274,255 @@ func (sc *Scope) LoadAllConst(funcs map[string]types.ReqType) error {
 	return nil
 }
 
+/*
+Gives the scope constants which are shared with other scopes instead of being copied into it, like the stdlib;
+they act like foreign constants, and the map must not be changed afterwards
+*/
+func (sc *Scope) SetBuiltins(builtins map[string]types.ReqType) {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+
+	sc.builtins = builtins
+}
+
+// reads a constant of this scope, including its builtins
+func (sc *Scope) constant(name string) (types.ReqType, bool) {
+	if c, ok := sc.consts[name]; ok {
+		return c, true
+	}
+
+	c, ok := sc.builtins[name]
+	return c, ok
+}
+
+func (sc *Scope) LoadAllForeignConst(funcs map[string]types.ReqType) error {
+	for n, f := range funcs {
+		if err := sc.WriteForeignConst(n, f); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
 func (sc *Scope) Write(name string, value types.ReqType) error {
-	if err := sc.NameExists(name); err != nil {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+
+	if err := sc.nameExists(name); err != nil {
 		return err
 	}
 
 	sc.vars[name] = value
+	sc.count(name, value)
 
 	return nil
 }
 
 func (sc *Scope) WriteConst(name string, value types.ReqType) error {
-	if err := sc.NameExists(name); err != nil {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+
+	if err := sc.nameExists(name); err != nil {
 		return err
 	}
 
 	sc.consts[name] = value
+	sc.count(name, value)
+
+	return nil
+}
+
+// Writes a constant that wasn't defined by the code using this scope (e.g. builtins and imported modules), so it isn't exported by default
+func (sc *Scope) WriteForeignConst(name string, value types.ReqType) error {
+	if err := sc.WriteConst(name, value); err != nil {
+		return err
+	}
+
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+
+	sc.foreign[name] = struct{}{}
+
+	return nil
+}
+
+// Reports whether a constant of this scope was written with WriteForeignConst
+func (sc *Scope) Foreign(name string) bool {
+	sc.mu.RLock()
+	defer sc.mu.RUnlock()
+
+	_, ok := sc.foreign[name]
+
+	return ok
+}
+
+// Marks a name to be exported by Exports; it doesn't have to be defined yet
+func (sc *Scope) Export(name string) error {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+
+	if strings.HasPrefix(name, "_") {
+		return fmt.Errorf("cannot export private name '%s'", name)
+	} else if slices.Contains(sc.exports, name) {
+		return fmt.Errorf("'%s' is already exported", name)
+	}
+
+	sc.exports = append(sc.exports, name)
 
 	return nil
 }
 
+/*
+Returns what a module made from this scope exposes
+
+if any names were marked with Export, only those are exported, otherwise it's every variable and constant defined in this scope,
+excluding foreign constants, names starting with '_', and variables without a value
+*/
+func (sc *Scope) Exports() (map[string]types.ReqType, error) {
+	sc.mu.RLock()
+	defer sc.mu.RUnlock()
+
+	exported := map[string]types.ReqType{}
+
+	if len(sc.exports) == 0 {
+		for _, m := range []map[string]types.ReqType{sc.vars, sc.consts} {
+			for n, v := range m {
+				if _, ok := sc.foreign[n]; !ok && v != nil && !strings.HasPrefix(n, "_") {
+					exported[n] = v
+				}
+			}
+		}
+
+		return exported, nil
+	}
+
+	for _, n := range sc.exports {
+		if v, ok := sc.constant(n); ok {
+			exported[n] = v
+		} else if v, ok = sc.vars[n]; !ok {
+			return nil, fmt.Errorf("cannot export '%s', it isn't defined", n)
+		} else if v == nil {
+			return nil, fmt.Errorf("cannot export variable '%s' before it has a value", n)
+		} else {
+			exported[n] = v
+		}
+	}
+
+	return exported, nil
+}
+
 func (sc *Scope) Update(name string, value types.ReqType) error {
-	if _, ok := sc.consts[name]; ok {
+	sc.mu.Lock()
+
+	if _, ok := sc.constant(name); ok {
+		sc.mu.Unlock()
 		return fmt.Errorf("cannot reassign constant '%s'", name)
 	}
 
 	_, ok := sc.vars[name]
-
 	if ok {
 		sc.vars[name] = value
+		sc.count(name, value)
+	}
+
+	sc.mu.Unlock()
+
+	if ok {
+		return nil
+	} else if sc.isolated {
+		return fmt.Errorf("cannot reassign '%s' from inside an isolated scope", name)
 	} else if sc.parent != nil {
 		return sc.parent.Update(name, value)
-	} else {
-		return fmt.Errorf("variable/constant '%s' does not exist", name)
 	}
 
-	return nil
+	return fmt.Errorf("variable/constant '%s' does not exist", name)
+}
+
+/*
+Adds the size of the values held by the scope's variables and constants to usage while they're held, see stack.SizeOf
+
+sizes are taken when values are written, so Grow has to be told about lists and tables changed in place
+*/
+func (sc *Scope) Track(usage *atomic.Int64) {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+
+	if sc.usage != nil || usage == nil {
+		return
+	}
+
+	sc.usage = usage
+	sc.sizes = map[string]int64{}
+
+	for _, m := range []map[string]types.ReqType{sc.vars, sc.consts} {
+		for n, v := range m {
+			sc.count(n, v)
+		}
+	}
+}
+
+// Stops tracking the scope, taking the size of its values off the usage they were added to
+func (sc *Scope) Release() {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+
+	if sc.usage == nil {
+		return
+	}
+
+	for _, size := range sc.sizes {
+		sc.usage.Add(-size)
+	}
+
+	sc.usage, sc.sizes = nil, nil
+}
+
+// Adds by to the size of the variables and constants of the scope and its parents which hold v, a list or table which was changed in place
+func (sc *Scope) Grow(v types.ReqType, by int64) {
+	for ; sc != nil; sc = sc.parent {
+		sc.mu.Lock()
+
+		if sc.usage != nil {
+			for _, m := range []map[string]types.ReqType{sc.vars, sc.consts} {
+				for n, held := range m {
+					if held != nil && sameIndexable(held, v) {
+						sc.sizes[n] += by
+						sc.usage.Add(by)
+					}
+				}
+			}
+		}
+
+		sc.mu.Unlock()
+	}
+}
+
+// counts the value written to a name in place of the one it had, if the scope is tracked
+func (sc *Scope) count(name string, v types.ReqType) {
+	if sc.usage == nil {
+		return
+	}
+
+	size := int64(0)
+	if v != nil {
+		size = stack.SizeOf(v)
+	}
+
+	sc.usage.Add(size - sc.sizes[name])
+	sc.sizes[name] = size
+}
+
+// reports whether a and b are the same list or table, so that changing one in place changes the other
+func sameIndexable(a, b types.ReqType) bool {
+	switch l := a.Literal().(type) {
+	case []types.ReqType:
+		other, ok := b.Literal().([]types.ReqType)
+		return ok && len(l) > 0 && len(other) > 0 && &l[0] == &other[0]
+	case map[string]types.ReqType:
+		other, ok := b.Literal().(map[string]types.ReqType)
+		return ok && reflect.ValueOf(l).Pointer() == reflect.ValueOf(other).Pointer()
+	}
+
+	return false
 }
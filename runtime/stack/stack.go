@@ -2,12 +2,18 @@ package stack
 
 import (
 	"fmt"
+	"reflect"
+	"sync/atomic"
 
 	"github.com/voidwyrm-2/reqproc/runtime/types"
 )
 
 type Stack struct {
 	stack []types.ReqType
+
+	// the size of each value and the counter it's added to while the value is on the stack, if the stack is tracked
+	sizes []int64
+	usage *atomic.Int64
 }
 
 func New(values ...types.ReqType) Stack {
@@ -16,6 +22,105 @@ func New(values ...types.ReqType) Stack {
 
 func (s *Stack) Push(values ...types.ReqType) {
 	s.stack = append(s.stack, values...)
+
+	if s.usage != nil {
+		s.count(values)
+	}
+}
+
+/*
+The bytes counted against memory limits for a value: the bytes of a string, 4 for a number (they're float32s),
+and the bytes of the items of a list, or of the keys and items of a table
+
+a list or table is only counted once, even if it's in the value more than once (or in itself)
+*/
+func SizeOf(v types.ReqType) int64 {
+	return sizeOf(v, map[uintptr]struct{}{})
+}
+
+func sizeOf(v types.ReqType, seen map[uintptr]struct{}) int64 {
+	switch l := v.Literal().(type) {
+	case string:
+		return int64(len(l))
+	case float32:
+		return 4
+	case []types.ReqType:
+		if len(l) == 0 || counted(reflect.ValueOf(l).Pointer(), seen) {
+			return 0
+		}
+
+		size := int64(0)
+		for _, item := range l {
+			size += sizeOf(item, seen)
+		}
+
+		return size
+	case map[string]types.ReqType:
+		if counted(reflect.ValueOf(l).Pointer(), seen) {
+			return 0
+		}
+
+		size := int64(0)
+		for k, item := range l {
+			size += int64(len(k)) + sizeOf(item, seen)
+		}
+
+		return size
+	default:
+		return 0
+	}
+}
+
+// reports whether the list or table at p was already counted, marking it as counted if it wasn't
+func counted(p uintptr, seen map[uintptr]struct{}) bool {
+	if _, ok := seen[p]; ok {
+		return true
+	}
+
+	seen[p] = struct{}{}
+
+	return false
+}
+
+/*
+Adds the size of the values on the stack to usage while they're on it, starting with the ones already there
+
+sizes are taken when values are pushed, so a list changed in place keeps the size it was pushed with until it's pushed again
+*/
+func (s *Stack) Track(usage *atomic.Int64) {
+	if s.usage != nil {
+		return
+	}
+
+	s.usage = usage
+	s.count(s.stack)
+}
+
+// Stops tracking the stack, taking the size of the values still on it off the usage they were added to
+func (s *Stack) Release() {
+	if s.usage == nil {
+		return
+	}
+
+	s.uncount(len(s.sizes))
+	s.usage = nil
+}
+
+func (s *Stack) count(values []types.ReqType) {
+	for _, v := range values {
+		size := SizeOf(v)
+		s.sizes = append(s.sizes, size)
+		s.usage.Add(size)
+	}
+}
+
+// takes the sizes of the top n values off the usage
+func (s *Stack) uncount(n int) {
+	for _, size := range s.sizes[len(s.sizes)-n:] {
+		s.usage.Add(-size)
+	}
+
+	s.sizes = s.sizes[:len(s.sizes)-n]
 }
 
 func (s Stack) Expect(kinds ...types.ReqVarType) error {
@@ -56,9 +161,26 @@ func (s Stack) Expect(kinds ...types.ReqVarType) error {
 func (s *Stack) Pop() types.ReqType {
 	value := s.stack[len(s.stack)-1]
 	s.stack = s.stack[:len(s.stack)-1]
+
+	if s.usage != nil {
+		s.uncount(1)
+	}
+
 	return value
 }
 
+// Pops n values off the stack, returning them in the order they were pushed
+func (s *Stack) PopN(n int) []types.ReqType {
+	values := append([]types.ReqType{}, s.stack[len(s.stack)-n:]...)
+	s.stack = s.stack[:len(s.stack)-n]
+
+	if s.usage != nil {
+		s.uncount(n)
+	}
+
+	return values
+}
+
 func (s Stack) Len() int {
 	return len(s.stack)
 }
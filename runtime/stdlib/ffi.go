@@ -0,0 +1,338 @@
+package stdlib
+
+import (
+	"errors"
+	"fmt"
+	"plugin"
+	"reflect"
+	"strings"
+
+	"github.com/voidwyrm-2/reqproc/runtime/scope"
+	"github.com/voidwyrm-2/reqproc/runtime/stack"
+	"github.com/voidwyrm-2/reqproc/runtime/types"
+	"github.com/voidwyrm-2/reqproc/runtime/types/functiontype"
+	"github.com/voidwyrm-2/reqproc/runtime/types/nativetype"
+	"github.com/voidwyrm-2/reqproc/runtime/types/stringtype"
+)
+
+var ffiBasicTypes = map[string]reflect.Type{
+	"bool":    reflect.TypeFor[bool](),
+	"string":  reflect.TypeFor[string](),
+	"int":     reflect.TypeFor[int](),
+	"int8":    reflect.TypeFor[int8](),
+	"int16":   reflect.TypeFor[int16](),
+	"int32":   reflect.TypeFor[int32](),
+	"int64":   reflect.TypeFor[int64](),
+	"uint":    reflect.TypeFor[uint](),
+	"uint8":   reflect.TypeFor[uint8](),
+	"uint16":  reflect.TypeFor[uint16](),
+	"uint32":  reflect.TypeFor[uint32](),
+	"uint64":  reflect.TypeFor[uint64](),
+	"float32": reflect.TypeFor[float32](),
+	"float64": reflect.TypeFor[float64](),
+	"byte":    reflect.TypeFor[byte](),
+	"rune":    reflect.TypeFor[rune](),
+	"any":     reflect.TypeFor[any](),
+	"error":   reflect.TypeFor[error](),
+}
+
+/*
+A Go type in a declared signature
+
+it's either one of ffiBasicTypes, `[]T`, `map[string]T`, or `native`, which matches any type that's passed around as a native value (e.g. pointers)
+*/
+type ffiType struct {
+	basic  reflect.Type
+	slice  *ffiType
+	mapOf  *ffiType
+	native bool
+}
+
+func (ft ffiType) matches(t reflect.Type) bool {
+	switch {
+	case ft.native:
+		return types.VarTypeOf(t) == types.TypeNative
+	case ft.slice != nil:
+		return t.Kind() == reflect.Slice && ft.slice.matches(t.Elem())
+	case ft.mapOf != nil:
+		return t.Kind() == reflect.Map && t.Key() == ffiBasicTypes["string"] && ft.mapOf.matches(t.Elem())
+	default:
+		return t == ft.basic
+	}
+}
+
+// parses a declared signature like `func(int, []string) (map[string]int, error)`
+type ffiSignatureParser struct {
+	src string
+	pos int
+}
+
+func (p *ffiSignatureParser) skipSpaces() {
+	for p.pos < len(p.src) && p.src[p.pos] == ' ' {
+		p.pos++
+	}
+}
+
+func (p *ffiSignatureParser) accept(s string) bool {
+	p.skipSpaces()
+
+	if strings.HasPrefix(p.src[p.pos:], s) {
+		p.pos += len(s)
+		return true
+	}
+
+	return false
+}
+
+func (p *ffiSignatureParser) errf(format string, a ...any) error {
+	return fmt.Errorf("invalid signature '%s' at column %d: %s", p.src, p.pos+1, fmt.Sprintf(format, a...))
+}
+
+func (p *ffiSignatureParser) parseType() (ffiType, error) {
+	if p.accept("[]") {
+		elem, err := p.parseType()
+		return ffiType{slice: &elem}, err
+	} else if p.accept("map[string]") {
+		elem, err := p.parseType()
+		return ffiType{mapOf: &elem}, err
+	} else if p.accept("interface{}") {
+		return ffiType{basic: ffiBasicTypes["any"]}, nil
+	}
+
+	p.skipSpaces()
+
+	start := p.pos
+	for p.pos < len(p.src) && (p.src[p.pos] >= 'a' && p.src[p.pos] <= 'z' || p.src[p.pos] >= '0' && p.src[p.pos] <= '9') {
+		p.pos++
+	}
+
+	name := p.src[start:p.pos]
+
+	if name == "native" {
+		return ffiType{native: true}, nil
+	} else if t, ok := ffiBasicTypes[name]; ok {
+		return ffiType{basic: t}, nil
+	}
+
+	p.pos = start
+
+	return ffiType{}, p.errf("unknown type '%s'", name)
+}
+
+// parses `(T, T...)`, the opening parenthesis having already been read
+func (p *ffiSignatureParser) parseList() ([]ffiType, error) {
+	list := []ffiType{}
+
+	if p.accept(")") {
+		return list, nil
+	}
+
+	for {
+		t, err := p.parseType()
+		if err != nil {
+			return nil, err
+		}
+
+		list = append(list, t)
+
+		if p.accept(")") {
+			return list, nil
+		} else if !p.accept(",") {
+			return nil, p.errf("expected ',' or ')'")
+		}
+	}
+}
+
+func parseFFISignature(src string) (in, out []ffiType, err error) {
+	p := &ffiSignatureParser{src: src}
+
+	if !p.accept("func(") {
+		return nil, nil, p.errf("expected 'func('")
+	} else if in, err = p.parseList(); err != nil {
+		return nil, nil, err
+	}
+
+	if p.accept("(") {
+		if out, err = p.parseList(); err != nil {
+			return nil, nil, err
+		}
+	} else if p.skipSpaces(); p.pos < len(p.src) {
+		t, err := p.parseType()
+		if err != nil {
+			return nil, nil, err
+		}
+
+		out = []ffiType{t}
+	}
+
+	if p.skipSpaces(); p.pos < len(p.src) {
+		return nil, nil, p.errf("unexpected '%s'", p.src[p.pos:])
+	}
+
+	return in, out, nil
+}
+
+// checks that a function has the declared signature, so a plugin can't be called with arguments it doesn't expect
+func checkFFISignature(name string, fn reflect.Type, declared string) error {
+	in, out, err := parseFFISignature(declared)
+	if err != nil {
+		return err
+	}
+
+	mismatch := fmt.Errorf("'%s' has type %s, but was declared as %s", name, fn, declared)
+
+	if fn.IsVariadic() || fn.NumIn() != len(in) || fn.NumOut() != len(out) {
+		return mismatch
+	}
+
+	for i, t := range in {
+		if !t.matches(fn.In(i)) {
+			return mismatch
+		}
+	}
+
+	for i, t := range out {
+		if !t.matches(fn.Out(i)) {
+			return mismatch
+		}
+	}
+
+	return nil
+}
+
+func lookupSymbol(v types.ReqType, name string) (plugin.Symbol, error) {
+	p, err := handleAs[*plugin.Plugin](v, "plugin")
+	if err != nil {
+		return nil, err
+	}
+
+	return p.Lookup(name)
+}
+
+var ffiModule = map[string]types.ReqType{
+	"open": functiontype.NewNative(func(sc *scope.Scope, st *stack.Stack, callf func(rft functiontype.ReqFunctionType, sc *scope.Scope, st *stack.Stack) error) error {
+		path := st.Pop().Literal().(string)
+
+		p, err := plugin.Open(path)
+		if err != nil {
+			return err
+		}
+
+		st.Push(nativetype.NewHandle("plugin "+path, p))
+
+		return nil
+	}, []types.ReqVarType{types.TypeString}, []types.ReqVarType{types.TypeNative}).SetDoc("Loads a Go plugin (a .so file built with -buildmode=plugin)"),
+
+	"func": functiontype.NewNative(func(sc *scope.Scope, st *stack.Stack, callf func(rft functiontype.ReqFunctionType, sc *scope.Scope, st *stack.Stack) error) error {
+		signature := st.Pop().Literal().(string)
+		name := st.Pop().Literal().(string)
+
+		sym, err := lookupSymbol(st.Pop(), name)
+		if err != nil {
+			return err
+		}
+
+		fn := reflect.ValueOf(sym)
+		if fn.Kind() != reflect.Func {
+			return fmt.Errorf("'%s' is a variable of type %s, not a function; use ffi.var instead", name, fn.Type().Elem())
+		} else if err = checkFFISignature(name, fn.Type(), signature); err != nil {
+			return err
+		}
+
+		rft, err := functiontype.FromGo(sym)
+		if err != nil {
+			return err
+		}
+
+		st.Push(rft.SetDoc(fmt.Sprintf("%s %s, from a Go plugin", name, strings.TrimPrefix(signature, "func"))))
+
+		return nil
+	}, []types.ReqVarType{types.TypeString, types.TypeString, types.TypeNative}, []types.ReqVarType{types.TypeFunction}).SetDoc("Takes a plugin, the name of a function it exports and its declared Go signature (e.g. \"func(int, int) int\"), and returns the function"),
+
+	"var": functiontype.NewNative(func(sc *scope.Scope, st *stack.Stack, callf func(rft functiontype.ReqFunctionType, sc *scope.Scope, st *stack.Stack) error) error {
+		name := st.Pop().Literal().(string)
+
+		sym, err := lookupSymbol(st.Pop(), name)
+		if err != nil {
+			return err
+		} else if reflect.TypeOf(sym).Kind() == reflect.Func {
+			return fmt.Errorf("'%s' is a function, use ffi.func instead", name)
+		}
+
+		st.Push(nativetype.NewHandle(name+" "+reflect.TypeOf(sym).String(), sym))
+
+		return nil
+	}, []types.ReqVarType{types.TypeString, types.TypeNative}, []types.ReqVarType{types.TypeNative}).SetDoc("Takes a plugin and the name of a variable it exports, and returns a pointer to it which can be used with ffi.fromNative and ffi.set"),
+
+	"set": functiontype.NewNative(func(sc *scope.Scope, st *stack.Stack, callf func(rft functiontype.ReqFunctionType, sc *scope.Scope, st *stack.Stack) error) error {
+		v := st.Pop()
+		ptr := st.Pop().(nativetype.ReqNativeType)
+
+		if t := ptr.GoType(); t == nil || t.Kind() != reflect.Pointer {
+			return fmt.Errorf("'%s' is not a pointer", ptr.String())
+		}
+
+		target := reflect.ValueOf(ptr.Handle())
+		if target.IsNil() {
+			return errors.New("cannot set through a nil pointer")
+		}
+
+		c, err := types.ToGoValue(v, target.Type().Elem(), nil)
+		if err != nil {
+			return err
+		}
+
+		target.Elem().Set(c)
+
+		return nil
+	}, []types.ReqVarType{types.TypeAny, types.TypeNative}, []types.ReqVarType{}).SetDoc("Sets the value a native pointer points to, converting it to the pointer's Go type"),
+
+	"typeof": functiontype.NewNative(func(sc *scope.Scope, st *stack.Stack, callf func(rft functiontype.ReqFunctionType, sc *scope.Scope, st *stack.Stack) error) error {
+		t := st.Pop().(nativetype.ReqNativeType).GoType()
+		if t == nil {
+			st.Push(stringtype.New("nil"))
+		} else {
+			st.Push(stringtype.New(t.String()))
+		}
+
+		return nil
+	}, []types.ReqVarType{types.TypeNative}, []types.ReqVarType{types.TypeString}).SetDoc("Returns the Go type of the value held by a native value"),
+
+	"toNative": functiontype.NewNative(func(sc *scope.Scope, st *stack.Stack, callf func(rft functiontype.ReqFunctionType, sc *scope.Scope, st *stack.Stack) error) error {
+		v := st.Pop()
+		if v.Type() == types.TypeNative {
+			st.Push(v)
+			return nil
+		}
+
+		val, err := types.ToGo(v, reflect.TypeFor[any]())
+		if err != nil {
+			return err
+		}
+
+		st.Push(nativetype.New(val))
+
+		return nil
+	}, []types.ReqVarType{types.TypeAny}, []types.ReqVarType{types.TypeNative}).SetDoc("Converts a value into a native value holding its Go equivalent"),
+
+	"fromNative": functiontype.NewNative(func(sc *scope.Scope, st *stack.Stack, callf func(rft functiontype.ReqFunctionType, sc *scope.Scope, st *stack.Stack) error) error {
+		val := reflect.ValueOf(st.Pop().Literal())
+		if !val.IsValid() {
+			return errors.New("native value holds nil")
+		}
+
+		// pointers (e.g. from ffi.var) are read through
+		if val.Kind() == reflect.Pointer && !val.IsNil() && types.VarTypeOf(val.Type().Elem()) != types.TypeNative {
+			val = val.Elem()
+		}
+
+		v, err := types.FromGo(val.Interface())
+		if err != nil {
+			return err
+		}
+
+		st.Push(v)
+
+		return nil
+	}, []types.ReqVarType{types.TypeNative}, []types.ReqVarType{types.TypeAny}).SetDoc("Converts the Go value held by a native value back into a ReqProc value, if it has an equivalent"),
+}
@@ -0,0 +1,255 @@
+package stdlib
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"time"
+
+	"github.com/voidwyrm-2/reqproc/runtime/scope"
+	"github.com/voidwyrm-2/reqproc/runtime/stack"
+	"github.com/voidwyrm-2/reqproc/runtime/types"
+	"github.com/voidwyrm-2/reqproc/runtime/types/functiontype"
+	"github.com/voidwyrm-2/reqproc/runtime/types/nativetype"
+	"github.com/voidwyrm-2/reqproc/runtime/types/numbertype"
+	"github.com/voidwyrm-2/reqproc/runtime/types/stringtype"
+)
+
+// shown as e.g. `<tcp 127.0.0.1:8080>`
+func newConnHandle(conn net.Conn) nativetype.ReqNativeType {
+	return nativetype.NewHandle(conn.RemoteAddr().Network()+" "+conn.RemoteAddr().String(), conn)
+}
+
+func newListenerHandle(l net.Listener) nativetype.ReqNativeType {
+	return nativetype.NewHandle(l.Addr().Network()+" listener "+l.Addr().String(), l)
+}
+
+func newPacketConnHandle(pc net.PacketConn) nativetype.ReqNativeType {
+	return nativetype.NewHandle(pc.LocalAddr().Network()+" "+pc.LocalAddr().String(), pc)
+}
+
+func handleAs[T any](v types.ReqType, what string) (T, error) {
+	h, ok := v.(nativetype.ReqNativeType).Handle().(T)
+	if !ok {
+		var zero T
+		return zero, fmt.Errorf("'%s' is not a %s", v.String(), what)
+	}
+
+	return h, nil
+}
+
+func byteCount(v types.ReqType) (int, error) {
+	if v.(numbertype.ReqNumberType).IsFloat() {
+		return 0, floatInvalidFor("byte count")
+	}
+
+	n := int(v.Literal().(float32))
+	if n < 1 {
+		return 0, fmt.Errorf("byte count must be positive, but found %d", n)
+	}
+
+	return n, nil
+}
+
+var netModule = map[string]types.ReqType{
+	"dial": functiontype.NewNative(func(sc *scope.Scope, st *stack.Stack, callf func(rft functiontype.ReqFunctionType, sc *scope.Scope, st *stack.Stack) error) error {
+		addr, network := st.Pop().Literal().(string), st.Pop().Literal().(string)
+
+		if err := sc.Env().GetSandbox().CheckHost(addr); err != nil {
+			return err
+		}
+
+		conn, err := net.Dial(network, addr)
+		if err != nil {
+			return err
+		}
+
+		st.Push(newConnHandle(conn))
+
+		return nil
+	}, []types.ReqVarType{types.TypeString, types.TypeString}, []types.ReqVarType{types.TypeNative}).SetDoc("Connects to an address on a network (e.g. \"tcp\" or \"udp\") and returns the connection"),
+
+	"listen": functiontype.NewNative(func(sc *scope.Scope, st *stack.Stack, callf func(rft functiontype.ReqFunctionType, sc *scope.Scope, st *stack.Stack) error) error {
+		addr, network := st.Pop().Literal().(string), st.Pop().Literal().(string)
+
+		if err := sc.Env().GetSandbox().CheckListen(addr); err != nil {
+			return err
+		}
+
+		l, err := net.Listen(network, addr)
+		if err != nil {
+			return err
+		}
+
+		st.Push(newListenerHandle(l))
+
+		return nil
+	}, []types.ReqVarType{types.TypeString, types.TypeString}, []types.ReqVarType{types.TypeNative}).SetDoc("Listens on an address on a stream network (e.g. \"tcp\") and returns the listener"),
+
+	"listenPacket": functiontype.NewNative(func(sc *scope.Scope, st *stack.Stack, callf func(rft functiontype.ReqFunctionType, sc *scope.Scope, st *stack.Stack) error) error {
+		addr, network := st.Pop().Literal().(string), st.Pop().Literal().(string)
+
+		if err := sc.Env().GetSandbox().CheckListen(addr); err != nil {
+			return err
+		}
+
+		pc, err := net.ListenPacket(network, addr)
+		if err != nil {
+			return err
+		}
+
+		st.Push(newPacketConnHandle(pc))
+
+		return nil
+	}, []types.ReqVarType{types.TypeString, types.TypeString}, []types.ReqVarType{types.TypeNative}).SetDoc("Listens on an address on a packet network (e.g. \"udp\") and returns the socket, for use with net.sendTo and net.recvFrom"),
+
+	"accept": functiontype.NewNative(func(sc *scope.Scope, st *stack.Stack, callf func(rft functiontype.ReqFunctionType, sc *scope.Scope, st *stack.Stack) error) error {
+		l, err := handleAs[net.Listener](st.Pop(), "listener")
+		if err != nil {
+			return err
+		}
+
+		conn, err := l.Accept()
+		if err != nil {
+			return err
+		}
+
+		st.Push(newConnHandle(conn))
+
+		return nil
+	}, []types.ReqVarType{types.TypeNative}, []types.ReqVarType{types.TypeNative}).SetDoc("Waits for and returns the next connection to a listener"),
+
+	"read": functiontype.NewNative(func(sc *scope.Scope, st *stack.Stack, callf func(rft functiontype.ReqFunctionType, sc *scope.Scope, st *stack.Stack) error) error {
+		n, err := byteCount(st.Pop())
+		if err != nil {
+			return err
+		}
+
+		conn, err := handleAs[net.Conn](st.Pop(), "connection")
+		if err != nil {
+			return err
+		}
+
+		buf := make([]byte, n)
+
+		read, err := conn.Read(buf)
+		if err != nil && !errors.Is(err, io.EOF) {
+			return err
+		}
+
+		st.Push(stringtype.New(string(buf[:read])))
+
+		return nil
+	}, []types.ReqVarType{types.TypeNumber, types.TypeNative}, []types.ReqVarType{types.TypeString}).SetDoc("Reads up to the given amount of bytes from a connection; returns an empty string once the connection is closed"),
+
+	"write": functiontype.NewNative(func(sc *scope.Scope, st *stack.Stack, callf func(rft functiontype.ReqFunctionType, sc *scope.Scope, st *stack.Stack) error) error {
+		data := st.Pop().Literal().(string)
+
+		conn, err := handleAs[net.Conn](st.Pop(), "connection")
+		if err != nil {
+			return err
+		}
+
+		n, err := io.WriteString(conn, data)
+		if err != nil {
+			return err
+		}
+
+		st.Push(numbertype.New(float32(n)))
+
+		return nil
+	}, []types.ReqVarType{types.TypeString, types.TypeNative}, []types.ReqVarType{types.TypeNumber}).SetDoc("Writes a string to a connection and returns the amount of bytes written"),
+
+	"sendTo": functiontype.NewNative(func(sc *scope.Scope, st *stack.Stack, callf func(rft functiontype.ReqFunctionType, sc *scope.Scope, st *stack.Stack) error) error {
+		addr, data := st.Pop().Literal().(string), st.Pop().Literal().(string)
+
+		pc, err := handleAs[net.PacketConn](st.Pop(), "packet socket")
+		if err != nil {
+			return err
+		}
+
+		if err := sc.Env().GetSandbox().CheckHost(addr); err != nil {
+			return err
+		}
+
+		to, err := net.ResolveUDPAddr(pc.LocalAddr().Network(), addr)
+		if err != nil {
+			return err
+		}
+
+		n, err := pc.WriteTo([]byte(data), to)
+		if err != nil {
+			return err
+		}
+
+		st.Push(numbertype.New(float32(n)))
+
+		return nil
+	}, []types.ReqVarType{types.TypeString, types.TypeString, types.TypeNative}, []types.ReqVarType{types.TypeNumber}).SetDoc("Sends a string to an address from a packet socket and returns the amount of bytes sent"),
+
+	"recvFrom": functiontype.NewNative(func(sc *scope.Scope, st *stack.Stack, callf func(rft functiontype.ReqFunctionType, sc *scope.Scope, st *stack.Stack) error) error {
+		n, err := byteCount(st.Pop())
+		if err != nil {
+			return err
+		}
+
+		pc, err := handleAs[net.PacketConn](st.Pop(), "packet socket")
+		if err != nil {
+			return err
+		}
+
+		buf := make([]byte, n)
+
+		read, from, err := pc.ReadFrom(buf)
+		if err != nil {
+			return err
+		}
+
+		st.Push(stringtype.New(string(buf[:read])), stringtype.New(from.String()))
+
+		return nil
+	}, []types.ReqVarType{types.TypeNumber, types.TypeNative}, []types.ReqVarType{types.TypeString, types.TypeString}).SetDoc("Receives a packet of up to the given amount of bytes on a packet socket; returns the data and the address it came from"),
+
+	"deadline": functiontype.NewNative(func(sc *scope.Scope, st *stack.Stack, callf func(rft functiontype.ReqFunctionType, sc *scope.Scope, st *stack.Stack) error) error {
+		seconds := st.Pop().Literal().(float32)
+
+		h, err := handleAs[interface{ SetDeadline(time.Time) error }](st.Pop(), "connection or packet socket")
+		if err != nil {
+			return err
+		}
+
+		deadline := time.Time{}
+		if seconds > 0 {
+			deadline = time.Now().Add(time.Duration(float64(seconds) * float64(time.Second)))
+		}
+
+		return h.SetDeadline(deadline)
+	}, []types.ReqVarType{types.TypeNumber, types.TypeNative}, []types.ReqVarType{}).SetDoc("Makes reads and writes on a connection or packet socket fail after the given amount of seconds; 0 removes the deadline"),
+
+	"addr": functiontype.NewNative(func(sc *scope.Scope, st *stack.Stack, callf func(rft functiontype.ReqFunctionType, sc *scope.Scope, st *stack.Stack) error) error {
+		v := st.Pop()
+
+		switch h := v.(nativetype.ReqNativeType).Handle().(type) {
+		case net.Listener:
+			st.Push(stringtype.New(h.Addr().String()))
+		case net.Conn:
+			st.Push(stringtype.New(h.LocalAddr().String()))
+		case net.PacketConn:
+			st.Push(stringtype.New(h.LocalAddr().String()))
+		default:
+			return fmt.Errorf("'%s' is not a connection, listener or packet socket", v.String())
+		}
+
+		return nil
+	}, []types.ReqVarType{types.TypeNative}, []types.ReqVarType{types.TypeString}).SetDoc("Returns the local address of a connection, listener or packet socket"),
+
+	"close": functiontype.NewNative(func(sc *scope.Scope, st *stack.Stack, callf func(rft functiontype.ReqFunctionType, sc *scope.Scope, st *stack.Stack) error) error {
+		c, err := handleAs[io.Closer](st.Pop(), "connection, listener or packet socket")
+		if err != nil {
+			return err
+		}
+
+		return c.Close()
+	}, []types.ReqVarType{types.TypeNative}, []types.ReqVarType{}).SetDoc("Closes a connection, listener or packet socket"),
+}
@@ -3,12 +3,10 @@ package stdlib
 import (
 	"errors"
 	"fmt"
-	"io"
-	"net/http"
 	"os"
 	"path/filepath"
+	"slices"
 	"strings"
-	"unsafe"
 
 	"github.com/voidwyrm-2/reqproc/runtime"
 	"github.com/voidwyrm-2/reqproc/runtime/scope"
@@ -16,7 +14,6 @@ import (
 	"github.com/voidwyrm-2/reqproc/runtime/types"
 	"github.com/voidwyrm-2/reqproc/runtime/types/functiontype"
 	"github.com/voidwyrm-2/reqproc/runtime/types/listtype"
-	"github.com/voidwyrm-2/reqproc/runtime/types/nativetype"
 	"github.com/voidwyrm-2/reqproc/runtime/types/numbertype"
 	"github.com/voidwyrm-2/reqproc/runtime/types/stringtype"
 	"github.com/voidwyrm-2/reqproc/runtime/types/tabletype"
@@ -26,6 +23,58 @@ func floatInvalidFor(msg string) error {
 	return errors.New("cannot use float value as " + msg)
 }
 
+// the depth given to pick and roll, checking that there's a value that deep below it
+func stackDepth(st *stack.Stack) (int, error) {
+	v := st.Pop().(numbertype.ReqNumberType)
+	if v.IsFloat() {
+		return 0, floatInvalidFor("stack depth")
+	}
+
+	n := int(v.Literal().(float32))
+	if n < 0 {
+		return 0, fmt.Errorf("stack depth cannot be negative, but found %d", n)
+	} else if n >= st.Len() {
+		return 0, fmt.Errorf("cannot reach %d values deep, there are only %d on the stack", n, st.Len())
+	}
+
+	return n, nil
+}
+
+// calls each function with the same value on top of the stack
+func cleave(sc *scope.Scope, st *stack.Stack, value types.ReqType, fns []types.ReqType, callf func(rft functiontype.ReqFunctionType, sc *scope.Scope, st *stack.Stack) error) error {
+	for _, f := range fns {
+		fn, ok := f.(functiontype.ReqFunctionType)
+		if !ok {
+			return fmt.Errorf("'%s' is not a function", f.String())
+		}
+
+		st.Push(value)
+
+		if err := callf(fn, sc, st); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// natives the combinators are built from, as well as being in __init__
+var (
+	dropNative = functiontype.NewSigNative(func(sc *scope.Scope, st *stack.Stack, callf func(rft functiontype.ReqFunctionType, sc *scope.Scope, st *stack.Stack) error) error {
+		st.Pop()
+
+		return nil
+	}, functiontype.Arity{In: 1, Out: 0}).SetDoc("Takes a value off the stack")
+
+	swapNative = functiontype.NewSigNative(func(sc *scope.Scope, st *stack.Stack, callf func(rft functiontype.ReqFunctionType, sc *scope.Scope, st *stack.Stack) error) error {
+		b, a := st.Pop(), st.Pop()
+
+		st.Push(b, a)
+
+		return nil
+	}, functiontype.Arity{In: 2, Out: 2}).SetDoc("Swaps the top two values of the stack")
+)
+
 /*
 Contains all the natively written functions
 
@@ -39,20 +88,18 @@ var Stdlib = map[string]map[string]types.ReqType{
 
 		// meta
 		"doc": functiontype.NewNative(func(sc *scope.Scope, st *stack.Stack, callf func(rft functiontype.ReqFunctionType, sc *scope.Scope, st *stack.Stack) error) error {
-			f := st.Pop().(functiontype.ReqFunctionType)
-
-			fmt.Println(f.Doc())
+			st.Push(stringtype.New(st.Pop().(functiontype.ReqFunctionType).Doc()))
 
 			return nil
 		}, []types.ReqVarType{types.TypeFunction}, []types.ReqVarType{types.TypeString}).SetDoc("Returns the docstring of the function it's called on"),
 
 		"sig": functiontype.NewNative(func(sc *scope.Scope, st *stack.Stack, callf func(rft functiontype.ReqFunctionType, sc *scope.Scope, st *stack.Stack) error) error {
-			v := st.Pop().(functiontype.ReqFunctionType)
+			arity := st.Pop().(functiontype.ReqFunctionType).Arity()
 
-			st.Push(numbertype.New(v.Signature()))
+			st.Push(listtype.New(numbertype.New(float32(arity.In)), numbertype.New(float32(arity.Out))))
 
 			return nil
-		}, []types.ReqVarType{types.TypeFunction}, []types.ReqVarType{types.TypeNumber}).SetDoc("Returns the I/O signature of the function it's called on"),
+		}, []types.ReqVarType{types.TypeFunction}, []types.ReqVarType{types.TypeList}).SetDoc("Returns the I/O signature of the function it's called on, as a list of the amount of inputs and outputs"),
 
 		// branches/turing/conditionals
 		/*
@@ -85,7 +132,7 @@ var Stdlib = map[string]map[string]types.ReqType{
 			st.Push(result)
 
 			return nil
-		}, 2.1).SetDoc("Adds two values together"),
+		}, functiontype.Arity{In: 2, Out: 1}).SetDoc("Adds two values together"),
 
 		"-": functiontype.NewSigNative(func(sc *scope.Scope, st *stack.Stack, callf func(rft functiontype.ReqFunctionType, sc *scope.Scope, st *stack.Stack) error) error {
 			b := st.Pop()
@@ -98,7 +145,7 @@ var Stdlib = map[string]map[string]types.ReqType{
 			st.Push(result)
 
 			return nil
-		}, 2.1).SetDoc("Subtracts one value from another"),
+		}, functiontype.Arity{In: 2, Out: 1}).SetDoc("Subtracts one value from another"),
 
 		"*": functiontype.NewSigNative(func(sc *scope.Scope, st *stack.Stack, callf func(rft functiontype.ReqFunctionType, sc *scope.Scope, st *stack.Stack) error) error {
 			b := st.Pop()
@@ -111,7 +158,7 @@ var Stdlib = map[string]map[string]types.ReqType{
 			st.Push(result)
 
 			return nil
-		}, 2.1).SetDoc("Multiplies one value with another"),
+		}, functiontype.Arity{In: 2, Out: 1}).SetDoc("Multiplies one value with another"),
 
 		"/": functiontype.NewSigNative(func(sc *scope.Scope, st *stack.Stack, callf func(rft functiontype.ReqFunctionType, sc *scope.Scope, st *stack.Stack) error) error {
 			b := st.Pop()
@@ -124,14 +171,10 @@ var Stdlib = map[string]map[string]types.ReqType{
 			st.Push(result)
 
 			return nil
-		}, 2.1).SetDoc("Divides one value by another"),
+		}, functiontype.Arity{In: 2, Out: 1}).SetDoc("Divides one value by another"),
 
 		// stack operations
-		"drop": functiontype.NewSigNative(func(sc *scope.Scope, st *stack.Stack, callf func(rft functiontype.ReqFunctionType, sc *scope.Scope, st *stack.Stack) error) error {
-			st.Pop()
-
-			return nil
-		}, 1.0).SetDoc("Takes a value off the stack"),
+		"drop": dropNative,
 
 		"dup": functiontype.NewSigNative(func(sc *scope.Scope, st *stack.Stack, callf func(rft functiontype.ReqFunctionType, sc *scope.Scope, st *stack.Stack) error) error {
 			value := st.Pop()
@@ -139,7 +182,7 @@ var Stdlib = map[string]map[string]types.ReqType{
 			st.Push(value, value)
 
 			return nil
-		}, 1.2).SetDoc("Pops a value off the stack then pushes two of that value back onto the stack"),
+		}, functiontype.Arity{In: 1, Out: 2}).SetDoc("Pops a value off the stack then pushes two of that value back onto the stack"),
 
 		"dip": functiontype.NewNative(func(sc *scope.Scope, st *stack.Stack, callf func(rft functiontype.ReqFunctionType, sc *scope.Scope, st *stack.Stack) error) error {
 			f := st.Pop().(functiontype.ReqFunctionType)
@@ -153,7 +196,195 @@ var Stdlib = map[string]map[string]types.ReqType{
 			st.Push(value)
 
 			return nil
-		}, []types.ReqVarType{types.TypeFunction, types.TypeAny}, []types.ReqVarType{types.TypeAny, types.TypeAny}).SetDoc("Pops a value off the stack then calls a function"),
+		}, []types.ReqVarType{types.TypeFunction, types.TypeAny}, []types.ReqVarType{types.TypeAny, types.TypeAny}).SetVariadic().SetDoc("Pops a value off the stack, calls a function, then pushes the value back"),
+
+		"swap": swapNative,
+
+		"over": functiontype.NewSigNative(func(sc *scope.Scope, st *stack.Stack, callf func(rft functiontype.ReqFunctionType, sc *scope.Scope, st *stack.Stack) error) error {
+			b, a := st.Pop(), st.Pop()
+
+			st.Push(a, b, a)
+
+			return nil
+		}, functiontype.Arity{In: 2, Out: 3}).SetDoc("Pushes a copy of the value under the top of the stack"),
+
+		"rot": functiontype.NewSigNative(func(sc *scope.Scope, st *stack.Stack, callf func(rft functiontype.ReqFunctionType, sc *scope.Scope, st *stack.Stack) error) error {
+			c, b, a := st.Pop(), st.Pop(), st.Pop()
+
+			st.Push(b, c, a)
+
+			return nil
+		}, functiontype.Arity{In: 3, Out: 3}).SetDoc("Moves the third value of the stack to the top"),
+
+		"-rot": functiontype.NewSigNative(func(sc *scope.Scope, st *stack.Stack, callf func(rft functiontype.ReqFunctionType, sc *scope.Scope, st *stack.Stack) error) error {
+			c, b, a := st.Pop(), st.Pop(), st.Pop()
+
+			st.Push(c, a, b)
+
+			return nil
+		}, functiontype.Arity{In: 3, Out: 3}).SetDoc("Moves the top value of the stack under the next two, undoing rot"),
+
+		"nip": functiontype.NewSigNative(func(sc *scope.Scope, st *stack.Stack, callf func(rft functiontype.ReqFunctionType, sc *scope.Scope, st *stack.Stack) error) error {
+			b := st.Pop()
+			st.Pop()
+
+			st.Push(b)
+
+			return nil
+		}, functiontype.Arity{In: 2, Out: 1}).SetDoc("Takes the value under the top of the stack off it"),
+
+		"tuck": functiontype.NewSigNative(func(sc *scope.Scope, st *stack.Stack, callf func(rft functiontype.ReqFunctionType, sc *scope.Scope, st *stack.Stack) error) error {
+			b, a := st.Pop(), st.Pop()
+
+			st.Push(b, a, b)
+
+			return nil
+		}, functiontype.Arity{In: 2, Out: 3}).SetDoc("Puts a copy of the top value of the stack under the next one"),
+
+		"2dup": functiontype.NewSigNative(func(sc *scope.Scope, st *stack.Stack, callf func(rft functiontype.ReqFunctionType, sc *scope.Scope, st *stack.Stack) error) error {
+			b, a := st.Pop(), st.Pop()
+
+			st.Push(a, b, a, b)
+
+			return nil
+		}, functiontype.Arity{In: 2, Out: 4}).SetDoc("Pushes copies of the top two values of the stack"),
+
+		"2drop": functiontype.NewSigNative(func(sc *scope.Scope, st *stack.Stack, callf func(rft functiontype.ReqFunctionType, sc *scope.Scope, st *stack.Stack) error) error {
+			st.PopN(2)
+
+			return nil
+		}, functiontype.Arity{In: 2, Out: 0}).SetDoc("Takes two values off the stack"),
+
+		"pick": functiontype.NewNative(func(sc *scope.Scope, st *stack.Stack, callf func(rft functiontype.ReqFunctionType, sc *scope.Scope, st *stack.Stack) error) error {
+			n, err := stackDepth(st)
+			if err != nil {
+				return err
+			}
+
+			st.Push(st.Slice()[st.Len()-1-n])
+
+			return nil
+		}, []types.ReqVarType{types.TypeNumber}, []types.ReqVarType{types.TypeAny}).SetDoc("Pushes a copy of the value the given amount of values deep; `0 pick` is dup and `1 pick` is over"),
+
+		"roll": functiontype.NewNative(func(sc *scope.Scope, st *stack.Stack, callf func(rft functiontype.ReqFunctionType, sc *scope.Scope, st *stack.Stack) error) error {
+			n, err := stackDepth(st)
+			if err != nil {
+				return err
+			}
+
+			values := st.PopN(n + 1)
+
+			st.Push(values[1:]...)
+			st.Push(values[0])
+
+			return nil
+		}, []types.ReqVarType{types.TypeNumber}, []types.ReqVarType{}).SetDoc("Moves the value the given amount of values deep to the top of the stack; `1 roll` is swap and `2 roll` is rot"),
+
+		"keep": functiontype.NewNative(func(sc *scope.Scope, st *stack.Stack, callf func(rft functiontype.ReqFunctionType, sc *scope.Scope, st *stack.Stack) error) error {
+			f := st.Pop().(functiontype.ReqFunctionType)
+			value := st.Slice()[st.Len()-1]
+
+			if err := callf(f, sc, st); err != nil {
+				return err
+			}
+
+			st.Push(value)
+
+			return nil
+		}, []types.ReqVarType{types.TypeFunction, types.TypeAny}, []types.ReqVarType{types.TypeAny}).SetVariadic().SetDoc("Calls a function with the value under it, then pushes the value back"),
+
+		"bi": functiontype.NewNative(func(sc *scope.Scope, st *stack.Stack, callf func(rft functiontype.ReqFunctionType, sc *scope.Scope, st *stack.Stack) error) error {
+			q, p := st.Pop(), st.Pop()
+
+			return cleave(sc, st, st.Pop(), []types.ReqType{p, q}, callf)
+		}, []types.ReqVarType{types.TypeFunction, types.TypeFunction, types.TypeAny}, []types.ReqVarType{}).SetVariadic().SetDoc("Calls two functions with the same value, e.g. `x p q bi` is `x p x q`"),
+
+		"tri": functiontype.NewNative(func(sc *scope.Scope, st *stack.Stack, callf func(rft functiontype.ReqFunctionType, sc *scope.Scope, st *stack.Stack) error) error {
+			r, q, p := st.Pop(), st.Pop(), st.Pop()
+
+			return cleave(sc, st, st.Pop(), []types.ReqType{p, q, r}, callf)
+		}, []types.ReqVarType{types.TypeFunction, types.TypeFunction, types.TypeFunction, types.TypeAny}, []types.ReqVarType{}).SetVariadic().SetDoc("Calls three functions with the same value, e.g. `x p q r tri` is `x p x q x r`"),
+
+		"cleave": functiontype.NewNative(func(sc *scope.Scope, st *stack.Stack, callf func(rft functiontype.ReqFunctionType, sc *scope.Scope, st *stack.Stack) error) error {
+			fns := st.Pop().Literal().([]types.ReqType)
+
+			return cleave(sc, st, st.Pop(), fns, callf)
+		}, []types.ReqVarType{types.TypeList, types.TypeAny}, []types.ReqVarType{}).SetVariadic().SetDoc("Calls each function in a list with the same value"),
+
+		"spread": functiontype.NewNative(func(sc *scope.Scope, st *stack.Stack, callf func(rft functiontype.ReqFunctionType, sc *scope.Scope, st *stack.Stack) error) error {
+			fns := st.Pop().Literal().([]types.ReqType)
+
+			if len(fns) > st.Len() {
+				return fmt.Errorf("expected %d values to spread but found %d", len(fns), st.Len())
+			}
+
+			for i, v := range st.PopN(len(fns)) {
+				if err := cleave(sc, st, v, fns[i:i+1], callf); err != nil {
+					return err
+				}
+			}
+
+			return nil
+		}, []types.ReqVarType{types.TypeList}, []types.ReqVarType{}).SetVariadic().SetDoc("Calls each function in a list with its own value, e.g. `x y [p q] spread` is `x p y q`"),
+
+		"clear": functiontype.NewNative(func(sc *scope.Scope, st *stack.Stack, callf func(rft functiontype.ReqFunctionType, sc *scope.Scope, st *stack.Stack) error) error {
+			st.PopN(st.Len())
+
+			return nil
+		}, []types.ReqVarType{}, []types.ReqVarType{}).SetVariadic().SetDoc("Takes every value off the stack"),
+
+		"depth": functiontype.NewNative(func(sc *scope.Scope, st *stack.Stack, callf func(rft functiontype.ReqFunctionType, sc *scope.Scope, st *stack.Stack) error) error {
+			st.Push(numbertype.New(float32(st.Len())))
+
+			return nil
+		}, []types.ReqVarType{}, []types.ReqVarType{types.TypeNumber}).SetDoc("Returns the amount of values on the stack"),
+
+		// functions
+		"identity": functiontype.NewSigNative(func(sc *scope.Scope, st *stack.Stack, callf func(rft functiontype.ReqFunctionType, sc *scope.Scope, st *stack.Stack) error) error {
+			return nil
+		}, functiontype.Arity{In: 1, Out: 1}).SetDoc("Returns the value it's given"),
+
+		"const": functiontype.NewNative(func(sc *scope.Scope, st *stack.Stack, callf func(rft functiontype.ReqFunctionType, sc *scope.Scope, st *stack.Stack) error) error {
+			st.Push(functiontype.NewComposite(functiontype.Part{Value: dropNative, Call: true}, functiontype.Part{Value: st.Pop()}))
+
+			return nil
+		}, []types.ReqVarType{types.TypeAny}, []types.ReqVarType{types.TypeFunction}).SetDoc("Returns a function which drops the value it's given and returns this value instead"),
+
+		"curry": functiontype.NewNative(func(sc *scope.Scope, st *stack.Stack, callf func(rft functiontype.ReqFunctionType, sc *scope.Scope, st *stack.Stack) error) error {
+			f, value := st.Pop(), st.Pop()
+
+			st.Push(functiontype.NewComposite(functiontype.Part{Value: value}, functiontype.Part{Value: f, Call: true}))
+
+			return nil
+		}, []types.ReqVarType{types.TypeFunction, types.TypeAny}, []types.ReqVarType{types.TypeFunction}).SetDoc("Returns a function which calls a function with a value already pushed, e.g. `5 @+ curry` adds 5 to its input"),
+
+		"compose": functiontype.NewNative(func(sc *scope.Scope, st *stack.Stack, callf func(rft functiontype.ReqFunctionType, sc *scope.Scope, st *stack.Stack) error) error {
+			g, f := st.Pop(), st.Pop()
+
+			st.Push(functiontype.NewComposite(functiontype.Part{Value: f, Call: true}, functiontype.Part{Value: g, Call: true}))
+
+			return nil
+		}, []types.ReqVarType{types.TypeFunction, types.TypeFunction}, []types.ReqVarType{types.TypeFunction}).SetDoc("Returns a function which calls one function, then another"),
+
+		"flip": functiontype.NewNative(func(sc *scope.Scope, st *stack.Stack, callf func(rft functiontype.ReqFunctionType, sc *scope.Scope, st *stack.Stack) error) error {
+			st.Push(functiontype.NewComposite(functiontype.Part{Value: swapNative, Call: true}, functiontype.Part{Value: st.Pop(), Call: true}))
+
+			return nil
+		}, []types.ReqVarType{types.TypeFunction}, []types.ReqVarType{types.TypeFunction}).SetDoc("Returns a function which calls a function with its top two inputs swapped"),
+
+		"function": functiontype.NewNative(func(sc *scope.Scope, st *stack.Stack, callf func(rft functiontype.ReqFunctionType, sc *scope.Scope, st *stack.Stack) error) error {
+			items := st.Pop().Literal().([]types.ReqType)
+			parts := make([]functiontype.Part, 0, len(items))
+
+			for _, v := range items {
+				parts = append(parts, functiontype.Part{Value: v, Call: v.Type() == types.TypeFunction})
+			}
+
+			st.Push(functiontype.NewComposite(parts...))
+
+			return nil
+		}, []types.ReqVarType{types.TypeList}, []types.ReqVarType{types.TypeFunction}).SetDoc("Creates a function from a list, which calls the functions in it and pushes the other values in order"),
+
 		"range": functiontype.NewNative(func(sc *scope.Scope, st *stack.Stack, callf func(rft functiontype.ReqFunctionType, sc *scope.Scope, st *stack.Stack) error) error {
 			v := st.Pop().(numbertype.ReqNumberType)
 
@@ -173,6 +404,18 @@ var Stdlib = map[string]map[string]types.ReqType{
 
 			return nil
 		}, []types.ReqVarType{types.TypeNumber}, []types.ReqVarType{types.TypeList}),
+
+		// tables
+		"table": functiontype.NewNative(func(sc *scope.Scope, st *stack.Stack, callf func(rft functiontype.ReqFunctionType, sc *scope.Scope, st *stack.Stack) error) error {
+			tbl, err := tabletype.FromPairs(st.Pop().Literal().([]types.ReqType))
+			if err != nil {
+				return err
+			}
+
+			st.Push(tbl)
+
+			return nil
+		}, []types.ReqVarType{types.TypeList}, []types.ReqVarType{types.TypeTable}).SetDoc("Creates a table from a list of alternating keys and values"),
 	},
 	"runtime": {
 		"version": functiontype.NewNative(func(sc *scope.Scope, st *stack.Stack, callf func(rft functiontype.ReqFunctionType, sc *scope.Scope, st *stack.Stack) error) error {
@@ -185,13 +428,41 @@ var Stdlib = map[string]map[string]types.ReqType{
 
 			return nil
 		}, []types.ReqVarType{}, []types.ReqVarType{types.TypeNumber}).SetDoc("Returns the length of the stack"),
+
+		"exports": functiontype.NewNative(func(sc *scope.Scope, st *stack.Stack, callf func(rft functiontype.ReqFunctionType, sc *scope.Scope, st *stack.Stack) error) error {
+			exports, err := sc.Exports()
+			if err != nil {
+				return err
+			}
+
+			sorted := make([]string, 0, len(exports))
+			for n := range exports {
+				sorted = append(sorted, n)
+			}
+
+			slices.Sort(sorted)
+
+			names := []types.ReqType{}
+			for _, n := range sorted {
+				names = append(names, stringtype.New(n))
+			}
+
+			st.Push(listtype.New(names...))
+
+			return nil
+		}, []types.ReqVarType{}, []types.ReqVarType{types.TypeList}).SetDoc("Returns a sorted list of the names the current file would export if it were imported"),
 	},
 	"os": {
 		"fs": tabletype.New(map[string]types.ReqType{
 			"items": functiontype.NewNative(func(sc *scope.Scope, st *stack.Stack, callf func(rft functiontype.ReqFunctionType, sc *scope.Scope, st *stack.Stack) error) error {
 				path := st.Pop().(stringtype.ReqStringType)
 
-				items, err := os.ReadDir(filepath.Clean(path.Literal().(string)))
+				p, err := sc.Env().GetSandbox().Path(filepath.Clean(path.Literal().(string)))
+				if err != nil {
+					return err
+				}
+
+				items, err := os.ReadDir(p)
 				if err != nil {
 					return err
 				}
@@ -210,34 +481,40 @@ var Stdlib = map[string]map[string]types.ReqType{
 	},
 	"io": {
 		"put": functiontype.NewSigNative(func(sc *scope.Scope, st *stack.Stack, callf func(rft functiontype.ReqFunctionType, sc *scope.Scope, st *stack.Stack) error) error {
-			fmt.Print(st.Pop())
+			fmt.Fprint(sc.Env().Out(), st.Pop())
 			return nil
-		}, 1.0),
+		}, functiontype.Arity{In: 1, Out: 0}).SetDoc("Prints a value"),
 
 		"putl": functiontype.NewSigNative(func(sc *scope.Scope, st *stack.Stack, callf func(rft functiontype.ReqFunctionType, sc *scope.Scope, st *stack.Stack) error) error {
-			fmt.Println(st.Pop())
+			fmt.Fprintln(sc.Env().Out(), st.Pop())
 			return nil
-		}, 1.0),
+		}, functiontype.Arity{In: 1, Out: 0}).SetDoc("Prints a value followed by a newline"),
 
 		"dump": functiontype.NewSigNative(func(sc *scope.Scope, st *stack.Stack, callf func(rft functiontype.ReqFunctionType, sc *scope.Scope, st *stack.Stack) error) error {
 			values := st.Slice()
+			out := sc.Env().Out()
 
-			fmt.Println("stack contents:")
+			fmt.Fprintln(out, "stack contents:")
 			for i := len(values) - 1; i > -1; i-- {
 				if i == len(values)-1 {
-					fmt.Printf(" [top] %d: %s\n", i, values[i].String())
+					fmt.Fprintf(out, " [top] %d: %s\n", i, values[i].String())
 				} else if i == 0 {
-					fmt.Printf(" [bottom] %d: %s\n", i, values[i].String())
+					fmt.Fprintf(out, " [bottom] %d: %s\n", i, values[i].String())
 				} else {
-					fmt.Printf(" %d: %s\n", i, values[i].String())
+					fmt.Fprintf(out, " %d: %s\n", i, values[i].String())
 				}
 			}
 
 			return nil
-		}, 0.0).SetDoc("Dumps the entire stack"),
+		}, functiontype.Arity{In: 0, Out: 0}).SetDoc("Dumps the entire stack"),
 
 		"readf": functiontype.NewNative(func(sc *scope.Scope, st *stack.Stack, callf func(rft functiontype.ReqFunctionType, sc *scope.Scope, st *stack.Stack) error) error {
-			content, err := os.ReadFile(st.Pop().Literal().(string))
+			p, err := sc.Env().GetSandbox().Path(st.Pop().Literal().(string))
+			if err != nil {
+				return err
+			}
+
+			content, err := os.ReadFile(p)
 			if err != nil {
 				return err
 			}
@@ -250,40 +527,26 @@ var Stdlib = map[string]map[string]types.ReqType{
 		"writef": functiontype.NewNative(func(sc *scope.Scope, st *stack.Stack, callf func(rft functiontype.ReqFunctionType, sc *scope.Scope, st *stack.Stack) error) error {
 			content := st.Pop().Literal().(string)
 
-			file, err := os.Create(st.Pop().Literal().(string))
-			if err != nil {
-				return err
-			}
-
-			defer file.Close()
-
-			_, err = file.WriteString(content)
-			return err
-		}, []types.ReqVarType{types.TypeString, types.TypeString}, []types.ReqVarType{}),
-	},
-	"web": {
-		"download": functiontype.NewNative(func(sc *scope.Scope, st *stack.Stack, callf func(rft functiontype.ReqFunctionType, sc *scope.Scope, st *stack.Stack) error) error {
-			resp, err := http.Get(st.Pop().Literal().(string))
+			p, err := sc.Env().GetSandbox().Path(st.Pop().Literal().(string))
 			if err != nil {
 				return err
 			}
 
-			defer resp.Body.Close()
-
-			if resp.StatusCode != http.StatusOK {
-				return fmt.Errorf("status code %d, '%s'\n", resp.StatusCode, resp.Status)
-			}
-
-			content, err := io.ReadAll(resp.Body)
+			file, err := os.Create(p)
 			if err != nil {
 				return err
 			}
 
-			st.Push(stringtype.New(string(content)))
+			defer file.Close()
 
-			return nil
-		}, []types.ReqVarType{types.TypeString}, []types.ReqVarType{types.TypeString}),
+			_, err = file.WriteString(content)
+			return err
+		}, []types.ReqVarType{types.TypeString, types.TypeString}, []types.ReqVarType{}),
 	},
+	"web":  webModule,
+	"net":  netModule,
+	"task": taskModule,
+	"ffi":  ffiModule,
 	"strings": {
 		"split": functiontype.NewNative(func(sc *scope.Scope, st *stack.Stack, callf func(rft functiontype.ReqFunctionType, sc *scope.Scope, st *stack.Stack) error) error {
 			delim := st.Pop().Literal().(string)
@@ -301,13 +564,4 @@ var Stdlib = map[string]map[string]types.ReqType{
 			return nil
 		}, []types.ReqVarType{types.TypeString, types.TypeString}, []types.ReqVarType{types.TypeString}),
 	},
-	"ffi": {
-		"toNative": functiontype.NewSigNative(func(sc *scope.Scope, st *stack.Stack, callf func(rft functiontype.ReqFunctionType, sc *scope.Scope, st *stack.Stack) error) error {
-			val := st.Pop().Literal()
-
-			st.Push(nativetype.New(unsafe.Pointer(&val)))
-
-			return nil
-		}, 1.1),
-	},
 }
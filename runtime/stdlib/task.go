@@ -0,0 +1,374 @@
+package stdlib
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+
+	"github.com/voidwyrm-2/reqproc/runtime/scope"
+	"github.com/voidwyrm-2/reqproc/runtime/stack"
+	"github.com/voidwyrm-2/reqproc/runtime/types"
+	"github.com/voidwyrm-2/reqproc/runtime/types/functiontype"
+	"github.com/voidwyrm-2/reqproc/runtime/types/listtype"
+	"github.com/voidwyrm-2/reqproc/runtime/types/nativetype"
+	"github.com/voidwyrm-2/reqproc/runtime/types/numbertype"
+	"github.com/voidwyrm-2/reqproc/runtime/types/tabletype"
+)
+
+/*
+The memory model for tasks:
+
+  - strings and numbers are immutable, so they're shared as-is
+  - lists and tables are copied whenever they're given to a task, sent through a channel or returned from a task
+  - functions are shared, but the scopes they were defined in are copied with them, like lists and tables
+  - channels and tasks are shared
+  - any other native values cannot cross between tasks, except in the scopes which are copied, where they're kept as-is
+
+tasks run in isolated copies of the scope they were spawned from, which are made before they start,
+so they read the globals as they were when they were spawned, and cannot reassign them
+*/
+type snapshot map[*scope.Scope]*scope.Scope
+
+func (s snapshot) share(v types.ReqType) (types.ReqType, error) {
+	return s.copy(v, false)
+}
+
+func (s snapshot) shareAll(values []types.ReqType) ([]types.ReqType, error) {
+	shared := make([]types.ReqType, 0, len(values))
+
+	for _, v := range values {
+		c, err := s.share(v)
+		if err != nil {
+			return nil, err
+		}
+
+		shared = append(shared, c)
+	}
+
+	return shared, nil
+}
+
+// copies a scope and everything in it, keeping the native values
+func (s snapshot) scope(sc *scope.Scope) *scope.Scope {
+	return sc.Copy(s, func(v types.ReqType) types.ReqType {
+		c, _ := s.copy(v, true)
+		return c
+	})
+}
+
+// natives keeps native values which can't be shared as they are, instead of being an error
+func (s snapshot) copy(v types.ReqType, natives bool) (types.ReqType, error) {
+	switch v.Type() {
+	case types.TypeList:
+		items := v.Literal().([]types.ReqType)
+		copied := make([]types.ReqType, 0, len(items))
+
+		for _, item := range items {
+			c, err := s.copy(item, natives)
+			if err != nil {
+				return nil, err
+			}
+
+			copied = append(copied, c)
+		}
+
+		return listtype.New(copied...), nil
+	case types.TypeTable:
+		m := map[string]types.ReqType{}
+
+		for k, item := range v.Literal().(map[string]types.ReqType) {
+			c, err := s.copy(item, natives)
+			if err != nil {
+				return nil, err
+			}
+
+			m[k] = c
+		}
+
+		return tabletype.New(m), nil
+	case types.TypeFunction:
+		rft := v.(functiontype.ReqFunctionType)
+
+		if closure := rft.Scope(); closure != nil {
+			rft = rft.SetScope(s.scope(closure))
+		}
+
+		if parts := rft.Parts(); parts != nil {
+			copied := make([]functiontype.Part, 0, len(parts))
+
+			for _, p := range parts {
+				c, err := s.copy(p.Value, natives)
+				if err != nil {
+					return nil, err
+				}
+
+				copied = append(copied, functiontype.Part{Value: c, Call: p.Call})
+			}
+
+			rft = rft.SetParts(copied)
+		}
+
+		return rft, nil
+	case types.TypeNative:
+		switch v.(nativetype.ReqNativeType).Handle().(type) {
+		case *reqChannel, *reqTask:
+			return v, nil
+		}
+
+		if natives {
+			return v, nil
+		}
+
+		return nil, fmt.Errorf("cannot share native value '%s' between tasks", v.String())
+	default:
+		return v, nil
+	}
+}
+
+type reqChannel struct {
+	c chan types.ReqType
+}
+
+func (ch *reqChannel) send(v types.ReqType) (err error) {
+	defer func() {
+		if recover() != nil {
+			err = errors.New("cannot send on a closed channel")
+		}
+	}()
+
+	ch.c <- v
+
+	return nil
+}
+
+func (ch *reqChannel) close() (err error) {
+	defer func() {
+		if recover() != nil {
+			err = errors.New("channel is already closed")
+		}
+	}()
+
+	close(ch.c)
+
+	return nil
+}
+
+type reqTask struct {
+	done    chan struct{}
+	results []types.ReqType
+	err     error
+}
+
+func (t *reqTask) wait(ctx context.Context) ([]types.ReqType, error) {
+	select {
+	case <-t.done:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+
+	if t.err != nil {
+		return nil, t.err
+	}
+
+	return snapshot{}.shareAll(t.results)
+}
+
+// the task's copies of fn, args and sc are made by s before it starts, so they can't be changed while it reads them
+func spawnTask(sc *scope.Scope, s snapshot, fn functiontype.ReqFunctionType, args []types.ReqType, callf func(rft functiontype.ReqFunctionType, sc *scope.Scope, st *stack.Stack) error) *reqTask {
+	t := &reqTask{done: make(chan struct{})}
+
+	// the task is part of the run that spawned it, even once that run is over
+	e := sc.Env()
+	release := e.Hold()
+
+	isolated := scope.NewIsolated(s.scope(sc))
+	isolated.SetEnv(e)
+
+	go func() {
+		defer close(t.done)
+		defer release()
+
+		defer func() {
+			if r := recover(); r != nil {
+				t.err = fmt.Errorf("task panicked: %v", r)
+			}
+		}()
+
+		st := stack.New(args...)
+		e.Track(&st)
+		defer st.Release()
+
+		if t.err = callf(fn, isolated, &st); t.err == nil {
+			t.results = st.Slice()
+		}
+	}()
+
+	return t
+}
+
+var taskModule = map[string]types.ReqType{
+	"spawn": functiontype.NewNative(func(sc *scope.Scope, st *stack.Stack, callf func(rft functiontype.ReqFunctionType, sc *scope.Scope, st *stack.Stack) error) error {
+		fn := st.Pop().(functiontype.ReqFunctionType)
+
+		if err := st.Expect(fn.Input()...); err != nil {
+			return err
+		}
+
+		s := snapshot{}
+
+		args, err := s.shareAll(st.PopN(len(fn.Input())))
+		if err != nil {
+			return err
+		}
+
+		shared, err := s.share(fn)
+		if err != nil {
+			return err
+		}
+
+		st.Push(nativetype.NewHandle("task", spawnTask(sc, s, shared.(functiontype.ReqFunctionType), args, callf)))
+
+		return nil
+	}, []types.ReqVarType{types.TypeFunction}, []types.ReqVarType{types.TypeNative}).SetVariadic().SetDoc("Runs a function in the background with its inputs taken off the stack and returns the task"),
+
+	"wait": functiontype.NewNative(func(sc *scope.Scope, st *stack.Stack, callf func(rft functiontype.ReqFunctionType, sc *scope.Scope, st *stack.Stack) error) error {
+		t, err := handleAs[*reqTask](st.Pop(), "task")
+		if err != nil {
+			return err
+		}
+
+		results, err := t.wait(sc.Env().Ctx())
+		if err != nil {
+			return err
+		}
+
+		st.Push(results...)
+
+		return nil
+	}, []types.ReqVarType{types.TypeNative}, []types.ReqVarType{}).SetVariadic().SetDoc("Waits for a task to finish and pushes the values it left on its stack"),
+
+	"join": functiontype.NewNative(func(sc *scope.Scope, st *stack.Stack, callf func(rft functiontype.ReqFunctionType, sc *scope.Scope, st *stack.Stack) error) error {
+		all := []types.ReqType{}
+
+		for _, v := range st.Pop().Literal().([]types.ReqType) {
+			if v.Type() != types.TypeNative {
+				return fmt.Errorf("'%s' is not a task", v.String())
+			}
+
+			t, err := handleAs[*reqTask](v, "task")
+			if err != nil {
+				return err
+			}
+
+			results, err := t.wait(sc.Env().Ctx())
+			if err != nil {
+				return err
+			}
+
+			all = append(all, results...)
+		}
+
+		st.Push(listtype.New(all...))
+
+		return nil
+	}, []types.ReqVarType{types.TypeList}, []types.ReqVarType{types.TypeList}).SetDoc("Waits for a list of tasks to finish and returns a list of the values they left, in order"),
+
+	"chan": functiontype.NewNative(func(sc *scope.Scope, st *stack.Stack, callf func(rft functiontype.ReqFunctionType, sc *scope.Scope, st *stack.Stack) error) error {
+		size := st.Pop().(numbertype.ReqNumberType)
+		if size.IsFloat() {
+			return floatInvalidFor("channel capacity")
+		}
+
+		st.Push(nativetype.NewHandle("channel", &reqChannel{c: make(chan types.ReqType, int(size.Literal().(float32)))}))
+
+		return nil
+	}, []types.ReqVarType{types.TypeNumber}, []types.ReqVarType{types.TypeNative}).SetDoc("Creates a channel which can buffer the given amount of values"),
+
+	"send": functiontype.NewNative(func(sc *scope.Scope, st *stack.Stack, callf func(rft functiontype.ReqFunctionType, sc *scope.Scope, st *stack.Stack) error) error {
+		v, err := snapshot{}.share(st.Pop())
+		if err != nil {
+			return err
+		}
+
+		ch, err := handleAs[*reqChannel](st.Pop(), "channel")
+		if err != nil {
+			return err
+		}
+
+		return ch.send(v)
+	}, []types.ReqVarType{types.TypeAny, types.TypeNative}, []types.ReqVarType{}).SetDoc("Sends a value through a channel, waiting until there's room for it"),
+
+	"recv": functiontype.NewNative(func(sc *scope.Scope, st *stack.Stack, callf func(rft functiontype.ReqFunctionType, sc *scope.Scope, st *stack.Stack) error) error {
+		ch, err := handleAs[*reqChannel](st.Pop(), "channel")
+		if err != nil {
+			return err
+		}
+
+		ctx := sc.Env().Ctx()
+
+		var v types.ReqType
+		var ok bool
+
+		select {
+		case v, ok = <-ch.c:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+
+		if !ok {
+			return errors.New("channel is closed")
+		}
+
+		st.Push(v)
+
+		return nil
+	}, []types.ReqVarType{types.TypeNative}, []types.ReqVarType{types.TypeAny}).SetDoc("Waits for a value from a channel; errors once the channel is closed and empty"),
+
+	"close": functiontype.NewNative(func(sc *scope.Scope, st *stack.Stack, callf func(rft functiontype.ReqFunctionType, sc *scope.Scope, st *stack.Stack) error) error {
+		ch, err := handleAs[*reqChannel](st.Pop(), "channel")
+		if err != nil {
+			return err
+		}
+
+		return ch.close()
+	}, []types.ReqVarType{types.TypeNative}, []types.ReqVarType{}).SetDoc("Closes a channel"),
+
+	"select": functiontype.NewNative(func(sc *scope.Scope, st *stack.Stack, callf func(rft functiontype.ReqFunctionType, sc *scope.Scope, st *stack.Stack) error) error {
+		chans := st.Pop().Literal().([]types.ReqType)
+		cases := make([]reflect.SelectCase, 0, len(chans))
+
+		for _, v := range chans {
+			if v.Type() != types.TypeNative {
+				return fmt.Errorf("'%s' is not a channel", v.String())
+			}
+
+			ch, err := handleAs[*reqChannel](v, "channel")
+			if err != nil {
+				return err
+			}
+
+			cases = append(cases, reflect.SelectCase{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(ch.c)})
+		}
+
+		// the last case is the context being cancelled
+		ctx := sc.Env().Ctx()
+		cases = append(cases, reflect.SelectCase{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(ctx.Done())})
+
+		// closed channels are dropped from the selection until there's none left
+		for open := len(chans); open > 0; {
+			i, v, ok := reflect.Select(cases)
+			if i == len(chans) {
+				return ctx.Err()
+			} else if ok {
+				st.Push(v.Interface().(types.ReqType), numbertype.New(float32(i)))
+				return nil
+			}
+
+			cases[i].Chan = reflect.Value{}
+			open--
+		}
+
+		return errors.New("all channels are closed")
+	}, []types.ReqVarType{types.TypeList}, []types.ReqVarType{types.TypeNumber, types.TypeAny}).SetDoc("Waits for a value from any channel in a list; returns the value and the index of the channel it came from"),
+}
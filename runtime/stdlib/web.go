@@ -0,0 +1,409 @@
+package stdlib
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/voidwyrm-2/reqproc/runtime/env"
+	"github.com/voidwyrm-2/reqproc/runtime/scope"
+	"github.com/voidwyrm-2/reqproc/runtime/stack"
+	"github.com/voidwyrm-2/reqproc/runtime/types"
+	"github.com/voidwyrm-2/reqproc/runtime/types/functiontype"
+	"github.com/voidwyrm-2/reqproc/runtime/types/nativetype"
+	"github.com/voidwyrm-2/reqproc/runtime/types/numbertype"
+	"github.com/voidwyrm-2/reqproc/runtime/types/stringtype"
+	"github.com/voidwyrm-2/reqproc/runtime/types/tabletype"
+)
+
+// checks every request against the sandbox's hosts, including redirects
+type sandboxedTransport struct {
+	sandbox *env.Sandbox
+	next    http.RoundTripper
+}
+
+func (t sandboxedTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	addr := req.URL.Host
+	if req.URL.Port() == "" {
+		port := "80"
+		if req.URL.Scheme == "https" {
+			port = "443"
+		}
+
+		addr = net.JoinHostPort(req.URL.Hostname(), port)
+	}
+
+	if err := t.sandbox.CheckHost(addr); err != nil {
+		return nil, err
+	}
+
+	return t.next.RoundTrip(req)
+}
+
+func roundTripper(sc *scope.Scope) http.RoundTripper {
+	e := sc.Env()
+	if e == nil {
+		return http.DefaultTransport
+	} else if s := e.GetSandbox(); s != nil {
+		return sandboxedTransport{sandbox: s, next: e.RoundTripper()}
+	}
+
+	return e.RoundTripper()
+}
+
+// converts strings and numbers into strings for use in headers and query parameters
+func webString(key string, v types.ReqType) (string, error) {
+	switch v.Type() {
+	case types.TypeString, types.TypeNumber:
+		return v.String(), nil
+	default:
+		return "", fmt.Errorf("expected a string or number for '%s', but found '%s(type %s)'", key, v.String(), v.Type().String())
+	}
+}
+
+// calls fn for each value under key, lists are flattened so a key can be given multiple times
+func eachWebValue(m map[string]types.ReqType, fn func(key, value string)) error {
+	for k, v := range m {
+		values := []types.ReqType{v}
+		if v.Type() == types.TypeList {
+			values = v.Literal().([]types.ReqType)
+		}
+
+		for _, item := range values {
+			s, err := webString(k, item)
+			if err != nil {
+				return err
+			}
+
+			fn(k, s)
+		}
+	}
+
+	return nil
+}
+
+func optionOfType(opts map[string]types.ReqType, key string, kind types.ReqVarType) (types.ReqType, bool, error) {
+	v, ok := opts[key]
+	if !ok {
+		return nil, false, nil
+	} else if v.Type() != kind {
+		return nil, false, fmt.Errorf("expected a %s for request option '%s', but found '%s(type %s)'", kind.String(), key, v.String(), v.Type().String())
+	}
+
+	return v, true, nil
+}
+
+// converts a value into something encoding/json understands
+func toJSONValue(v types.ReqType) (any, error) {
+	switch v.Type() {
+	case types.TypeString, types.TypeNumber:
+		return v.Literal(), nil
+	case types.TypeList:
+		items := []any{}
+
+		for _, item := range v.Literal().([]types.ReqType) {
+			j, err := toJSONValue(item)
+			if err != nil {
+				return nil, err
+			}
+
+			items = append(items, j)
+		}
+
+		return items, nil
+	case types.TypeTable:
+		m := map[string]any{}
+
+		for k, item := range v.Literal().(map[string]types.ReqType) {
+			j, err := toJSONValue(item)
+			if err != nil {
+				return nil, err
+			}
+
+			m[k] = j
+		}
+
+		return m, nil
+	default:
+		return nil, fmt.Errorf("cannot encode type '%s' as JSON", v.Type().String())
+	}
+}
+
+/*
+Performs the request described by opts and returns the response as a table
+
+opts can contain:
+  - method: string, defaults to GET
+  - url: string, required
+  - query: table of strings, numbers or lists of either
+  - headers: table of strings, numbers or lists of either
+  - body: string
+  - json: any value, encoded as the body with a JSON Content-Type unless headers has one
+  - timeout: number of seconds
+  - redirects: the maximum amount of redirects to follow, 0 disables following them
+  - jar: a cookie jar made with web.jar
+*/
+func doRequest(sc *scope.Scope, opts map[string]types.ReqType) (tabletype.ReqTableType, error) {
+	method := http.MethodGet
+	if v, ok, err := optionOfType(opts, "method", types.TypeString); err != nil {
+		return tabletype.ReqTableType{}, err
+	} else if ok {
+		method = strings.ToUpper(v.Literal().(string))
+	}
+
+	rawurl, ok, err := optionOfType(opts, "url", types.TypeString)
+	if err != nil {
+		return tabletype.ReqTableType{}, err
+	} else if !ok {
+		return tabletype.ReqTableType{}, errors.New("request option 'url' is required")
+	}
+
+	u, err := url.Parse(rawurl.Literal().(string))
+	if err != nil {
+		return tabletype.ReqTableType{}, err
+	}
+
+	if v, ok, err := optionOfType(opts, "query", types.TypeTable); err != nil {
+		return tabletype.ReqTableType{}, err
+	} else if ok {
+		query := u.Query()
+
+		if err = eachWebValue(v.Literal().(map[string]types.ReqType), query.Add); err != nil {
+			return tabletype.ReqTableType{}, err
+		}
+
+		u.RawQuery = query.Encode()
+	}
+
+	var body io.Reader
+	contentType := ""
+
+	if v, ok, err := optionOfType(opts, "body", types.TypeString); err != nil {
+		return tabletype.ReqTableType{}, err
+	} else if ok {
+		body = strings.NewReader(v.Literal().(string))
+	}
+
+	if v, ok := opts["json"]; ok {
+		if body != nil {
+			return tabletype.ReqTableType{}, errors.New("a request cannot have both a 'body' and a 'json' option")
+		}
+
+		j, err := toJSONValue(v)
+		if err != nil {
+			return tabletype.ReqTableType{}, err
+		}
+
+		encoded, err := json.Marshal(j)
+		if err != nil {
+			return tabletype.ReqTableType{}, err
+		}
+
+		body = bytes.NewReader(encoded)
+		contentType = "application/json"
+	}
+
+	req, err := http.NewRequestWithContext(sc.Env().Ctx(), method, u.String(), body)
+	if err != nil {
+		return tabletype.ReqTableType{}, err
+	}
+
+	if v, ok, err := optionOfType(opts, "headers", types.TypeTable); err != nil {
+		return tabletype.ReqTableType{}, err
+	} else if ok {
+		if err = eachWebValue(v.Literal().(map[string]types.ReqType), req.Header.Add); err != nil {
+			return tabletype.ReqTableType{}, err
+		}
+	}
+
+	// a Content-Type from the headers option takes the place of the default one
+	if contentType != "" && req.Header.Get("Content-Type") == "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+
+	client := &http.Client{Transport: roundTripper(sc)}
+
+	if v, ok, err := optionOfType(opts, "timeout", types.TypeNumber); err != nil {
+		return tabletype.ReqTableType{}, err
+	} else if ok {
+		client.Timeout = time.Duration(float64(v.Literal().(float32)) * float64(time.Second))
+	}
+
+	if v, ok, err := optionOfType(opts, "redirects", types.TypeNumber); err != nil {
+		return tabletype.ReqTableType{}, err
+	} else if ok {
+		if v.(numbertype.ReqNumberType).IsFloat() {
+			return tabletype.ReqTableType{}, floatInvalidFor("redirect limit")
+		}
+
+		limit := int(v.Literal().(float32))
+
+		client.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+			if len(via) > limit {
+				return http.ErrUseLastResponse
+			}
+
+			return nil
+		}
+	}
+
+	if v, ok, err := optionOfType(opts, "jar", types.TypeNative); err != nil {
+		return tabletype.ReqTableType{}, err
+	} else if ok {
+		jar, ok := v.(nativetype.ReqNativeType).Handle().(http.CookieJar)
+		if !ok {
+			return tabletype.ReqTableType{}, fmt.Errorf("'%s' is not a cookie jar", v.String())
+		}
+
+		client.Jar = jar
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return tabletype.ReqTableType{}, err
+	}
+
+	defer resp.Body.Close()
+
+	content, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return tabletype.ReqTableType{}, err
+	}
+
+	headers := map[string]types.ReqType{}
+	for k, v := range resp.Header {
+		headers[k] = stringtype.New(strings.Join(v, ", "))
+	}
+
+	return tabletype.New(map[string]types.ReqType{
+		"status":     numbertype.New(float32(resp.StatusCode)),
+		"statusText": stringtype.New(resp.Status),
+		"headers":    tabletype.New(headers),
+		"body":       stringtype.New(string(content)),
+		"url":        stringtype.New(resp.Request.URL.String()),
+	}), nil
+}
+
+func pushRequest(sc *scope.Scope, st *stack.Stack, opts map[string]types.ReqType) error {
+	resp, err := doRequest(sc, opts)
+	if err != nil {
+		return err
+	}
+
+	st.Push(resp)
+
+	return nil
+}
+
+var webModule = map[string]types.ReqType{
+	"request": functiontype.NewNative(func(sc *scope.Scope, st *stack.Stack, callf func(rft functiontype.ReqFunctionType, sc *scope.Scope, st *stack.Stack) error) error {
+		return pushRequest(sc, st, st.Pop().Literal().(map[string]types.ReqType))
+	}, []types.ReqVarType{types.TypeTable}, []types.ReqVarType{types.TypeTable}).SetDoc("Performs a request from a table of options and returns a table with the status, statusText, headers, body and url of the response"),
+
+	"get": functiontype.NewNative(func(sc *scope.Scope, st *stack.Stack, callf func(rft functiontype.ReqFunctionType, sc *scope.Scope, st *stack.Stack) error) error {
+		return pushRequest(sc, st, map[string]types.ReqType{
+			"url": st.Pop(),
+		})
+	}, []types.ReqVarType{types.TypeString}, []types.ReqVarType{types.TypeTable}).SetDoc("Performs a GET request and returns the response table"),
+
+	"post": functiontype.NewNative(func(sc *scope.Scope, st *stack.Stack, callf func(rft functiontype.ReqFunctionType, sc *scope.Scope, st *stack.Stack) error) error {
+		body, contentType, u := st.Pop(), st.Pop(), st.Pop()
+
+		return pushRequest(sc, st, map[string]types.ReqType{
+			"method":  stringtype.New(http.MethodPost),
+			"url":     u,
+			"headers": tabletype.New(map[string]types.ReqType{"Content-Type": contentType}),
+			"body":    body,
+		})
+	}, []types.ReqVarType{types.TypeString, types.TypeString, types.TypeString}, []types.ReqVarType{types.TypeTable}).SetDoc("Performs a POST request with a url, content type and body and returns the response table"),
+
+	"postJSON": functiontype.NewNative(func(sc *scope.Scope, st *stack.Stack, callf func(rft functiontype.ReqFunctionType, sc *scope.Scope, st *stack.Stack) error) error {
+		value, u := st.Pop(), st.Pop()
+
+		return pushRequest(sc, st, map[string]types.ReqType{
+			"method": stringtype.New(http.MethodPost),
+			"url":    u,
+			"json":   value,
+		})
+	}, []types.ReqVarType{types.TypeAny, types.TypeString}, []types.ReqVarType{types.TypeTable}).SetDoc("Performs a POST request with a value encoded as JSON and returns the response table"),
+
+	"jar": functiontype.NewNative(func(sc *scope.Scope, st *stack.Stack, callf func(rft functiontype.ReqFunctionType, sc *scope.Scope, st *stack.Stack) error) error {
+		jar, err := cookiejar.New(nil)
+		if err != nil {
+			return err
+		}
+
+		st.Push(nativetype.NewHandle("cookiejar", jar))
+
+		return nil
+	}, []types.ReqVarType{}, []types.ReqVarType{types.TypeNative}).SetDoc("Returns a new cookie jar, which can be given to web.request under 'jar' to keep cookies between requests"),
+
+	"download": functiontype.NewNative(func(sc *scope.Scope, st *stack.Stack, callf func(rft functiontype.ReqFunctionType, sc *scope.Scope, st *stack.Stack) error) error {
+		resp, err := doRequest(sc, map[string]types.ReqType{
+			"url": st.Pop(),
+		})
+		if err != nil {
+			return err
+		}
+
+		m := resp.Literal().(map[string]types.ReqType)
+
+		if status := int(m["status"].Literal().(float32)); status < 200 || status > 299 {
+			return fmt.Errorf("status code %d, '%s'", status, m["statusText"].Literal().(string))
+		}
+
+		st.Push(m["body"])
+
+		return nil
+	}, []types.ReqVarType{types.TypeString}, []types.ReqVarType{types.TypeString}).SetDoc("Returns the body of a GET request, erroring if the status code isn't 2xx"),
+
+	"handler": functiontype.NewNative(func(sc *scope.Scope, st *stack.Stack, callf func(rft functiontype.ReqFunctionType, sc *scope.Scope, st *stack.Stack) error) error {
+		handler, err := newWebHandler(sc, st.Pop().Literal().(map[string]types.ReqType), callf)
+		if err != nil {
+			return err
+		}
+
+		st.Push(nativetype.NewHandle("web handler", handler))
+
+		return nil
+	}, []types.ReqVarType{types.TypeTable}, []types.ReqVarType{types.TypeNative}).SetDoc("Creates a handler from a table of \"METHOD /path\" routes to functions, which are given a request table and return a response table or string"),
+
+	"serve": functiontype.NewNative(func(sc *scope.Scope, st *stack.Stack, callf func(rft functiontype.ReqFunctionType, sc *scope.Scope, st *stack.Stack) error) error {
+		routes := st.Pop()
+		addr := st.Pop().Literal().(string)
+
+		handler, err := webHandlerFrom(sc, routes, callf)
+		if err != nil {
+			return err
+		}
+
+		if err = sc.Env().GetSandbox().CheckListen(addr); err != nil {
+			return err
+		}
+
+		server := &http.Server{Addr: addr, Handler: handler}
+		ctx := sc.Env().Ctx()
+
+		// the server is shut down once the context is cancelled
+		stop := context.AfterFunc(ctx, func() {
+			server.Shutdown(context.Background())
+		})
+
+		defer stop()
+
+		if err = server.ListenAndServe(); errors.Is(err, http.ErrServerClosed) && ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		return err
+	}, []types.ReqVarType{types.TypeTable | types.TypeNative, types.TypeString}, []types.ReqVarType{}).SetDoc("Serves a routing table or handler on an address until an error occurs"),
+}
@@ -0,0 +1,192 @@
+package stdlib
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/voidwyrm-2/reqproc/runtime/scope"
+	"github.com/voidwyrm-2/reqproc/runtime/stack"
+	"github.com/voidwyrm-2/reqproc/runtime/types"
+	"github.com/voidwyrm-2/reqproc/runtime/types/functiontype"
+	"github.com/voidwyrm-2/reqproc/runtime/types/nativetype"
+	"github.com/voidwyrm-2/reqproc/runtime/types/numbertype"
+	"github.com/voidwyrm-2/reqproc/runtime/types/stringtype"
+	"github.com/voidwyrm-2/reqproc/runtime/types/tabletype"
+)
+
+var routeWildcard = regexp.MustCompile(`\{([^}.]+)(?:\.\.\.)?\}`)
+
+func webRequestTable(r *http.Request, params []string) (tabletype.ReqTableType, error) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		return tabletype.ReqTableType{}, err
+	}
+
+	headers := map[string]types.ReqType{}
+	for k, v := range r.Header {
+		headers[k] = stringtype.New(strings.Join(v, ", "))
+	}
+
+	query := map[string]types.ReqType{}
+	for k, v := range r.URL.Query() {
+		query[k] = stringtype.New(strings.Join(v, ", "))
+	}
+
+	values := map[string]types.ReqType{}
+	for _, p := range params {
+		values[p] = stringtype.New(r.PathValue(p))
+	}
+
+	return tabletype.New(map[string]types.ReqType{
+		"method":     stringtype.New(r.Method),
+		"path":       stringtype.New(r.URL.Path),
+		"url":        stringtype.New(r.URL.String()),
+		"remoteAddr": stringtype.New(r.RemoteAddr),
+		"headers":    tabletype.New(headers),
+		"query":      tabletype.New(query),
+		"params":     tabletype.New(values),
+		"body":       stringtype.New(string(body)),
+	}), nil
+}
+
+// writes a handler's result, which is either a string body or a table with a status, headers and body
+func writeWebResponse(w http.ResponseWriter, result types.ReqType) error {
+	if result.Type() == types.TypeString {
+		_, err := io.WriteString(w, result.Literal().(string))
+		return err
+	} else if result.Type() != types.TypeTable {
+		return fmt.Errorf("expected a handler to return a string or table, but found '%s(type %s)'", result.String(), result.Type().String())
+	}
+
+	resp := result.Literal().(map[string]types.ReqType)
+
+	if v, ok, err := optionOfType(resp, "headers", types.TypeTable); err != nil {
+		return err
+	} else if ok {
+		if err = eachWebValue(v.Literal().(map[string]types.ReqType), w.Header().Add); err != nil {
+			return err
+		}
+	}
+
+	status := http.StatusOK
+	if v, ok, err := optionOfType(resp, "status", types.TypeNumber); err != nil {
+		return err
+	} else if ok {
+		if v.(numbertype.ReqNumberType).IsFloat() {
+			return floatInvalidFor("status code")
+		}
+
+		status = int(v.Literal().(float32))
+	}
+
+	body := ""
+	if v, ok, err := optionOfType(resp, "body", types.TypeString); err != nil {
+		return err
+	} else if ok {
+		body = v.Literal().(string)
+	}
+
+	w.WriteHeader(status)
+	_, err := io.WriteString(w, body)
+
+	return err
+}
+
+/*
+Creates a http.Handler from a routing table of "METHOD /path" patterns (see net/http.ServeMux) to handler functions
+
+each request gets its own stack and an isolated copy of the scope the handler was made in, taken when it was made (see snapshot),
+so handlers can read the globals as they were then but cannot reassign them, and mutating a list or table only changes it for the request
+*/
+func newWebHandler(sc *scope.Scope, routes map[string]types.ReqType, callf func(rft functiontype.ReqFunctionType, sc *scope.Scope, st *stack.Stack) error) (http.Handler, error) {
+	mux := http.NewServeMux()
+
+	// only ever read from, so requests can copy it at the same time
+	s := snapshot{}
+	base := s.scope(sc)
+
+	for pattern, v := range routes {
+		if v.Type() != types.TypeFunction {
+			return nil, fmt.Errorf("expected a function for route '%s', but found '%s(type %s)'", pattern, v.String(), v.Type().String())
+		}
+
+		copied, _ := s.copy(v, true)
+		handler := copied.(functiontype.ReqFunctionType)
+
+		params := []string{}
+		for _, m := range routeWildcard.FindAllStringSubmatch(pattern, -1) {
+			params = append(params, m[1])
+		}
+
+		err := func() (err error) {
+			// ServeMux panics on invalid or conflicting patterns
+			defer func() {
+				if r := recover(); r != nil {
+					err = fmt.Errorf("invalid route '%s': %v", pattern, r)
+				}
+			}()
+
+			mux.HandleFunc(pattern, func(w http.ResponseWriter, r *http.Request) {
+				if err := serveWebRequest(sc, base, handler, params, w, r, callf); err != nil {
+					fmt.Fprintf(sc.Env().ErrOut(), "error handling %s %s: %s\n", r.Method, r.URL.Path, err.Error())
+					http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+				}
+			})
+
+			return nil
+		}()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return mux, nil
+}
+
+// handles a request with handler in a copy of base, which is the copy of sc the handler was made in
+func serveWebRequest(sc, base *scope.Scope, handler functiontype.ReqFunctionType, params []string, w http.ResponseWriter, r *http.Request, callf func(rft functiontype.ReqFunctionType, sc *scope.Scope, st *stack.Stack) error) error {
+	req, err := webRequestTable(r, params)
+	if err != nil {
+		return err
+	}
+
+	// a request being handled keeps the env it started with, even if the run serving it ends
+	e := sc.Env()
+	defer e.Hold()()
+
+	s := snapshot{}
+	isolated := scope.NewIsolated(s.scope(base))
+	isolated.SetEnv(e)
+
+	copied, _ := s.copy(handler, true)
+	handler = copied.(functiontype.ReqFunctionType)
+
+	st := stack.New(req)
+	e.Track(&st)
+	defer st.Release()
+
+	if err = callf(handler, isolated, &st); err != nil {
+		return err
+	} else if err = st.Expect(types.TypeString | types.TypeTable); err != nil {
+		return err
+	}
+
+	return writeWebResponse(w, st.Pop())
+}
+
+// accepts either a routing table or a handler made by web.handler
+func webHandlerFrom(sc *scope.Scope, v types.ReqType, callf func(rft functiontype.ReqFunctionType, sc *scope.Scope, st *stack.Stack) error) (http.Handler, error) {
+	if v.Type() == types.TypeTable {
+		return newWebHandler(sc, v.Literal().(map[string]types.ReqType), callf)
+	}
+
+	handler, ok := v.(nativetype.ReqNativeType).Handle().(http.Handler)
+	if !ok {
+		return nil, fmt.Errorf("'%s' is not a web handler", v.String())
+	}
+
+	return handler, nil
+}
@@ -0,0 +1,104 @@
+package test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/voidwyrm-2/reqproc/check"
+	"github.com/voidwyrm-2/reqproc/runtime/types"
+	"github.com/voidwyrm-2/reqproc/runtime/types/functiontype"
+)
+
+func TestCheck(t *testing.T) {
+	cases := []struct {
+		input     string
+		line, col int
+		expected  string
+	}{
+		{`1 +`, 1, 3, "stack underflow: '+' takes 2 values, but there are only 1 on the stack"},
+		{`"a" range`, 1, 5, "'range' expects a number, but found a string"},
+		{`(|1:1 1 2) $f`, 1, 2, "function declared |1:1 but leaves 3 values"},
+		{`(|number -> number drop "b") $f`, 1, 2, "function declared to return a number, but leaves a string"},
+		{`0 try :loop 1 1 "a" + err loop`, 1, 23, "label 'loop' is reached with 2 values on the stack here, but with 1 elsewhere"},
+		{`nope`, 1, 1, "'nope' is not defined"},
+		{`"io" import io.nothing`, 1, 13, "'io' has no member 'nothing'"},
+		{`"io" import only ["nothing"]`, 1, 19, "module 'io' has no member 'nothing'"},
+		{`"a" $x x`, 1, 8, "'x' is not callable, it's a string"},
+		{`1 (|1:1 "a" +) dip`, 1, 16, "stack underflow"},
+		{`"task" import (|2:1 +) $add 1 @add task.spawn`, 1, 36, "stack underflow: 'task.spawn' takes 2 values"},
+		{`1 (|0:1 1 1) $f f +`, 1, 4, "function declared |0:1 but leaves 2 values"},
+		{`(|string -> number -> s ; @s) $f`, 1, 2, "function declared to return a number, but leaves a string"},
+		{`(|2:1 -> a ; @a)`, 1, 7, "function takes 2 values, but names 1"},
+		{`1 2 clear swap`, 1, 11, "stack underflow: 'swap' takes 2 values, but there are only 0 on the stack"},
+		// only imports define names, not every string
+		{`"later" drop later`, 1, 14, "'later' is not defined"},
+		{`(|0:0 "later" drop later) $f`, 1, 20, "'later' is not defined"},
+		// top-level code runs in order, unlike functions, which can be called after what they use is defined
+		{`x 1 $x`, 1, 1, "'x' is not defined"},
+		{`f (|0:0) $f`, 1, 1, "'f' is not defined"},
+		{`[1 2`, 1, 1, "no ']' to match '['"},
+		{`"io" import only ["print"`, 1, 18, "no ']' to match '['"},
+	}
+
+	for _, c := range cases {
+		t.Logf("checking `%s`", c.input)
+
+		diags, err := check.Source(c.input, nil)
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+
+		found := false
+		for _, d := range diags {
+			if d.Line == c.line && d.Col == c.col && strings.Contains(d.Message, c.expected) {
+				found = true
+			}
+		}
+
+		if !found {
+			t.Fatalf("expected '%s' on line %d, col %d, but found %v", c.expected, c.line, c.col, diags)
+		}
+	}
+}
+
+func TestCheckValid(t *testing.T) {
+	cases := []string{
+		`1 2 + 3 *`,
+		`(|2:1 +) $add 1 2 add`,
+		`(|number string -> number drop) $f 1 "a" f`,
+		`"io" import "hi" io.putl`,
+		`"io" import as out "hi" out.putl`,
+		`"io" import only ["putl"] "hi" putl`,
+		`try "a" 1 + drop err failed notry :failed geterr errcl`,
+		`0 try :loop 1 "a" + err loop`,
+		`(|0:0 later) $f (|0:0) $later f`,
+		`(|0:0 "hi" putl) $f "io" import only ["putl"] f`,
+		`(|2:1 -> a b ; @a @b +) $add 1 2 add`,
+		`1 2 (|1:1 1 +) dip + 3 - drop`,
+		`[1 2 3] 0 @# drop`,
+		`"try" 1 exit 1 2 3 :unreachable`,
+		// the stack is unknown after calling a function whose effect isn't known
+		`def f f drop drop`,
+	}
+
+	for _, c := range cases {
+		t.Logf("checking `%s`", c)
+
+		diags, err := check.Source(c, nil)
+		if err != nil {
+			t.Fatal(err.Error())
+		} else if len(diags) > 0 {
+			t.Fatalf("expected no problems, but found %v", diags)
+		}
+	}
+
+	// globals from the host are checked like the standard library
+	diags, err := check.Source(`"a" double`, map[string]types.ReqType{
+		"double": functiontype.NewNative(nil, []types.ReqVarType{types.TypeNumber}, []types.ReqVarType{types.TypeNumber}),
+	})
+	if err != nil {
+		t.Fatal(err.Error())
+	} else if len(diags) != 1 || diags[0].Message != "'double' expects a number, but found a string" {
+		t.Fatalf("expected a wrong type for 'double', but found %v", diags)
+	}
+}
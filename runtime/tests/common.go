@@ -3,6 +3,7 @@ package test
 import (
 	"math/rand"
 	"reflect"
+	"strings"
 	"testing"
 
 	"github.com/voidwyrm-2/reqproc/runtime/interpreter"
@@ -96,3 +97,67 @@ func generateStackTestCases(cases *[]stackTestCase, amount int, generator func(i
 
 	return nil
 }
+
+type valueTestCase struct {
+	input string
+	// either the expected literal of the value on top of the stack, or a func(types.ReqType) bool that checks it
+	expected any
+}
+
+func newTestInterpreter(t *testing.T) interpreter.Interpreter {
+	i, err := interpreter.New(scope.New(nil, map[string]types.ReqType{}))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	return i
+}
+
+// runs each case in a fresh interpreter (given to setup first, if it isn't nil) and checks the value it leaves on top of the stack
+func testValues(t *testing.T, cases []valueTestCase, setup func(i *interpreter.Interpreter)) {
+	for caseIndex, c := range cases {
+		t.Logf("(%d of %d) testing `%s`\n", caseIndex+1, len(cases), c.input)
+
+		i := newTestInterpreter(t)
+		if setup != nil {
+			setup(&i)
+		}
+
+		result, err := i.Execute(c.input)
+		if err != nil {
+			t.Fatal(err.Error() + " (with `" + c.input + "`)")
+		} else if len(result) == 0 {
+			t.Fatalf("expected a value to be on the stack, but it was empty (with `%s`)", c.input)
+		}
+
+		top := result[len(result)-1]
+
+		if checkf, ok := c.expected.(func(types.ReqType) bool); ok {
+			if !checkf(top) {
+				t.Fatalf("unexpected value '%s' (with `%s`)", top.String(), c.input)
+			}
+		} else if !reflect.DeepEqual(top.Literal(), c.expected) {
+			t.Fatalf("expected value '%v', but found '%v' instead (with `%s`)", c.expected, top.Literal(), c.input)
+		}
+
+		t.Logf("(%d of %d) test output: `%s`", caseIndex+1, len(cases), i.GetStack())
+	}
+}
+
+// checks that each input fails with an error containing the expected string
+func testErrors(t *testing.T, cases []valueTestCase, setup func(i *interpreter.Interpreter)) {
+	for caseIndex, c := range cases {
+		t.Logf("(%d of %d) testing `%s`\n", caseIndex+1, len(cases), c.input)
+
+		i := newTestInterpreter(t)
+		if setup != nil {
+			setup(&i)
+		}
+
+		if _, err := i.Execute(c.input); err == nil {
+			t.Fatalf("expected an error (with `%s`)", c.input)
+		} else if !strings.Contains(err.Error(), c.expected.(string)) {
+			t.Fatalf("expected an error containing '%s', but found '%s' instead (with `%s`)", c.expected, err.Error(), c.input)
+		}
+	}
+}
@@ -0,0 +1,209 @@
+package test
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/voidwyrm-2/reqproc/runtime/types"
+	"github.com/voidwyrm-2/reqproc/runtime/types/functiontype"
+	"github.com/voidwyrm-2/reqproc/runtime/types/nativetype"
+	"github.com/voidwyrm-2/reqproc/runtime/types/numbertype"
+)
+
+type convertUser struct {
+	Name   string   `reqproc:"name"`
+	Age    int      `reqproc:"age"`
+	Tags   []string `reqproc:"tags"`
+	Admin  bool     `reqproc:"-"`
+	Nick   string
+	secret string
+}
+
+func TestFromGo(t *testing.T) {
+	type handle struct{ n int }
+
+	cases := []struct {
+		value    any
+		expected any
+	}{
+		{3, float32(3)},
+		{uint8(200), float32(200)},
+		{2.5, float32(2.5)},
+		{true, float32(1)},
+		{"hi", "hi"},
+		{[]int{1, 2}, []any{float32(1), float32(2)}},
+		{[2]string{"a", "b"}, []any{"a", "b"}},
+		{map[string]int{"a": 1}, map[string]any{"a": float32(1)}},
+		{
+			convertUser{Name: "ann", Age: 30, Tags: []string{"x"}, Admin: true, Nick: "a", secret: "s"},
+			map[string]any{"name": "ann", "age": float32(30), "tags": []any{"x"}, "Nick": "a"},
+		},
+	}
+
+	for _, c := range cases {
+		v, err := types.FromGo(c.value)
+		if err != nil {
+			t.Fatal(err.Error())
+		} else if got := plainLiteral(v); !reflect.DeepEqual(got, c.expected) {
+			t.Fatalf("expected %v for %#v, but found %v", c.expected, c.value, got)
+		}
+	}
+
+	h := &handle{n: 1}
+	if v, err := types.FromGo(h); err != nil {
+		t.Fatal(err.Error())
+	} else if v.Type() != types.TypeNative || v.(nativetype.ReqNativeType).Handle() != h {
+		t.Fatalf("expected a native value wrapping the pointer, but found '%s'", v.String())
+	}
+
+	if v, err := types.FromGo(func(a, b int) int { return a - b }); err != nil {
+		t.Fatal(err.Error())
+	} else if fn, ok := v.(functiontype.ReqFunctionType); !ok || fn.String() != "function{number, number -> number}" {
+		t.Fatalf("expected a native function, but found '%s'", v.String())
+	}
+
+	errorCases := []struct {
+		value    any
+		expected string
+	}{
+		{nil, "cannot convert nil to a ReqProc value"},
+		{map[int]string{}, "cannot convert map[int]string to a table, its keys must be strings"},
+		{[]any{1, nil}, "index 1: cannot convert nil to a ReqProc value"},
+		{func(...int) {}, "cannot use variadic function func(...int)"},
+	}
+
+	for _, c := range errorCases {
+		if _, err := types.FromGo(c.value); err == nil || err.Error() != c.expected {
+			t.Fatalf("expected error '%s', but found %v", c.expected, err)
+		}
+	}
+}
+
+// the literal of v, with lists and tables converted recursively
+func plainLiteral(v types.ReqType) any {
+	switch l := v.Literal().(type) {
+	case []types.ReqType:
+		items := []any{}
+		for _, item := range l {
+			items = append(items, plainLiteral(item))
+		}
+
+		return items
+	case map[string]types.ReqType:
+		m := map[string]any{}
+		for k, item := range l {
+			m[k] = plainLiteral(item)
+		}
+
+		return m
+	default:
+		return l
+	}
+}
+
+func TestToGo(t *testing.T) {
+	i := newTestInterpreter(t)
+
+	values, err := i.Execute(`
+["a"] $tags
+7
+2.5
+"str"
+[1 2 3]
+["name" "bob" "age" 41 "tags" @tags "Admin" 1 "extra" 0] table
+(|2.1 *)
+"nope"
+0 1 -`)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	number, float, str, list, user, fn, wrong, negative := values[0], values[1], values[2], values[3], values[4], values[5], values[6], values[7]
+
+	cases := []struct {
+		value    types.ReqType
+		t        reflect.Type
+		expected any
+	}{
+		{number, reflect.TypeFor[int](), 7},
+		{number, reflect.TypeFor[uint16](), uint16(7)},
+		{numbertype.New(255), reflect.TypeFor[uint8](), uint8(255)},
+		{numbertype.New(-128), reflect.TypeFor[int8](), int8(-128)},
+		{number, reflect.TypeFor[bool](), true},
+		{float, reflect.TypeFor[float64](), 2.5},
+		{str, reflect.TypeFor[string](), "str"},
+		{list, reflect.TypeFor[[]int](), []int{1, 2, 3}},
+		{list, reflect.TypeFor[[3]float32](), [3]float32{1, 2, 3}},
+		{list, reflect.TypeFor[any](), []any{float32(1), float32(2), float32(3)}},
+		{user, reflect.TypeFor[convertUser](), convertUser{Name: "bob", Age: 41, Tags: []string{"a"}}},
+		{user, reflect.TypeFor[*convertUser](), &convertUser{Name: "bob", Age: 41, Tags: []string{"a"}}},
+		{list, reflect.TypeFor[types.ReqType](), list},
+	}
+
+	for _, c := range cases {
+		got, err := types.ToGo(c.value, c.t)
+		if err != nil {
+			t.Fatal(err.Error())
+		} else if !reflect.DeepEqual(got, c.expected) {
+			t.Fatalf("expected %#v, but found %#v", c.expected, got)
+		}
+	}
+
+	mul, err := types.ToGo(fn, reflect.TypeFor[func(int, int) int]())
+	if err != nil {
+		t.Fatal(err.Error())
+	} else if n := mul.(func(int, int) int)(6, 7); n != 42 {
+		t.Fatalf("expected 42, but found %d", n)
+	}
+
+	mulErr, err := types.ToGo(fn, reflect.TypeFor[func(int, int) (string, error)]())
+	if err != nil {
+		t.Fatal(err.Error())
+	} else if _, err = mulErr.(func(int, int) (string, error))(1, 2); err == nil {
+		t.Fatal("expected the function's error to be returned")
+	}
+
+	// functions can't be converted without something to call them with, which the interpreter package provides
+	call := types.Constructors.Call
+	types.Constructors.Call = nil
+
+	_, err = types.ToGo(fn, reflect.TypeFor[func(int, int) int]())
+	types.Constructors.Call = call
+
+	if err == nil || !strings.Contains(err.Error(), "without an interpreter to call it") {
+		t.Fatalf("expected an error without a caller, but found %v", err)
+	}
+
+	errorCases := []struct {
+		value    types.ReqType
+		t        reflect.Type
+		expected string
+	}{
+		{float, reflect.TypeFor[int](), "2.5 cannot be used as Go type int"},
+		{negative, reflect.TypeFor[uint](), "-1 cannot be used as Go type uint"},
+		{wrong, reflect.TypeFor[int](), "cannot use 'nope(type string)' as Go type int"},
+		{list, reflect.TypeFor[[]string](), "index 0: cannot use '1(type number)' as Go type string"},
+		{list, reflect.TypeFor[[2]int](), "expected a list of length 2"},
+		{user, reflect.TypeFor[map[string]int](), "cannot use"},
+		{numbertype.New(1e20), reflect.TypeFor[uint64](), "1e+20 cannot be used as Go type uint64"},
+		{numbertype.New(1e20), reflect.TypeFor[uint8](), "1e+20 cannot be used as Go type uint8"},
+		{numbertype.New(1e20), reflect.TypeFor[int64](), "1e+20 cannot be used as Go type int64"},
+		{numbertype.New(256), reflect.TypeFor[uint8](), "256 cannot be used as Go type uint8"},
+		{numbertype.New(-129), reflect.TypeFor[int8](), "-129 cannot be used as Go type int8"},
+	}
+
+	for _, c := range errorCases {
+		if _, err := types.ToGo(c.value, c.t); err == nil || !strings.Contains(err.Error(), c.expected) {
+			t.Fatalf("expected error '%s', but found %v", c.expected, err)
+		}
+	}
+
+	sentinel := errors.New("sentinel")
+	if v, err := types.ToGo(nativetype.NewHandle("error", sentinel), reflect.TypeFor[error]()); err != nil {
+		t.Fatal(err.Error())
+	} else if v != sentinel {
+		t.Fatalf("expected the wrapped value, but found %v", v)
+	}
+}
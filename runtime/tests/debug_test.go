@@ -0,0 +1,302 @@
+package test
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/voidwyrm-2/reqproc/debug"
+)
+
+const debugged = `"io" import
+def total
+5 !total
+(|1:1
+	2 *) $double
+@total double
+:show
+io.putl
+`
+
+func writeDebugged(t *testing.T) string {
+	path := filepath.Join(t.TempDir(), "main.req")
+
+	if err := os.WriteFile(path, []byte(debugged), 0o644); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	return path
+}
+
+func TestDebugConsole(t *testing.T) {
+	path := writeDebugged(t)
+
+	var out bytes.Buffer
+	commands := "b 6\nb :show\nc\nw @total 1 +\ns\ns\nbt\nst\no\nv\np @total 2 *\nc\n"
+
+	if err := debug.Console(path, strings.NewReader(commands), &out); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	// what the commands print, in order
+	expected := []string{
+		"main.req:1:1 in main.req (entry)",
+		"main.req:6:1 in main.req (breakpoint)",
+		"main.req:6:8 in main.req (step)",
+		"watch 1: @total 1 + = [6]",
+		"main.req:5:2 in double (step)",
+		"* 0: double at " + path + ":5:2",
+		"  1: main.req at " + path + ":6:8",
+		"[5]",
+		"main.req:7:1 in main.req (breakpoint)",
+		"const double = function{any -> any}\nvar total = 5",
+		"[10]",
+		"10\nthe code finished",
+	}
+
+	rest := out.String()
+
+	for _, e := range expected {
+		i := strings.Index(rest, e)
+		if i == -1 {
+			t.Fatalf("expected '%s' in the rest of the output:\n%s", e, rest)
+		}
+
+		rest = rest[i+len(e):]
+	}
+}
+
+func TestDebugConsoleQuit(t *testing.T) {
+	path := writeDebugged(t)
+
+	var out bytes.Buffer
+
+	// quitting isn't an error, and the code stops before printing anything
+	if err := debug.Console(path, strings.NewReader("n\nq\n"), &out); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	// the path of the file can contain "10" itself
+	if printed := strings.ReplaceAll(out.String(), path, ""); strings.Contains(printed, "10") || strings.Contains(printed, "the code finished") {
+		t.Fatalf("expected the code to be stopped, but it went on:\n%s", out.String())
+	}
+}
+
+type dapMessage struct {
+	Type       string          `json:"type"`
+	Command    string          `json:"command"`
+	RequestSeq int             `json:"request_seq"`
+	Success    bool            `json:"success"`
+	Message    string          `json:"message"`
+	Event      string          `json:"event"`
+	Body       json.RawMessage `json:"body"`
+}
+
+// talks to a debug adapter as an editor would, one request at a time
+type dapClient struct {
+	t      *testing.T
+	in     io.Writer
+	out    *bufio.Reader
+	seq    int
+	events []dapMessage
+}
+
+func (c *dapClient) read() dapMessage {
+	header, err := textproto.NewReader(c.out).ReadMIMEHeader()
+	if err != nil {
+		c.t.Fatal(err.Error())
+	}
+
+	length, _ := strconv.Atoi(header.Get("Content-Length"))
+	body := make([]byte, length)
+
+	if _, err = io.ReadFull(c.out, body); err != nil {
+		c.t.Fatal(err.Error())
+	}
+
+	var msg dapMessage
+	if err = json.Unmarshal(body, &msg); err != nil {
+		c.t.Fatal(err.Error())
+	}
+
+	return msg
+}
+
+// sends a request and returns its response's body, keeping the events sent before it
+func (c *dapClient) request(command string, args any, body any) {
+	c.seq++
+
+	req, err := json.Marshal(map[string]any{"seq": c.seq, "type": "request", "command": command, "arguments": args})
+	if err != nil {
+		c.t.Fatal(err.Error())
+	}
+
+	fmt.Fprintf(c.in, "Content-Length: %d\r\n\r\n%s", len(req), req)
+
+	for {
+		msg := c.read()
+
+		if msg.Type == "event" {
+			c.events = append(c.events, msg)
+			continue
+		} else if msg.RequestSeq != c.seq {
+			c.t.Fatalf("expected the response to request %d, but got one to %d", c.seq, msg.RequestSeq)
+		} else if !msg.Success {
+			c.t.Fatalf("'%s' failed: %s", command, msg.Message)
+		}
+
+		if body != nil {
+			if err = json.Unmarshal(msg.Body, body); err != nil {
+				c.t.Fatal(err.Error())
+			}
+		}
+
+		return
+	}
+}
+
+// returns the next event with a name, along with the events skipped to get to it
+func (c *dapClient) event(name string, body any) []dapMessage {
+	skipped := []dapMessage{}
+
+	for {
+		var msg dapMessage
+		if len(c.events) > 0 {
+			msg, c.events = c.events[0], c.events[1:]
+		} else {
+			msg = c.read()
+		}
+
+		if msg.Type != "event" {
+			c.t.Fatalf("expected the '%s' event, but got a response to '%s'", name, msg.Command)
+		} else if msg.Event != name {
+			skipped = append(skipped, msg)
+			continue
+		}
+
+		if body != nil {
+			if err := json.Unmarshal(msg.Body, body); err != nil {
+				c.t.Fatal(err.Error())
+			}
+		}
+
+		return skipped
+	}
+}
+
+type dapStopped struct {
+	Reason string `json:"reason"`
+}
+
+type dapStackTrace struct {
+	StackFrames []struct {
+		Name string `json:"name"`
+		Line int    `json:"line"`
+	} `json:"stackFrames"`
+}
+
+type dapVariables struct {
+	Variables []struct {
+		Name  string `json:"name"`
+		Value string `json:"value"`
+	} `json:"variables"`
+}
+
+func TestDebugDAP(t *testing.T) {
+	path := writeDebugged(t)
+
+	inR, inW := io.Pipe()
+	outR, outW := io.Pipe()
+	errc := make(chan error, 1)
+
+	go func() {
+		errc <- debug.DAP("", inR, outW)
+		outW.Close()
+	}()
+
+	c := &dapClient{t: t, in: inW, out: bufio.NewReader(outR)}
+
+	c.request("initialize", map[string]any{"adapterID": "reqproc"}, nil)
+	c.event("initialized", nil)
+	c.request("launch", map[string]any{"program": path}, nil)
+	c.request("setBreakpoints", map[string]any{"source": map[string]any{"path": path}, "breakpoints": []map[string]any{{"line": 6}}}, nil)
+	c.request("setFunctionBreakpoints", map[string]any{"breakpoints": []map[string]any{{"name": "show"}}}, nil)
+	c.request("configurationDone", nil, nil)
+
+	var stopped dapStopped
+	var trace dapStackTrace
+
+	c.event("stopped", &stopped)
+	c.request("stackTrace", map[string]any{"threadId": 1}, &trace)
+
+	if stopped.Reason != "breakpoint" || len(trace.StackFrames) != 1 || trace.StackFrames[0].Line != 6 {
+		t.Fatalf("expected to stop at the breakpoint on line 6, but stopped for '%s' at %+v", stopped.Reason, trace.StackFrames)
+	}
+
+	c.request("stepIn", map[string]any{"threadId": 1}, nil)
+	c.event("stopped", nil)
+	c.request("stepIn", map[string]any{"threadId": 1}, nil)
+	c.event("stopped", &stopped)
+	c.request("stackTrace", map[string]any{"threadId": 1}, &trace)
+
+	if stopped.Reason != "step" || len(trace.StackFrames) != 2 || trace.StackFrames[0].Name != "double" || trace.StackFrames[0].Line != 5 {
+		t.Fatalf("expected to step into double on line 5, but stopped for '%s' at %+v", stopped.Reason, trace.StackFrames)
+	}
+
+	var stack, scope dapVariables
+
+	// the references of the stack and scope of the second frame
+	c.request("variables", map[string]any{"variablesReference": 1}, &stack)
+	c.request("variables", map[string]any{"variablesReference": 4}, &scope)
+
+	if len(stack.Variables) != 1 || stack.Variables[0].Value != "5" {
+		t.Fatalf("expected the stack [5], but got %+v", stack.Variables)
+	} else if !strings.Contains(fmt.Sprint(scope.Variables), "{total 5}") {
+		t.Fatalf("expected 'total' to be 5, but got %+v", scope.Variables)
+	}
+
+	var evaluated struct {
+		Result string `json:"result"`
+	}
+
+	c.request("evaluate", map[string]any{"expression": "@total 2 *", "frameId": 0}, &evaluated)
+
+	if evaluated.Result != "[10]" {
+		t.Fatalf("expected [10] to be evaluated, but got '%s'", evaluated.Result)
+	}
+
+	c.request("continue", map[string]any{"threadId": 1}, nil)
+	c.event("stopped", &stopped)
+
+	if stopped.Reason != "breakpoint" {
+		t.Fatalf("expected to stop at the label breakpoint, but stopped for '%s'", stopped.Reason)
+	}
+
+	var exited struct {
+		ExitCode int `json:"exitCode"`
+	}
+
+	c.request("continue", map[string]any{"threadId": 1}, nil)
+	skipped := c.event("exited", &exited)
+	c.event("terminated", nil)
+
+	if exited.ExitCode != 0 {
+		t.Fatalf("expected the code to exit with 0, but it exited with %d", exited.ExitCode)
+	} else if len(skipped) != 1 || skipped[0].Event != "output" || !strings.Contains(string(skipped[0].Body), `"output":"10\n"`) {
+		t.Fatalf("expected the code to print 10, but got %+v", skipped)
+	}
+
+	c.request("disconnect", nil, nil)
+
+	if err := <-errc; err != nil {
+		t.Fatal(err.Error())
+	}
+}
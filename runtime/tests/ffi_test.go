@@ -0,0 +1,124 @@
+package test
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"testing"
+
+	"github.com/voidwyrm-2/reqproc/runtime/interpreter"
+	"github.com/voidwyrm-2/reqproc/runtime/types"
+	"github.com/voidwyrm-2/reqproc/runtime/types/functiontype"
+)
+
+const testPluginSource = `package main
+
+import (
+	"errors"
+	"strings"
+)
+
+type Counter struct{ n int }
+
+var Total = 10
+
+func Add(a, b int) int { return a + b }
+
+func Join(parts []string, sep string) string { return strings.Join(parts, sep) }
+
+func Divide(a, b float64) (float64, error) {
+	if b == 0 {
+		return 0, errors.New("division by zero")
+	}
+
+	return a / b, nil
+}
+
+func NewCounter() *Counter { return &Counter{} }
+
+func Incr(c *Counter) int {
+	c.n++
+	return c.n
+}
+`
+
+// builds testPluginSource with -buildmode=plugin, skipping the test where plugins aren't supported
+func buildTestPlugin(t *testing.T) string {
+	if runtime.GOOS != "linux" && runtime.GOOS != "darwin" && runtime.GOOS != "freebsd" {
+		t.Skip("plugins aren't supported on " + runtime.GOOS)
+	} else if testing.Short() {
+		t.Skip("building a plugin is slow")
+	}
+
+	dir := writeModules(t, map[string]string{
+		"go.mod":    "module testplugin\n\ngo 1.22\n",
+		"plugin.go": testPluginSource,
+	})
+
+	so := filepath.Join(dir, "plugin.so")
+
+	args := []string{"build", "-buildmode=plugin", "-o", so}
+	if raceEnabled {
+		args = append(args, "-race")
+	}
+
+	cmd := exec.Command("go", append(args, ".")...)
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(), "CGO_ENABLED=1")
+
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Skipf("cannot build a plugin: %s\n%s", err.Error(), out)
+	}
+
+	return so
+}
+
+func TestFFIPlugin(t *testing.T) {
+	so := buildTestPlugin(t)
+
+	setup := func(i *interpreter.Interpreter) {
+		if _, err := i.Execute(`"ffi" import "` + so + `" ffi.open $plug`); err != nil {
+			t.Fatal(err.Error())
+		}
+	}
+
+	testValues(t, []valueTestCase{
+		{`2 3 @plug "Add" "func(int, int) int" ffi.func $add add`, float32(5)},
+		{`["a" "b"] "-" @plug "Join" "func([]string, string) string" ffi.func $join join`, "a-b"},
+		{`7 2 @plug "Divide" "func(float64, float64) (float64, error)" ffi.func $div div`, float32(3.5)},
+		{`@plug "NewCounter" "func() native" ffi.func $new @plug "Incr" "func(native) int" ffi.func $incr
+new $c @c incr drop @c incr`, float32(2)},
+		{`@plug "NewCounter" "func() native" ffi.func $new new ffi.typeof`, "*main.Counter"},
+		{`@plug "Total" ffi.var ffi.fromNative`, float32(10)},
+		{`@plug "Total" ffi.var $total @total 32 ffi.set @total ffi.fromNative`, float32(32)},
+		{`@plug "Add" "func(int, int) int" ffi.func`, func(v types.ReqType) bool {
+			return v.(functiontype.ReqFunctionType).Doc() == "Add (int, int) int, from a Go plugin"
+		}},
+	}, setup)
+
+	testErrors(t, []valueTestCase{
+		{`@plug "Add" "func(int) int" ffi.func`, "'Add' has type func(int, int) int, but was declared as func(int) int"},
+		{`@plug "Add" "func(int, string) int" ffi.func`, "but was declared as"},
+		{`@plug "Add" "func(int, int) number" ffi.func`, "invalid signature 'func(int, int) number' at column 16: unknown type 'number'"},
+		{`@plug "Add" "func(int int) int" ffi.func`, "expected ',' or ')'"},
+		{`@plug "Total" "func() int" ffi.func`, "'Total' is a variable of type int, not a function; use ffi.var instead"},
+		{`@plug "Add" ffi.var`, "'Add' is a function, use ffi.func instead"},
+		{`@plug "Missing" ffi.var`, "symbol Missing not found"},
+		{`1 0 @plug "Divide" "func(float64, float64) (float64, error)" ffi.func $div div`, "division by zero"},
+		{`1.5 1 @plug "Add" "func(int, int) int" ffi.func $add add`, "argument 1: 1.5 cannot be used as Go type int"},
+		{`@plug "Total" ffi.var "str" ffi.set`, "cannot use 'str(type string)' as Go type int"},
+		{`"task" import 1 task.chan @plug "Incr" "func(native) int" ffi.func $incr incr`, "cannot use '<channel>(type native)' as Go type *main.Counter"},
+		{`"/does/not/exist.so" ffi.open`, "exist.so"},
+	}, setup)
+}
+
+func TestFFIConversion(t *testing.T) {
+	testValues(t, []valueTestCase{
+		{`"ffi" import [1 "a"] ffi.toNative`, func(v types.ReqType) bool {
+			return reflect.DeepEqual(v.Literal(), []any{float32(1), "a"}) && v.String() == "<[]interface {}>"
+		}},
+		{`"ffi" import ["a" 2] table ffi.toNative ffi.fromNative "a" @#`, float32(2)},
+	}, func(i *interpreter.Interpreter) {})
+}
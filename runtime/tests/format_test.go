@@ -0,0 +1,113 @@
+package test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"unicode"
+
+	"github.com/voidwyrm-2/reqproc/format"
+	"github.com/voidwyrm-2/reqproc/lexer"
+	"github.com/voidwyrm-2/reqproc/lexer/tokens"
+)
+
+func TestFormat(t *testing.T) {
+	cases := []struct {
+		input, expected string
+	}{
+		{"1   2 +", "1 2 +\n"},
+		{"  \"io\"  import ; load io  \n\n\n\nio.putl", "\"io\" import ; load io\n\nio.putl\n"},
+		{"( |2:1   + )   $add", "(|2:1 +) $add\n"},
+		{"[ 1\n      2\n]", "[1\n\t2\n]\n"},
+		{"(|0:1\n[\n1\n2]\n)", "(|0:1\n\t[\n\t\t1\n\t\t2]\n)\n"},
+		{"(|2:1 -> a   b ;\n@a @b +)", "(|2:1 -> a b ;\n\t@a @b +)\n"},
+		{"(|list -> table | table)", "(|list -> table | table)\n"},
+		{"(|string -> number\n5)", "(|string -> number\n\t5)\n"},
+		{"\"a\\\"b\\n\" `raw\\n`", "\"a\\\"b\\n\" `raw\\n`\n"},
+		{"`two\n  lines` io.putl\n1", "`two\n  lines` io.putl\n1\n"},
+		{":start\n1 drop\n   :end\n0 exit", ":start\n\t1 drop\n:end\n\t0 exit\n"},
+		{"(|0:0\n:loop\ntry\n1 \"a\" +\nerr loop\nnotry)", "(|0:0\n\t:loop\n\t\ttry\n\t\t\t1 \"a\" +\n\t\t\terr loop\n\t\tnotry)\n"},
+		{"try ; catch it\n\"a\" 1 +\nnotry", "try ; catch it\n\t\"a\" 1 +\nnotry\n"},
+		{"", ""},
+	}
+
+	for _, c := range cases {
+		t.Logf("formatting `%s`", c.input)
+
+		actual, err := format.Source(c.input)
+		if err != nil {
+			t.Fatal(err.Error())
+		} else if actual != c.expected {
+			t.Fatalf("expected `%s`, but found `%s` instead", c.expected, actual)
+		}
+	}
+
+	if _, err := format.Source(`"unterminated`); err == nil {
+		t.Fatal("expected an error for code which can't be lexed")
+	}
+}
+
+// formatting shouldn't change what code does, and formatting it again shouldn't change it at all
+func TestFormatExamples(t *testing.T) {
+	paths, err := filepath.Glob("../../examples/*.req")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	sources := []string{
+		"(|2:1 -> n acc ;\n  try 0 @n pick drop drop notry err recur\n @acc try 1 \"a\" + err end\n:recur errcl @n 1 - @acc @n + sum\n :end) $sum",
+		"[1 2 \"three\" @x]   (|list -> list | list) $f ; a comment\n; another",
+	}
+
+	for _, path := range paths {
+		content, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+
+		sources = append(sources, string(content))
+	}
+
+	for _, src := range sources {
+		formatted, err := format.Source(src)
+		if err != nil {
+			// some examples use syntax which was removed
+			continue
+		}
+
+		t.Logf("formatted `%s` as `%s`", src, formatted)
+
+		if again, err := format.Source(formatted); err != nil {
+			t.Fatal(err.Error())
+		} else if again != formatted {
+			t.Fatalf("formatting again changed `%s` to `%s`", formatted, again)
+		}
+
+		before, after := lexer.New(src), lexer.New(formatted)
+
+		expected, err := before.LexComments()
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+
+		actual, err := after.LexComments()
+		if err != nil {
+			t.Fatal(err.Error())
+		} else if len(actual) != len(expected) {
+			t.Fatalf("expected %d tokens after formatting, but found %d", len(expected), len(actual))
+		}
+
+		for i, e := range expected {
+			// comments lose the spaces they end with
+			lit := e.Lit()
+			if e.Iskind(tokens.Comment) {
+				lit = strings.TrimRightFunc(lit, unicode.IsSpace)
+			}
+
+			if !actual[i].Is(e.Kind(), lit) {
+				t.Fatalf("expected %s after formatting, but found %s", e, actual[i])
+			}
+		}
+	}
+}
@@ -0,0 +1,236 @@
+package test
+
+import (
+	"math"
+	"path/filepath"
+	"testing"
+
+	"github.com/voidwyrm-2/reqproc/runtime/env"
+	"github.com/voidwyrm-2/reqproc/runtime/interpreter"
+	"github.com/voidwyrm-2/reqproc/runtime/types"
+	"github.com/voidwyrm-2/reqproc/runtime/types/functiontype"
+)
+
+func TestTypedSignatures(t *testing.T) {
+	testValues(t, []valueTestCase{
+		{`"ab" 3 (|string number -> string *) $rep rep`, "ababab"},
+		{`5 (|string|number -> string|number) $id id`, float32(5)},
+		{`"x" (|string|number -> string|number) $id id`, "x"},
+		{`["a" 1] (|list -> table | table) $t t "a" @#`, float32(1)},
+		{`(|-> number 5) $five five`, float32(5)},
+		{`(|string number -> list)`, func(v types.ReqType) bool {
+			return v.String() == "function{number, string -> list}"
+		}},
+		{`(|any -> string|number)`, func(v types.ReqType) bool {
+			return v.String() == "function{any -> string|number}"
+		}},
+	}, func(i *interpreter.Interpreter) {})
+
+	testErrors(t, []valueTestCase{
+		{`1 2 (|string number -> string *) $f f`, "expected a string on the stack but found '1(type number)' instead"},
+		{`1 (|number -> string 1 +) $f f`, "invalid output for function{number -> string}: expected a string on the stack but found '2(type number)' instead"},
+		{`(|-> number) $f f`, "function declared |0:1 but left 0 values"},
+		{`[1] (|string|number -> any) $f f`, "expected a string|number on the stack but found '[1](type list)' instead"},
+		{`(|strin -> number)`, "error on line 1, col 2: invalid type 'strin' in signature"},
+		{`(|string number)`, "expected '->' in signature"},
+	}, func(i *interpreter.Interpreter) {})
+}
+
+func TestArity(t *testing.T) {
+	cases := map[string]functiontype.Arity{
+		"2:1":  {In: 2, Out: 1},
+		"2.1":  {In: 2, Out: 1},
+		"1.10": {In: 1, Out: 10},
+		"1.1":  {In: 1, Out: 1},
+		"0:12": {In: 0, Out: 12},
+		"3":    {In: 3, Out: 0},
+	}
+
+	for s, expected := range cases {
+		if a, err := functiontype.ParseArity(s); err != nil {
+			t.Fatal(err.Error())
+		} else if a != expected {
+			t.Fatalf("expected %s for '%s', but found %s", expected, s, a)
+		}
+	}
+
+	for _, s := range []string{"", "a:1", "1:", "1.2.3", "-1:0"} {
+		if _, err := functiontype.ParseArity(s); err == nil {
+			t.Fatalf("expected an error for '%s'", s)
+		}
+	}
+
+	testValues(t, []valueTestCase{
+		{`2 3 (|2:1 *) $mul mul`, float32(6)},
+		{`2 3 (|2.1 *) $mul mul`, float32(6)},
+		{`(|1.10 drop) sig 1 @#`, float32(10)},
+		{`(|2:1 *) sig 0 @#`, float32(2)},
+		{`(|string number -> list) sig 1 @#`, float32(1)},
+		{`@dup sig 1 @#`, float32(2)},
+	}, func(i *interpreter.Interpreter) {})
+
+	testErrors(t, []valueTestCase{
+		{`(|1:a drop)`, "invalid signature '1:', expected something like '2:1'"},
+	}, func(i *interpreter.Interpreter) {})
+}
+
+func TestOutputArity(t *testing.T) {
+	testValues(t, []valueTestCase{
+		{`1 2 (|2:1 +) $add add`, float32(3)},
+		{`1 2 (|2:2) $same same +`, float32(3)},
+		{`5 1 @dup dip + +`, float32(11)},
+		{`4 1 (|1:2 dup) dip + +`, float32(9)},
+		{`(|0:1 "hi") doc`, ""},
+		{`@doc doc`, "Returns the docstring of the function it's called on"},
+		{`try 1 (|1:1 1 2) $f f err caught :caught notry geterr`, func(v types.ReqType) bool {
+			return v.String() == "error on line 1, col 21: function declared |1:1 but left 3 values"
+		}},
+	}, func(i *interpreter.Interpreter) {})
+
+	testErrors(t, []valueTestCase{
+		{`1 (|1:1 1 2) $f f`, "error on line 1, col 17: function declared |1:1 but left 3 values"},
+		{`1 2 (|2.1) $f f`, "function declared |2:1 but left 2 values"},
+		{`1 2 (|2:1 + drop) $f f`, "function declared |2:1 but left 0 values"},
+		{`1 2 (|0:0 drop) dip`, "function declared |0:0 but took 1 values"},
+		{`0 1 2 (|1:0 drop drop) dip`, "function declared |1:0 but took 2 values"},
+		{`1 (|1:1 1 2) $f 1 @f dip`, "function declared |1:1 but left 3 values"},
+	}, func(i *interpreter.Interpreter) {})
+}
+
+func TestClosures(t *testing.T) {
+	dir := writeModules(t, map[string]string{
+		"counter.req": `def _count 0 !_count (|0:1 @_count 1 + !_count @_count) $next (|1:1 @_scale *) $scaled 10 $_scale`,
+	})
+
+	counter := `(|0:1 def n 0 !n (|0:1 @n 1 + !n @n)) $counter `
+
+	testValues(t, []valueTestCase{
+		{counter + `counter $next next drop next drop next`, float32(3)},
+		{counter + `counter $a counter $b a drop a drop b`, float32(1)},
+		{`(|1:1 $x (|1:1 @x +)) $adder 5 adder $add5 10 add5`, float32(15)},
+		{`def x 1 !x (|0:1 @x) $f 2 !x f`, float32(2)},
+		{`1 $x (|0:1 @x) $f (|0:1 2 $x f) $g g`, float32(1)},
+		{`"counter.req" import counter.next drop counter.next`, float32(2)},
+		{`"counter.req" import 2 counter.scaled`, float32(20)},
+	}, func(i *interpreter.Interpreter) {
+		if err := i.SetFile(filepath.Join(dir, "main.req")); err != nil {
+			t.Fatal(err.Error())
+		}
+	})
+
+	testErrors(t, []valueTestCase{
+		// functions can't see the variables of the code calling them
+		{`(|0:1 @y) $f (|0:1 1 $y f) $g g`, "variable/constant 'y' does not exist"},
+		{`"task" import def n 0 !n (|0:0 1 !n) $set @set task.spawn task.wait`, "cannot reassign 'n' from inside an isolated scope"},
+	}, nil)
+}
+
+func TestParams(t *testing.T) {
+	testValues(t, []valueTestCase{
+		{`1 2 (|2.1 -> a b ; @a @b -) $sub sub`, float32(-1)},
+		{`"ab" 2 (|string number -> string -> s n ; @s @n *) $rep rep`, "abab"},
+		{`(|1:1 -> x ; (|0:1 @x)) $always 5 always $five five`, float32(5)},
+		{`5 1 (|1:1 -> x ;
+			@x 1 +) dip drop`, float32(6)},
+		{`10 3 (|2:1 -> a b ; (|1:1 -> b ; @b 1 +) $inc @a @b inc -) $f f`, float32(6)},
+	}, nil)
+
+	testErrors(t, []valueTestCase{
+		{`(|2:1 -> a ; @a)`, "function takes 2 values, but names 1"},
+		{`(|2:1 -> a a ; @a)`, "input 'a' is named more than once"},
+		{`(|1:1 -> def ; @def)`, "'def' cannot be used as a name"},
+		{`(|1:1 -> a @a)`, "expected ';' after the names of the function's inputs"},
+		{`(|1:1 -> a.b ; @a)`, "invalid input name 'a.b'"},
+		{`"a" (|number -> number -> n ; @n) $f f`, "expected a number on the stack"},
+		{`1 (|1:1 -> n ; 1 !n @n) $f f`, "cannot reassign constant 'n'"},
+	}, nil)
+}
+
+func TestCombinators(t *testing.T) {
+	testValues(t, []valueTestCase{
+		{`3 5 @+ curry $add5 add5`, float32(8)},
+		{`5 @+ curry sig 0 @#`, float32(1)},
+		{`1 2 @- flip $rsub rsub`, float32(1)},
+		{`4 (|1:1 1 +) (|1:1 2 *) compose $f f`, float32(10)},
+		{`4 (|1:1 1 +) (|1:1 2 *) compose sig 1 @#`, float32(1)},
+		{`"a" identity`, "a"},
+		{`1 2 7 const $seven seven`, float32(7)},
+		{`(|1:1 2 *) $double 3 [1 @+ @double] function $f f`, float32(8)},
+		{`[1 2 @+] function $three three`, float32(3)},
+		{`[1 2 @+] function sig 0 @#`, float32(0)},
+		{`5 @+ curry`, func(v types.ReqType) bool {
+			return v.String() == "function{any -> any}(5 function{any, any -> any})"
+		}},
+		{`"a" const`, func(v types.ReqType) bool {
+			return v.String() == `function{any -> any}(function{any -> } "a")`
+		}},
+		{`@drop @+ curry`, func(v types.ReqType) bool {
+			return v.String() == "function{any -> any}(@function{any -> } function{any, any -> any})"
+		}},
+		// composite functions can be curried and composed again
+		{`(|1:1 2 *) $double 3 @+ curry @double compose 4 swap curry $f f`, float32(14)},
+		{`10 @+ curry 20 swap curry $thirty thirty`, float32(30)},
+	}, nil)
+
+	testErrors(t, []valueTestCase{
+		{`1 @+ curry $f f`, "expected any type on the stack but the stack is empty"},
+		{`1 [@drop @drop] function $f f`, "the stack isn't large enough"},
+		{`[@+] function $f 1 "a" f`, "invalid operation"},
+	}, nil)
+}
+
+func TestRecursion(t *testing.T) {
+	// `0 n pick` only works when n is 0, so the function recurses until then
+	sum := `(|2:1 -> n acc ;
+		try 0 @n pick drop drop notry err recur
+		@acc try 1 "a" + err end
+		:recur errcl @n 1 - @acc @n + sum
+		:end) $sum `
+	count := `(|2:1 -> n acc ;
+		try 0 @n pick drop drop notry err recur
+		@acc try 1 "a" + err end
+		:recur errcl @n 1 - @acc 1 + count
+		:end) $count `
+
+	testValues(t, []valueTestCase{
+		{sum + `4 0 sum`, float32(10)},
+		{sum + `100 0 sum`, float32(5050)},
+		// calls in tail position don't use up the Go stack, or count towards the call depth
+		{count + `1000000 0 count`, float32(1000000)},
+		// a function can call itself by its name after being passed around
+		{`(|1:1 -> n ; try 0 @n pick drop drop notry err recur @n try 1 "a" + err end :recur errcl @n 1 - down :end) $down @down (|1:1 -> f ; 3 f) $g @down g`, float32(0)},
+		// calls that aren't in tail position still work
+		{`(|1:1 -> n ; try 0 @n pick drop drop notry err recur 1 try 1 "a" + err end :recur errcl @n 1 - fact @n * :end) $fact 5 fact`, float32(120)},
+		{`(|number -> number -> n ; try 0 @n pick drop drop notry err recur 0 try 1 "a" + err end :recur errcl @n 1 - down :end) $down 50 down`, float32(0)},
+		{`(|0:1 1 2 drop) $f 5 (|1:2 f) $g g +`, float32(6)},
+	}, func(i *interpreter.Interpreter) {
+		i.SetLimits(env.Limits{CallDepth: 20})
+	})
+
+	// a 1,000,000 deep sum; numbers are float32s, so it's only close to 500000500000
+	i := newTestInterpreter(t)
+	i.SetLimits(env.Limits{CallDepth: 20})
+
+	result, err := i.Execute(sum + `1000000 0 sum`)
+	if err != nil {
+		t.Fatal(err.Error())
+	} else if len(result) != 1 {
+		t.Fatalf("expected 1 value, but found %d", len(result))
+	} else if got := float64(result[0].Literal().(float32)); math.Abs(got-500000500000)/500000500000 > 0.001 {
+		t.Fatalf("expected about 500000500000, but found %v", got)
+	}
+
+	testErrors(t, []valueTestCase{
+		{`(|number -> number -> n ; "a" bad) $bad 1 bad`, "expected a number on the stack"},
+		{`(|1:1 -> n ; "a") $f (|number -> number f) $g 1 g`, "invalid output for function{number -> number}"},
+		{`(|1:2 -> n ; @n @n) $f (|1:1 f) $g 1 g`, "function declared |1:1 but left 2 values"},
+	}, nil)
+
+	// tail calls which leave values under their inputs, or whose outputs are checked differently, still count towards the call depth
+	testErrors(t, []valueTestCase{
+		{`(|1:1 -> n ; try 0 @n pick drop drop notry err recur @n try 1 "a" + err end :recur errcl @n @n 1 - grow :end) $grow 100 grow`, "call depth limit of 20 exceeded"},
+		{`(|number -> number -> n ; try 0 @n pick drop drop notry err recur @n try 1 "a" + err end :recur errcl @n 1 - untyped :end) $typed (|1:1 typed) $untyped 100 typed`, "call depth limit of 20 exceeded"},
+	}, func(i *interpreter.Interpreter) {
+		i.SetLimits(env.Limits{CallDepth: 20})
+	})
+}
@@ -0,0 +1,148 @@
+package test
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/voidwyrm-2/reqproc/runtime/env"
+)
+
+func TestLimits(t *testing.T) {
+	cases := []struct {
+		input    string
+		limits   env.Limits
+		expected env.LimitError
+	}{
+		{`try :loop 1 "a" + drop drop err loop`, env.Limits{Instructions: 1000}, env.ErrInstructions},
+		{`try :loop 1 1 "a" + err loop`, env.Limits{StackSize: 100}, env.ErrStackSize},
+		{`1 1 1 1 1 1 1 1 1 1 1`, env.Limits{StackSize: 10}, env.ErrStackSize},
+		{`100 range`, env.Limits{Memory: 99}, env.ErrMemory},
+		{`(|0.0 rec 0 drop) $rec rec`, env.Limits{CallDepth: 50}, env.ErrCallDepth},
+		// tail calls don't count towards the call depth, but still run instructions
+		{`(|0.0 rec) $rec rec`, env.Limits{Instructions: 1000}, env.ErrInstructions},
+		// memory is what the stacks hold at once, so a loop which doesn't grow them stays under the limit
+		{`try :loop "0123456789012345678901234567890123456789" dup drop dup drop 1 swap swap swap drop drop 1 "a" + drop drop err loop`, env.Limits{Instructions: 10000, Memory: 100}, env.ErrInstructions},
+		{`try :loop "0123456789" 1 "a" + err loop`, env.Limits{Memory: 1000}, env.ErrMemory},
+		{`(|0:0 "0123456789" dup drop drop) $f try :loop f 1 "a" + drop drop err loop`, env.Limits{Instructions: 10000, Memory: 20}, env.ErrInstructions},
+		{`"ab" 1000 *`, env.Limits{Memory: 1000}, env.ErrMemory},
+		// values held by variables count too, including what lists and tables grow by when they're changed in place
+		{`"x" 1000 * $a "x" 1000 * $b "x" 1000 * $c`, env.Limits{Memory: 2500}, env.ErrMemory},
+		{`10 range $l def i 0 !i try :loop @l @i "x" 1000 * !# drop @i 1 + !i 1 "a" + err loop`, env.Limits{Instructions: 100000, Memory: 3000}, env.ErrMemory},
+		{`1000 range $l def i 0 !i try :loop @l @i "x" 1000 * !# drop @i 1 + !i 1 "a" + err loop`, env.Limits{Instructions: 100000, Memory: 3000}, env.ErrMemory},
+		{`[] table $t @t "a" "x" 1000 * !# drop @t "b" "x" 1000 * !# drop @t "c" "x" 1000 * !# drop`, env.Limits{Memory: 2500}, env.ErrMemory},
+		{`[0 0] $l @l 0 "x" 1000 * !# drop @l 1 "x" 1000 * !# drop "x" 1000 *`, env.Limits{Memory: 2500}, env.ErrMemory},
+	}
+
+	for _, c := range cases {
+		t.Logf("testing `%s` with %+v", c.input, c.limits)
+
+		i := newTestInterpreter(t)
+		i.SetLimits(c.limits)
+
+		_, err := i.Execute(c.input)
+		if !errors.Is(err, c.expected) {
+			t.Fatalf("expected a %s limit error, but found %v", c.expected.Kind, err)
+		}
+
+		for _, other := range []env.LimitError{env.ErrInstructions, env.ErrStackSize, env.ErrCallDepth, env.ErrMemory, env.ErrTimeout} {
+			if other.Kind != c.expected.Kind && errors.Is(err, other) {
+				t.Fatalf("expected only a %s limit error, but '%s' is also a %s limit error", c.expected.Kind, err.Error(), other.Kind)
+			}
+		}
+	}
+
+	// code within the limits runs as normal
+	i := newTestInterpreter(t)
+	i.SetLimits(env.Limits{Instructions: 100, StackSize: 10, CallDepth: 5, Memory: 100})
+
+	if _, err := i.Execute(`(|1.1 2 *) $double 4 double double "ab" 2 *`); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	// values popped off the stack don't count anymore
+	i = newTestInterpreter(t)
+	i.SetLimits(env.Limits{Memory: 100})
+
+	if _, err := i.Execute(`"0123456789012345678901234567890123456789" dup drop dup drop 1 swap swap swap drop drop 20 range drop 20 range`); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	// and neither do the variables of a function once it returns
+	i = newTestInterpreter(t)
+	i.SetLimits(env.Limits{Memory: 1000})
+
+	if _, err := i.Execute(`(|0:0 "x" 600 * $s) $f f f f`); err != nil {
+		t.Fatal(err.Error())
+	}
+}
+
+func TestLimitsTimeout(t *testing.T) {
+	i := newTestInterpreter(t)
+	i.SetLimits(env.Limits{Timeout: 50 * time.Millisecond})
+
+	// every run gets the whole time limit
+	for range 2 {
+		start := time.Now()
+
+		_, err := i.Execute(`try :loop 1 "a" + drop drop err loop`)
+		if !errors.Is(err, env.ErrTimeout) {
+			t.Fatalf("expected a time limit error, but found %v", err)
+		} else if time.Since(start) > 5*time.Second {
+			t.Fatal("execution wasn't stopped by the time limit")
+		}
+	}
+
+	if _, err := i.Execute(`1 2 +`); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	// functions of a module imported by an earlier run are part of the run calling them
+	dir := writeModules(t, map[string]string{"util.req": `(|1.1 2 *) $double`})
+
+	i = newTestInterpreter(t)
+	i.SetLimits(env.Limits{Timeout: 50 * time.Millisecond})
+
+	if err := i.SetFile(filepath.Join(dir, "main.req")); err != nil {
+		t.Fatal(err.Error())
+	} else if _, err = i.Execute(`"util.req" import`); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	time.Sleep(100 * time.Millisecond)
+
+	if _, err := i.Execute(`4 util.double`); err != nil {
+		t.Fatal(err.Error())
+	}
+}
+
+func TestLimitsVM(t *testing.T) {
+	vm := newTestVM(t)
+	vm.SetLimits(env.Limits{Timeout: 50 * time.Millisecond})
+
+	start := time.Now()
+
+	_, err := vm.Execute(`try :loop 1 "a" + drop drop err loop`)
+	if !errors.Is(err, env.ErrTimeout) {
+		t.Fatalf("expected a time limit error, but found %v", err)
+	} else if errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected the time limit error to replace the context's error, but found %v", err)
+	} else if time.Since(start) > 5*time.Second {
+		t.Fatal("execution wasn't stopped by the time limit")
+	}
+
+	// usage is counted for each run separately
+	vm.SetLimits(env.Limits{Instructions: 10})
+
+	for range 3 {
+		if _, err = vm.Execute(`1 2 + drop`); err != nil {
+			t.Fatal(err.Error())
+		}
+	}
+
+	if _, err = vm.Execute(`1 1 1 1 1 1 1 1 1 1 1`); !errors.Is(err, env.ErrInstructions) {
+		t.Fatalf("expected an instruction limit error, but found %v", err)
+	}
+}
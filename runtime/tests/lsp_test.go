@@ -0,0 +1,232 @@
+package test
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/textproto"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/voidwyrm-2/reqproc/lsp"
+	"github.com/voidwyrm-2/reqproc/runtime/stdlib"
+	"github.com/voidwyrm-2/reqproc/runtime/types/functiontype"
+)
+
+type lspMessage struct {
+	ID     *int            `json:"id"`
+	Method string          `json:"method"`
+	Params json.RawMessage `json:"params"`
+	Result json.RawMessage `json:"result"`
+	Error  *struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+// runs the server over the given messages, returning the responses by their id and the notifications it sent
+func runLSP(t *testing.T, messages []map[string]any) (map[int]lspMessage, []lspMessage) {
+	var in, out bytes.Buffer
+
+	for _, m := range messages {
+		m["jsonrpc"] = "2.0"
+
+		body, err := json.Marshal(m)
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+
+		fmt.Fprintf(&in, "Content-Length: %d\r\n\r\n%s", len(body), body)
+	}
+
+	if err := lsp.Serve(&in, &out); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	responses, notifications := map[int]lspMessage{}, []lspMessage{}
+	r := bufio.NewReader(&out)
+
+	for {
+		header, err := textproto.NewReader(r).ReadMIMEHeader()
+		if err == io.EOF {
+			return responses, notifications
+		} else if err != nil {
+			t.Fatal(err.Error())
+		}
+
+		length, _ := strconv.Atoi(header.Get("Content-Length"))
+		body := make([]byte, length)
+
+		if _, err = io.ReadFull(r, body); err != nil {
+			t.Fatal(err.Error())
+		}
+
+		var msg lspMessage
+		if err = json.Unmarshal(body, &msg); err != nil {
+			t.Fatal(err.Error())
+		}
+
+		if msg.ID == nil {
+			notifications = append(notifications, msg)
+		} else {
+			responses[*msg.ID] = msg
+		}
+	}
+}
+
+func at(id int, method string, line, character int) map[string]any {
+	return map[string]any{"id": id, "method": method, "params": map[string]any{
+		"textDocument": map[string]any{"uri": "file:///test.req"},
+		"position":     map[string]any{"line": line, "character": character},
+	}}
+}
+
+func TestLSP(t *testing.T) {
+	text := strings.Join([]string{
+		`"io" import`,
+		`; adds two numbers`,
+		`(|2:1 -> a b ; @a @b +) $add`,
+		`def total`,
+		`1 2 add !total`,
+		`:loop`,
+		`	try 1 "a" + err loop notry`,
+		`@total io.putl nope`,
+		`io.pu`,
+	}, "\n")
+
+	responses, notifications := runLSP(t, []map[string]any{
+		{"id": 1, "method": "initialize", "params": map[string]any{}},
+		{"method": "initialized", "params": map[string]any{}},
+		{"method": "textDocument/didOpen", "params": map[string]any{"textDocument": map[string]any{"uri": "file:///test.req", "languageId": "reqproc", "version": 1, "text": text}}},
+		at(2, "textDocument/hover", 4, 5),
+		at(3, "textDocument/hover", 7, 12),
+		at(4, "textDocument/completion", 8, 5),
+		at(5, "textDocument/definition", 6, 18),
+		at(6, "textDocument/definition", 4, 10),
+		at(7, "textDocument/definition", 7, 8),
+		{"id": 8, "method": "textDocument/documentSymbol", "params": map[string]any{"textDocument": map[string]any{"uri": "file:///test.req"}}},
+		{"id": 9, "method": "textDocument/rename", "params": map[string]any{}},
+		at(10, "textDocument/hover", 4, 0),
+		{"id": 11, "method": "shutdown"},
+		{"method": "exit"},
+	})
+
+	var capabilities struct {
+		Capabilities map[string]any `json:"capabilities"`
+	}
+
+	if err := json.Unmarshal(responses[1].Result, &capabilities); err != nil {
+		t.Fatal(err.Error())
+	} else if capabilities.Capabilities["hoverProvider"] != true {
+		t.Fatalf("expected the server to provide hovers, but found %v", capabilities.Capabilities)
+	}
+
+	// diagnostics
+	if len(notifications) != 1 || notifications[0].Method != "textDocument/publishDiagnostics" {
+		t.Fatalf("expected the diagnostics to be published once, but found %v", notifications)
+	}
+
+	var published struct {
+		Diagnostics []lsp.Diagnostic `json:"diagnostics"`
+	}
+
+	if err := json.Unmarshal(notifications[0].Params, &published); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	messages := []string{}
+	for _, d := range published.Diagnostics {
+		messages = append(messages, fmt.Sprintf("%d:%d-%d %s", d.Range.Start.Line, d.Range.Start.Character, d.Range.End.Character, d.Message))
+	}
+
+	for _, expected := range []string{"7:15-19 'nope' is not defined", "8:0-5 'io' has no member 'pu'"} {
+		if !slices.Contains(messages, expected) {
+			t.Fatalf("expected the diagnostic '%s', but found %v", expected, messages)
+		}
+	}
+
+	// hover
+	hovers := map[int]string{
+		2: "add function{any, any -> any}\n```\n\nadds two numbers",
+		3: "io.putl " + stdlib.Stdlib["io"]["putl"].String() + "\n```\n\n" + stdlib.Stdlib["io"]["putl"].(functiontype.ReqFunctionType).Doc(),
+	}
+
+	for id, expected := range hovers {
+		var hover lsp.Hover
+		if err := json.Unmarshal(responses[id].Result, &hover); err != nil {
+			t.Fatal(err.Error())
+		} else if !strings.Contains(hover.Contents.Value, expected) {
+			t.Fatalf("expected the hover to contain `%s`, but found `%s`", expected, hover.Contents.Value)
+		}
+	}
+
+	if string(responses[10].Result) != "null" {
+		t.Fatalf("expected no hover for a number, but found %s", responses[10].Result)
+	}
+
+	// completion
+	var items []lsp.CompletionItem
+	if err := json.Unmarshal(responses[4].Result, &items); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	labels := []string{}
+	for _, item := range items {
+		labels = append(labels, item.Label)
+	}
+
+	if !slices.Equal(labels, []string{"put", "putl"}) {
+		t.Fatalf("expected the completions [put putl], but found %v", labels)
+	}
+
+	// definitions
+	definitions := map[int]lsp.Range{
+		5: {Start: lsp.Position{Line: 5, Character: 0}, End: lsp.Position{Line: 5, Character: 5}},
+		6: {Start: lsp.Position{Line: 3, Character: 4}, End: lsp.Position{Line: 3, Character: 9}},
+		7: {Start: lsp.Position{Line: 0, Character: 0}, End: lsp.Position{Line: 0, Character: 4}},
+	}
+
+	for id, expected := range definitions {
+		var location lsp.Location
+		if err := json.Unmarshal(responses[id].Result, &location); err != nil {
+			t.Fatal(err.Error())
+		} else if location.Range != expected {
+			t.Fatalf("expected the definition at %+v, but found %+v (request %d)", expected, location.Range, id)
+		}
+	}
+
+	// symbols
+	var symbols []lsp.DocumentSymbol
+	if err := json.Unmarshal(responses[8].Result, &symbols); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	names := []string{}
+	for _, s := range symbols {
+		names = append(names, fmt.Sprintf("%s %d %s", s.Name, s.Kind, s.Detail))
+	}
+
+	if expected := []string{"io 2 ", "add 12 function{any, any -> any}", "total 13 ", "loop 20 "}; !slices.Equal(names, expected) {
+		t.Fatalf("expected the symbols %v, but found %v", expected, names)
+	}
+
+	if responses[9].Error == nil || responses[9].Error.Code != -32601 {
+		t.Fatalf("expected an unsupported method to be an error, but found %+v", responses[9])
+	}
+
+	if _, ok := responses[11]; !ok {
+		t.Fatal("expected a response to shutdown")
+	}
+}
+
+func TestLSPExit(t *testing.T) {
+	in := "Content-Length: 33\r\n\r\n{\"jsonrpc\":\"2.0\",\"method\":\"exit\"}"
+
+	if err := lsp.Serve(strings.NewReader(in), io.Discard); err == nil {
+		t.Fatal("expected an error when the client exits without shutting down the server")
+	}
+}
@@ -0,0 +1,209 @@
+package test
+
+import (
+	"archive/zip"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/voidwyrm-2/reqproc/manifest"
+	"github.com/voidwyrm-2/reqproc/runtime/interpreter"
+)
+
+func TestManifestParse(t *testing.T) {
+	m, err := manifest.Parse("reqproc.mod", "package app ; the app\nversion 1.0.0\n\nrequire util 1.2.0\nrequire helpers ../helpers\n")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if m.Package != "app" || m.Version != "1.0.0" || len(m.Requires) != 2 {
+		t.Fatalf("unexpected manifest %+v", m)
+	} else if m.Requires[0].IsPath() || !m.Requires[1].IsPath() {
+		t.Fatalf("wrong sources in %+v", m.Requires)
+	}
+
+	for text, expected := range map[string]string{
+		"version 1.0.0":                         "reqproc.mod: missing 'package' directive",
+		"package app\nrequire util":             "reqproc.mod:2: expected 'require <name> <version or path>'",
+		"package app\nrequire a 1\nrequire a 2": "reqproc.mod:3: 'a' is already required",
+		"package a/b":                           "reqproc.mod:1: 'a/b' is not a valid package name",
+		"package app\nreplace util ../util":     "reqproc.mod:2: unknown directive 'replace'",
+	} {
+		if _, err := manifest.Parse("reqproc.mod", text); err == nil {
+			t.Fatalf("expected error '%s' for %q", expected, text)
+		} else if err.Error() != expected {
+			t.Fatalf("expected error '%s' for %q, but found '%s'", expected, text, err.Error())
+		}
+	}
+}
+
+func TestLock(t *testing.T) {
+	l := manifest.Lock{Root: "/project", Packages: []manifest.Locked{{Name: "util", Version: "1.2.0", Dir: "vendor/util", Sum: "h1:abc"}}}
+
+	parsed, err := manifest.ParseLock("/project", l.String())
+	if err != nil {
+		t.Fatal(err.Error())
+	} else if len(parsed.Packages) != 1 || parsed.Packages[0] != l.Packages[0] {
+		t.Fatalf("expected %+v, but found %+v", l, parsed)
+	}
+
+	for _, dir := range []string{"/etc", "../util", "vendor/../..", "vendor/../src", "vendor", "."} {
+		if _, err = manifest.ParseLock("/project", fmt.Sprintf("util 1.2.0 %s h1:abc\n", dir)); err == nil || !strings.Contains(err.Error(), "is not inside 'vendor'") {
+			t.Fatalf("expected the directory '%s' to be rejected, but found %v", dir, err)
+		}
+	}
+
+	root := writeModules(t, map[string]string{"vendor/util/strings.req": `"a" $a`})
+
+	sum, err := manifest.Sum(filepath.Join(root, "vendor", "util"))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	l = manifest.Lock{Root: root, Packages: []manifest.Locked{{Name: "util", Version: "1.2.0", Dir: "vendor/util", Sum: sum}}}
+
+	if p, ok, err := l.Resolve("util/strings.req"); err != nil || !ok || p != filepath.Join(root, "vendor", "util", "strings.req") {
+		t.Fatalf("wrong resolution '%s' (%v)", p, err)
+	} else if _, ok, _ = l.Resolve("other/strings.req"); ok {
+		t.Fatal("resolved a package that isn't locked")
+	} else if _, ok, _ = l.Resolve("strings.req"); ok {
+		t.Fatal("resolved a path without a package")
+	} else if _, _, err = l.Resolve("util/../../secret.req"); err == nil || !strings.Contains(err.Error(), "escapes the directory of package 'util'") {
+		t.Fatalf("expected an import escaping the package to be rejected, but found %v", err)
+	}
+
+	// a package changed since it was vendored isn't used
+	if err = os.WriteFile(filepath.Join(root, "vendor", "util", "strings.req"), []byte(`"b" $a`), 0o644); err != nil {
+		t.Fatal(err.Error())
+	} else if _, _, err = l.Resolve("util/strings.req"); err == nil || !strings.Contains(err.Error(), "package 'util' has been changed since it was vendored") {
+		t.Fatalf("expected the changed package to be rejected, but found %v", err)
+	}
+}
+
+func writeZip(t *testing.T, p string, files map[string]string) {
+	f, err := os.Create(p)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	defer f.Close()
+
+	w := zip.NewWriter(f)
+
+	for name, content := range files {
+		fw, err := w.Create(name)
+		if err != nil {
+			t.Fatal(err.Error())
+		} else if _, err = fw.Write([]byte(content)); err != nil {
+			t.Fatal(err.Error())
+		}
+	}
+
+	if err = w.Close(); err != nil {
+		t.Fatal(err.Error())
+	}
+}
+
+func TestVendor(t *testing.T) {
+	dir := writeModules(t, map[string]string{
+		"app/reqproc.mod":                 "package app\nrequire util 1.2.0\nrequire helpers ../helpers\n",
+		"app/main.req":                    `"helpers/h.req" import @h.six`,
+		"helpers/reqproc.mod":             "package helpers\nversion 0.3.0\nrequire util 1.2.0\n",
+		"helpers/h.req":                   `"util/math.req" import 2 3 math.mul $six`,
+		"registry/util/1.2.0/math.req":    `(|2.1 *) $mul`,
+		"registry/util/1.2.0/reqproc.mod": "package util\nversion 1.2.0\n",
+		"conflict/reqproc.mod":            "package conflict\nrequire helpers ../helpers\nrequire other ./other\n",
+		"conflict/other/reqproc.mod":      "package other\nrequire helpers ./helpers\n",
+		"conflict/other/helpers/h.req":    ``,
+		"misnamed/reqproc.mod":            "package misnamed\nrequire util ../helpers\n",
+	})
+
+	registry := filepath.Join(dir, "registry")
+	app := filepath.Join(dir, "app")
+
+	lock, err := manifest.Vendor(app, registry)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	versions := map[string]string{}
+	for _, p := range lock.Packages {
+		versions[p.Name] = p.Version
+	}
+
+	if len(versions) != 2 || versions["helpers"] != "0.3.0" || versions["util"] != "1.2.0" {
+		t.Fatalf("unexpected lock %+v", lock)
+	}
+
+	if _, err = os.Stat(filepath.Join(app, "vendor", "util", "math.req")); err != nil {
+		t.Fatal(err.Error())
+	} else if _, err = os.Stat(filepath.Join(app, manifest.LockName)); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	// imports are resolved through the lockfile, including from inside vendored packages
+	testValues(t, []valueTestCase{
+		{`"helpers/h.req" import @h.six`, float32(6)},
+		{`"util/math.req" import 3 4 math.mul`, float32(12)},
+	}, func(i *interpreter.Interpreter) {
+		if err := i.SetFile(filepath.Join(app, "main.req")); err != nil {
+			t.Fatal(err.Error())
+		}
+	})
+
+	testErrors(t, []valueTestCase{
+		{`"util/missing.req" import`, "cannot find module 'util/missing.req' in the vendored packages"},
+	}, func(i *interpreter.Interpreter) {
+		if err := i.SetFile(filepath.Join(app, "main.req")); err != nil {
+			t.Fatal(err.Error())
+		}
+	})
+
+	// archives from the registry are unpacked, and their single top-level directory is unwrapped
+	if err = os.RemoveAll(filepath.Join(registry, "util", "1.2.0")); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	writeZip(t, filepath.Join(registry, "util", "1.2.0.zip"), map[string]string{
+		"util-1.2.0/reqproc.mod": "package util\n",
+		"util-1.2.0/math.req":    `(|2.1 -) $mul`,
+	})
+
+	if _, err = manifest.Vendor(app, registry); err != nil {
+		t.Fatal(err.Error())
+	} else if content, err := os.ReadFile(filepath.Join(app, "vendor", "util", "math.req")); err != nil {
+		t.Fatal(err.Error())
+	} else if string(content) != `(|2.1 -) $mul` {
+		t.Fatalf("expected the archive's math.req, but found %q", content)
+	}
+
+	for project, expected := range map[string]string{
+		"conflict": "package 'helpers' is required from both",
+		"misnamed": "package 'util' (required by 'misnamed') declares itself as 'helpers'",
+	} {
+		if _, err := manifest.Vendor(filepath.Join(dir, project), registry); err == nil {
+			t.Fatalf("expected error '%s'", expected)
+		} else if !strings.Contains(err.Error(), expected) {
+			t.Fatalf("expected error '%s', but found '%s'", expected, err.Error())
+		}
+	}
+
+	writeZip(t, filepath.Join(registry, "util", "6.6.6.zip"), map[string]string{"../escape.req": ""})
+
+	if err = os.WriteFile(filepath.Join(app, manifest.ManifestName), []byte("package app\nrequire util 6.6.6\n"), 0o644); err != nil {
+		t.Fatal(err.Error())
+	} else if _, err = manifest.Vendor(app, registry); err == nil || !strings.Contains(err.Error(), "escapes the package directory") {
+		t.Fatalf("expected an escaping archive entry to be rejected, but found %v", err)
+	}
+
+	// vendoring again doesn't remove directories a lockfile names outside of the vendor directory
+	if err = os.WriteFile(filepath.Join(app, manifest.LockName), []byte("util 1.2.0 .. h1:abc\n"), 0o644); err != nil {
+		t.Fatal(err.Error())
+	} else if _, err = manifest.Vendor(app, registry); err == nil || !strings.Contains(err.Error(), "is not inside 'vendor'") {
+		t.Fatalf("expected the lockfile to be rejected, but found %v", err)
+	} else if _, err = os.Stat(filepath.Join(dir, "registry")); err != nil {
+		t.Fatal(err.Error())
+	}
+}
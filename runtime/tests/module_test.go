@@ -0,0 +1,107 @@
+package test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/voidwyrm-2/reqproc/runtime/interpreter"
+	"github.com/voidwyrm-2/reqproc/runtime/types"
+	"github.com/voidwyrm-2/reqproc/runtime/types/stringtype"
+)
+
+// writes files (relative path -> content) into a temporary directory and returns it
+func writeModules(t *testing.T, files map[string]string) string {
+	dir := t.TempDir()
+
+	for name, content := range files {
+		p := filepath.Join(dir, name)
+
+		if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+			t.Fatal(err.Error())
+		} else if err = os.WriteFile(p, []byte(content), 0o644); err != nil {
+			t.Fatal(err.Error())
+		}
+	}
+
+	return dir
+}
+
+func TestModules(t *testing.T) {
+	dir := writeModules(t, map[string]string{
+		"lib/util.req":     `[0] $state (|1.1 2 *) $double`,
+		"lib/a.req":        `"util.req" import @util.state 0 1 !# drop`,
+		"lib/cycle/x.req":  `"y.req" import`,
+		"lib/cycle/y.req":  `"x.req" import`,
+		"search/found.req": `"found" $name`,
+	})
+
+	main := filepath.Join(dir, "main.req")
+	setFile := func(i *interpreter.Interpreter) {
+		if err := i.SetFile(main); err != nil {
+			t.Fatal(err.Error())
+		}
+	}
+
+	testValues(t, []valueTestCase{
+		{`"lib/util.req" import 4 util.double`, float32(8)},
+		{`"lib/util.req" import as u 4 u.double`, float32(8)},
+		{`"lib/util.req" import only ["double"] 5 double`, float32(10)},
+		{`"lib/a.req" import "lib/util.req" import @util.state 0 @#`, float32(1)},
+		{`"found.req" import @found.name`, "found"},
+		{`"strings" import as str "a b" " " str.split 1 @#`, "b"},
+		{`"strings" import only ["split"] "a b" " " split 0 @#`, "a"},
+	}, func(i *interpreter.Interpreter) {
+		setFile(i)
+		i.GetEnv().SearchPath = []string{filepath.Join(dir, "search")}
+	})
+
+	testErrors(t, []valueTestCase{
+		{`"lib/cycle/x.req" import`, "import cycle: " + strings.Join([]string{main, filepath.Join(dir, "lib/cycle/x.req"), filepath.Join(dir, "lib/cycle/y.req"), filepath.Join(dir, "lib/cycle/x.req")}, " -> ")},
+		{`"missing.req" import`, "cannot find module 'missing.req'"},
+		{`"lib/util.req" import only ["triple"]`, "module 'lib/util.req' has no member 'triple'"},
+		{`"lib/util.req" import as`, "expected 'identifier', but found EOF"},
+	}, setFile)
+}
+
+func TestModuleExports(t *testing.T) {
+	dir := writeModules(t, map[string]string{
+		"implicit.req": `"io" import def counter 3 !counter (|1.1 1 +) $inc 1 $_hidden`,
+		"explicit.req": `export counter export inc def counter 3 !counter (|1.1 1 +) $inc 2 $other`,
+		"missing.req":  `export nothing`,
+	})
+
+	main := filepath.Join(dir, "main.req")
+	setFile := func(i *interpreter.Interpreter) {
+		if err := i.SetFile(main); err != nil {
+			t.Fatal(err.Error())
+		}
+	}
+
+	names := func(names ...string) []types.ReqType {
+		list := []types.ReqType{}
+		for _, n := range names {
+			list = append(list, stringtype.New(n))
+		}
+
+		return list
+	}
+
+	testValues(t, []valueTestCase{
+		{`"runtime" import def counter 3 !counter (|1.1 1 +) $inc 1 $_hidden runtime.exports`, names("counter", "inc")},
+		{`"runtime" import export inc (|1.1 1 +) $inc 2 $other runtime.exports`, names("inc")},
+		{`"implicit.req" import @implicit`, func(v types.ReqType) bool {
+			m := v.Literal().(map[string]types.ReqType)
+			return len(m) == 2 && m["counter"] != nil && m["inc"] != nil
+		}},
+		{`"explicit.req" import 1 explicit.inc @explicit.counter +`, float32(5)},
+	}, setFile)
+
+	testErrors(t, []valueTestCase{
+		{`"explicit.req" import @explicit.other`, "key 'other' does not exist"},
+		{`"missing.req" import`, "cannot export 'nothing', it isn't defined"},
+		{`export _secret`, "cannot export private name '_secret'"},
+		{`export a export a`, "'a' is already exported"},
+	}, setFile)
+}
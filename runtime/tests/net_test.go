@@ -0,0 +1,100 @@
+package test
+
+import (
+	"fmt"
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/voidwyrm-2/reqproc/runtime/types"
+)
+
+func TestNet(t *testing.T) {
+	echo, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	defer echo.Close()
+
+	go func() {
+		for {
+			conn, err := echo.Accept()
+			if err != nil {
+				return
+			}
+
+			buf := make([]byte, 64)
+			n, _ := conn.Read(buf)
+			conn.Write([]byte(strings.ToUpper(string(buf[:n]))))
+			conn.Close()
+		}
+	}()
+
+	cases := []valueTestCase{
+		{
+			fmt.Sprintf(`"net" import "tcp" "%s" net.dial $conn @conn "hello" net.write drop @conn 64 net.read @conn net.close`, echo.Addr()),
+			"HELLO",
+		},
+		{
+			fmt.Sprintf(`"net" import "tcp" "%s" net.dial $conn @conn "a" net.write drop @conn 64 net.read drop @conn 64 net.read`, echo.Addr()),
+			"",
+		},
+		{
+			fmt.Sprintf(`"net" import "tcp" "%s" net.dial`, echo.Addr()),
+			func(v types.ReqType) bool {
+				return v.String() == fmt.Sprintf("<tcp %s>", echo.Addr())
+			},
+		},
+		{
+			`"net" import
+"tcp" "127.0.0.1:0" net.listen $server
+"tcp" @server net.addr net.dial $client
+@server net.accept $conn
+@client "ping" net.write drop
+@conn 4 net.read
+@conn "pong" net.write drop
+@client 4 net.read +`,
+			"pingpong",
+		},
+	}
+
+	testValues(t, cases, nil)
+}
+
+func TestNetUDP(t *testing.T) {
+	i := newTestInterpreter(t)
+
+	result, err := i.Execute(`"net" import
+"udp" "127.0.0.1:0" net.listenPacket $a
+"udp" "127.0.0.1:0" net.listenPacket $b
+@a "datagram" @b net.addr net.sendTo
+@b 64 net.recvFrom
+@a net.addr`)
+	if err != nil {
+		t.Fatal(err.Error())
+	} else if len(result) != 4 {
+		t.Fatalf("expected 4 values to be on the stack, but found %v instead", result)
+	}
+
+	if result[0].Literal() != float32(8) {
+		t.Errorf("expected 8 bytes to be sent, but found '%s' instead", result[0].String())
+	} else if result[1].Literal() != "datagram" {
+		t.Errorf("expected 'datagram' to be received, but found '%s' instead", result[1].String())
+	} else if result[2].Literal() != result[3].Literal() {
+		t.Errorf("expected the packet to come from '%s', but found '%s' instead", result[3].String(), result[2].String())
+	}
+}
+
+func TestNetDeadline(t *testing.T) {
+	i := newTestInterpreter(t)
+
+	_, err := i.Execute(`"net" import
+"tcp" "127.0.0.1:0" net.listen $server
+"tcp" @server net.addr net.dial $client
+@client 0.05 net.deadline
+@client 64 net.read`)
+	if err == nil || !strings.Contains(err.Error(), "timeout") {
+		t.Fatalf("expected a timeout error, but found '%v' instead", err)
+	}
+}
@@ -0,0 +1,6 @@
+//go:build race
+
+package test
+
+// whether the tests were built with -race, which plugins they load have to be built with too
+const raceEnabled = true
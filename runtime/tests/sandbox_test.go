@@ -0,0 +1,185 @@
+package test
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/voidwyrm-2/reqproc/runtime/env"
+	"github.com/voidwyrm-2/reqproc/runtime/interpreter"
+	"github.com/voidwyrm-2/reqproc/runtime/types"
+)
+
+func TestSandboxImports(t *testing.T) {
+	dir := writeModules(t, map[string]string{
+		"lib.req": `5 $five`,
+	})
+
+	sandbox := func(s *env.Sandbox) func(i *interpreter.Interpreter) {
+		return func(i *interpreter.Interpreter) {
+			if err := i.SetFile(filepath.Join(dir, "main.req")); err != nil {
+				t.Fatal(err.Error())
+			}
+
+			i.SetSandbox(s)
+			i.GetEnv().RegisterModule("host", map[string]types.ReqType{"name": types.Constructors.String("host")})
+		}
+	}
+
+	testValues(t, []valueTestCase{
+		{`"strings" import "a b" " " strings.split 1 @#`, "b"},
+		{`"host" import @host.name`, "host"},
+	}, sandbox(&env.Sandbox{Modules: []string{"strings"}}))
+
+	testValues(t, []valueTestCase{
+		{`"lib.req" import @lib.five`, float32(5)},
+	}, sandbox(&env.Sandbox{FileImports: true}))
+
+	testErrors(t, []valueTestCase{
+		{`"io" import`, "permission denied: importing module 'io' is not allowed"},
+		{`"lib.req" import`, "permission denied: importing 'lib.req' is not allowed, .req imports are disabled"},
+		{`"nonexistent" import`, "nonexistent"},
+	}, sandbox(&env.Sandbox{Modules: []string{"strings"}}))
+}
+
+func TestSandboxFS(t *testing.T) {
+	dir := t.TempDir()
+	root := filepath.Join(dir, "root")
+	outside := filepath.Join(dir, "secret.txt")
+
+	for _, err := range []error{
+		os.MkdirAll(filepath.Join(root, "sub"), 0o755),
+		os.WriteFile(filepath.Join(root, "a.txt"), []byte("inside"), 0o644),
+		os.WriteFile(outside, []byte("secret"), 0o644),
+		os.Symlink(outside, filepath.Join(root, "link.txt")),
+		os.Symlink(dir, filepath.Join(root, "sub", "up")),
+	} {
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+	}
+
+	setup := func(i *interpreter.Interpreter) {
+		i.SetSandbox(&env.Sandbox{Modules: []string{"io", "os"}, FSRoot: root})
+	}
+
+	testValues(t, []valueTestCase{
+		{`"io" import "a.txt" io.readf`, "inside"},
+		{`"io" import "/a.txt" io.readf`, "inside"},
+		{`"io" import "../../a.txt" io.readf`, "inside"},
+		{`"io" import "sub/new.txt" "written" io.writef "/sub/new.txt" io.readf`, "written"},
+		{`"os" import "/" os.fs.items`, func(v types.ReqType) bool {
+			return len(v.Literal().([]types.ReqType)) == 3
+		}},
+		{`"io" import try "link.txt" io.readf err caught notry "read" :caught notry geterr`, func(v types.ReqType) bool {
+			return strings.Contains(v.String(), "permission denied")
+		}},
+	}, setup)
+
+	testErrors(t, []valueTestCase{
+		{`"io" import "link.txt" io.readf`, "permission denied: 'link.txt' is outside of the filesystem root"},
+		{`"io" import "sub/up/secret.txt" io.readf`, "is outside of the filesystem root"},
+		{`"io" import "sub/up/new.txt" "x" io.writef`, "is outside of the filesystem root"},
+		{`"os" import "sub/up" os.fs.items`, "is outside of the filesystem root"},
+	}, setup)
+
+	testErrors(t, []valueTestCase{
+		{`"io" import "a.txt" io.readf`, "permission denied: accessing 'a.txt' is not allowed, the filesystem is disabled"},
+	}, func(i *interpreter.Interpreter) {
+		i.SetSandbox(&env.Sandbox{Modules: []string{"io"}})
+	})
+
+	if _, err := os.Stat(filepath.Join(dir, "new.txt")); err == nil {
+		t.Fatal("a file was written outside of the filesystem root")
+	}
+}
+
+func TestSandboxNetwork(t *testing.T) {
+	srv := newWebTestServer()
+	defer srv.Close()
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	defer l.Close()
+
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+
+			conn.Close()
+		}
+	}()
+
+	sandbox := func(hosts ...string) func(i *interpreter.Interpreter) {
+		return func(i *interpreter.Interpreter) {
+			i.SetTransport(srv.Client().Transport)
+			i.SetSandbox(&env.Sandbox{Modules: []string{"web", "net"}, Hosts: hosts})
+		}
+	}
+
+	testValues(t, []valueTestCase{
+		{fmt.Sprintf(`"web" import "%s/echo" web.get "status" @#`, srv.URL), float32(200)},
+		{fmt.Sprintf(`"web" import "%s/redirect" web.get "status" @#`, srv.URL), float32(200)},
+		{fmt.Sprintf(`"net" import "tcp" "%s" net.dial net.close 1`, l.Addr()), float32(1)},
+	}, sandbox("127.0.0.1"))
+
+	testValues(t, []valueTestCase{
+		{fmt.Sprintf(`"web" import "%s/echo" web.get "status" @#`, srv.URL), float32(200)},
+	}, sandbox(strings.TrimPrefix(srv.URL, "http://")))
+
+	testValues(t, []valueTestCase{
+		{fmt.Sprintf(`"web" import "%s/echo" web.get "status" @#`, srv.URL), float32(200)},
+	}, sandbox("*"))
+
+	testErrors(t, []valueTestCase{
+		{fmt.Sprintf(`"web" import "%s/echo" web.get`, srv.URL), "permission denied: connecting to '127.0.0.1:"},
+		{`"web" import "http://example.com/" web.get`, "connecting to 'example.com:80' is not allowed"},
+		{`"web" import "https://api.example.org/" web.get`, "connecting to 'api.example.org:443' is not allowed"},
+		{fmt.Sprintf(`"net" import "tcp" "%s" net.dial`, l.Addr()), "permission denied: connecting to"},
+		{`"net" import "tcp" "127.0.0.1:0" net.listen`, "permission denied: listening on '127.0.0.1:0' is not allowed"},
+		{`"net" import "udp" "127.0.0.1:0" net.listenPacket`, "listening on '127.0.0.1:0' is not allowed"},
+		{`"web" import "127.0.0.1:0" [] table web.serve`, "listening on '127.0.0.1:0' is not allowed"},
+	}, sandbox("*.example.com", "localhost:8080"))
+}
+
+func TestSandboxHosts(t *testing.T) {
+	s := &env.Sandbox{Hosts: []string{"example.com", "*.example.org", "localhost:8080"}}
+
+	for addr, allowed := range map[string]bool{
+		"example.com:443":     true,
+		"EXAMPLE.com:80":      true,
+		"api.example.org:443": true,
+		"example.org:443":     false,
+		"evil-example.org:80": false,
+		"localhost:8080":      true,
+		"localhost:8081":      false,
+		"other.com:80":        false,
+	} {
+		if err := s.CheckHost(addr); (err == nil) != allowed {
+			t.Fatalf("expected '%s' to be allowed: %t, but found error %v", addr, allowed, err)
+		}
+	}
+
+	var nilSandbox *env.Sandbox
+	if err := nilSandbox.CheckHost("anything:1"); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	i := newTestInterpreter(t)
+	i.SetSandbox(&env.Sandbox{})
+
+	_, err := i.Execute(`"io" import`)
+	if perr := (env.PermissionError{}); !errors.As(err, &perr) {
+		t.Fatalf("expected a PermissionError, but found %v", err)
+	}
+}
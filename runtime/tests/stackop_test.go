@@ -360,3 +360,62 @@ func TestDivision(t *testing.T) {
 	testStack(t, cases)
 }
 */
+
+func TestStackWords(t *testing.T) {
+	// the values expected to be left on the stack, bottom to top
+	numbers := func(values ...float32) []struct {
+		t types.ReqVarType
+		v any
+	} {
+		expected := []struct {
+			t types.ReqVarType
+			v any
+		}{}
+
+		for _, v := range values {
+			expected = append(expected, struct {
+				t types.ReqVarType
+				v any
+			}{types.TypeNumber, v})
+		}
+
+		return expected
+	}
+
+	testStack(t, []stackTestCase{
+		{`1 2 swap`, numbers(2, 1), true},
+		{`1 2 over`, numbers(1, 2, 1), true},
+		{`1 2 3 rot`, numbers(2, 3, 1), true},
+		{`1 2 3 -rot`, numbers(3, 1, 2), true},
+		{`1 2 3 rot -rot`, numbers(1, 2, 3), true},
+		{`1 2 nip`, numbers(2), true},
+		{`1 2 tuck`, numbers(2, 1, 2), true},
+		{`1 2 2dup`, numbers(1, 2, 1, 2), true},
+		{`1 2 3 2drop`, numbers(1), true},
+		{`1 2 3 0 pick`, numbers(1, 2, 3, 3), true},
+		{`1 2 3 2 pick`, numbers(1, 2, 3, 1), true},
+		{`1 2 3 1 roll`, numbers(1, 3, 2), true},
+		{`1 2 3 2 roll`, numbers(2, 3, 1), true},
+		{`1 2 3 0 roll`, numbers(1, 2, 3), true},
+		{`5 (|1:1 2 *) keep`, numbers(10, 5), true},
+		{`5 (|1:1 2 *) (|1:1 3 +) bi`, numbers(10, 8), true},
+		{`5 (|1:1 2 *) (|1:1 3 +) (|1:1 1 -) tri`, numbers(10, 8, 4), true},
+		{`(|1:1 2 *) $double (|1:1 3 +) $add3 5 [@double @add3 @double] cleave`, numbers(10, 8, 10), true},
+		{`(|1:1 2 *) $double (|1:1 3 +) $add3 1 2 5 [@double @add3] spread`, numbers(1, 4, 8), true},
+		{`1 2 3 clear 4`, numbers(4), true},
+		{`1 2 3 depth`, numbers(1, 2, 3, 3), true},
+		{`depth`, numbers(0), true},
+		{`1 (|0:1 depth) $f f`, numbers(1, 0), true},
+	})
+
+	testErrors(t, []valueTestCase{
+		{`1 swap`, "expected any type on the stack but the stack isn't large enough"},
+		{`1 2 rot`, "expected any type on the stack but the stack isn't large enough"},
+		{`1 2 2 pick`, "cannot reach 2 values deep, there are only 2 on the stack"},
+		{`1 2 -1 roll`, "stack depth cannot be negative, but found -1"},
+		{`1 2 0.5 pick`, "cannot use float value as stack depth"},
+		{`1 "a" (|1:1 1 +) keep`, "invalid operation"},
+		{`1 [1] cleave`, "'1' is not a function"},
+		{`(|1:1) $f 1 [@f @f] spread`, "expected 2 values to spread but found 1"},
+	}, nil)
+}
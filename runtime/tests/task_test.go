@@ -0,0 +1,132 @@
+package test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/voidwyrm-2/reqproc/runtime/types"
+	"github.com/voidwyrm-2/reqproc/runtime/types/numbertype"
+)
+
+func TestTasks(t *testing.T) {
+	cases := []valueTestCase{
+		{
+			`"task" import 2 (|1.1 10 *) task.spawn task.wait`,
+			float32(20),
+		},
+		{
+			`"task" import
+1 (|1.1 1 +) task.spawn $a
+2 (|1.1 1 +) task.spawn $b
+3 (|1.2 dup) task.spawn $c
+[@a @b @c] task.join`,
+			[]types.ReqType{numbertype.New(2), numbertype.New(3), numbertype.New(3), numbertype.New(3)},
+		},
+		{
+			`"task" import [1 2] $l @l (|1.1 0 5 !#) task.spawn task.wait drop @l 0 @#`,
+			float32(1),
+		},
+		{
+			`"task" import 1 task.chan $ch @ch 5 task.send @ch task.recv`,
+			float32(5),
+		},
+		{
+			`"task" import 0 task.chan $ch
+@ch (|1.0 $c @c "hi" task.send @c task.close) task.spawn drop
+@ch task.recv`,
+			"hi",
+		},
+		{
+			`"task" import 1 task.chan $a 1 task.chan $b
+@b "second" task.send
+[@a @b] task.select`,
+			float32(1),
+		},
+		{
+			`"task" import 1 task.chan $a 1 task.chan $b
+@a task.close
+@b "second" task.send
+[@a @b] task.select drop`,
+			"second",
+		},
+	}
+
+	testValues(t, cases, nil)
+}
+
+func TestTaskErrors(t *testing.T) {
+	testErrors(t, []valueTestCase{
+		{
+			`"task" import (|0.0 1 "a" +) task.spawn task.wait`,
+			"invalid operation",
+		},
+		{
+			`"task" import 1 task.chan $ch @ch task.close @ch task.recv`,
+			"channel is closed",
+		},
+		{
+			`"task" import 1 task.chan $ch @ch task.close @ch 1 task.send`,
+			"closed channel",
+		},
+		{
+			`"task" import 1 task.chan $ch @ch task.close [@ch] task.select`,
+			"all channels are closed",
+		},
+		{
+			`"task" import "web" import web.jar (|1.0 drop) task.spawn`,
+			"cannot share",
+		},
+		{
+			`"task" import def x 0 !x (|0.0 1 !x) task.spawn task.wait`,
+			"isolated scope",
+		},
+		{
+			`"task" import (|1.1 1 +) task.spawn`,
+			"stack",
+		},
+	}, nil)
+}
+
+// the task reads the globals while the main code changes them in place, which `go test -race` checks is safe
+func TestTaskSharedValuesConcurrently(t *testing.T) {
+	// each function calls itself until n is 0
+	loop := func(name, body string) string {
+		return fmt.Sprintf(`(|1:0 -> n ;
+	try 0 @n pick drop drop notry err %[1]sRecur
+	try 1 "a" + err %[1]sEnd
+	:%[1]sRecur errcl %[2]s @n 1 - %[1]s
+	:%[1]sEnd) $%[1]s `, name, body)
+	}
+
+	testValues(t, []valueTestCase{
+		{
+			`"task" import [1 2 3 4 5 6 7 8] $l ["n" 0] table $t ` +
+				loop("read", `@l 0 @# drop @t "n" @# drop`) +
+				loop("write", `@l 0 @n !# drop @t "n" @n !# drop @t "m" @n !# drop`) +
+				`(|0:1 2000 read @l 0 @# @t "n" @# +) task.spawn $reader 2000 write @reader task.wait @l 0 @# +`,
+			float32(2),
+		},
+	}, nil)
+}
+
+// run with -race; tasks mutate copies of the lists and tables they read from outside of themselves
+func TestTaskSharedValues(t *testing.T) {
+	testValues(t, []valueTestCase{
+		{
+			`"task" import [0 0] $l ["n" 0] table $t
+(|0.0 @l 0 5 !# drop @t "n" 5 !# drop) $poke
+(|0.0 poke poke poke) task.spawn $a
+(|0.0 poke poke poke) task.spawn $b
+(|0.0 @l 0 5 !# drop poke) task.spawn $c
+@l 0 7 !# drop @t "n" 7 !# drop
+[@a @b @c] task.join drop
+@l 0 @# @t "n" @# +`,
+			float32(14),
+		},
+		{
+			`"task" import ["inner" 0] table $t @t "inner" [1] !# drop
+(|0.1 @t.inner 0 2 !#) task.spawn task.wait 0 @# @t.inner 0 @# +`,
+			float32(3),
+		},
+	}, nil)
+}
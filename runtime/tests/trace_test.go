@@ -0,0 +1,119 @@
+package test
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/voidwyrm-2/reqproc/runtime/interpreter"
+	"github.com/voidwyrm-2/reqproc/trace"
+)
+
+func runTraced(t *testing.T, code string, format trace.Format) string {
+	var out bytes.Buffer
+
+	interp, err := interpreter.New(nil)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	interp.SetHook(trace.New(&out, format))
+
+	// the code may fail on purpose, which the trace shows
+	interp.Execute(code)
+
+	return out.String()
+}
+
+func TestTrace(t *testing.T) {
+	cases := []struct {
+		code, expected string
+	}{
+		{"1 2 +", `:1:1 1  [] -> [1]
+:1:3 2  [1] -> [1 2]
+call +  [1 2]
+return +  [3]
+:1:5 +  [1 2] -> [3]
+`},
+		{"3 (|1:1\n2 *) $double double", `:1:1 3  [] -> [3]
+:1:3 (  [3] -> [3 function{any -> any}]
+:2:6 $double  [3 function{any -> any}] -> [3]
+call double  [3]
+  :2:1 2  [3] -> [3 2]
+  call *  [3 2]
+  return *  [6]
+  :2:3 *  [3 2] -> [6]
+return double  [6]
+:2:14 double  [3] -> [6]
+`},
+		{"\"a\" 1 +", `:1:1 "a"  [] -> [a]
+:1:5 1  [a] -> [a 1]
+call +  [a 1]
+return +  []  error: invalid operation 'addition' for types 'string' and 'number'
+:1:7 +  [a 1] -> []  error: error on line 1, col 7: invalid operation 'addition' for types 'string' and 'number'
+`},
+		// a tail call returns with what the function left, and is called with only its inputs
+		{"(|1:1 -> n ; @n 2 *) $dbl (|1:2 -> n ; 0 @n 1 + dbl) $f 4 f", `return f  [0 5]
+call dbl  [5]
+`},
+		{"1 2 3 4 5 6 7 8 9 10 drop", ":1:22 drop  [(2 more) 3 4 5 6 7 8 9 10] -> [(1 more) 2 3 4 5 6 7 8 9]\n"},
+		{`"this string is far too long to be logged whole"`, ":1:1 \"this string is far too long ...  [] -> [this string is far too long t...]\n"},
+	}
+
+	for _, c := range cases {
+		out := runTraced(t, c.code, trace.Human)
+
+		if !strings.Contains(out, c.expected) {
+			t.Fatalf("expected the trace of '%s' to contain:\n%s\nbut it was:\n%s", c.code, c.expected, out)
+		}
+	}
+}
+
+func TestTraceIndent(t *testing.T) {
+	out := runTraced(t, "3 (|1:1 2 *) $double double", trace.Human)
+
+	expected := "call double  [3]\n  :1:9 2  [3] -> [3 2]\n  call *  [3 2]\n  return *  [6]\n  :1:11 *  [3 2] -> [6]\nreturn double  [6]\n"
+	if !strings.Contains(out, expected) {
+		t.Fatalf("expected the trace to contain:\n%s\nbut it was:\n%s", expected, out)
+	}
+}
+
+func TestTraceJSON(t *testing.T) {
+	out := runTraced(t, "1 (|1:1 2 *) $double double", trace.JSON)
+
+	records := []trace.Record{}
+	scn := bufio.NewScanner(strings.NewReader(out))
+
+	for scn.Scan() {
+		var r trace.Record
+		if err := json.Unmarshal(scn.Bytes(), &r); err != nil {
+			t.Fatalf("invalid JSON line '%s': %s", scn.Text(), err.Error())
+		}
+
+		records = append(records, r)
+	}
+
+	events := []string{}
+	for _, r := range records {
+		events = append(events, r.Event+" "+r.Word+r.Function)
+	}
+
+	expected := "step 1, step (, step $double, call double, step 2, call *, return *, step *, return double, step double"
+	if strings.Join(events, ", ") != expected {
+		t.Fatalf("expected the events '%s' but got '%s'", expected, strings.Join(events, ", "))
+	}
+
+	if last := records[len(records)-1]; last.Depth != 0 || last.Line != 1 || last.Col != 22 || strings.Join(last.Before.Values, " ") != "1" || strings.Join(last.After.Values, " ") != "2" {
+		t.Fatalf("unexpected record for 'double': %+v", last)
+	} else if inner := records[4]; inner.Depth != 1 {
+		t.Fatalf("expected the tokens of double to be 1 deep, but they were %d", inner.Depth)
+	}
+}
+
+func TestTraceFormat(t *testing.T) {
+	if _, err := trace.FormatFromString("xml"); err == nil || err.Error() != "invalid trace format 'xml', expected 'human' or 'json'" {
+		t.Fatalf("expected an invalid format error, but got %v", err)
+	}
+}
@@ -0,0 +1,195 @@
+package test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/voidwyrm-2/reqproc/reqproc"
+	"github.com/voidwyrm-2/reqproc/runtime/env"
+	"github.com/voidwyrm-2/reqproc/runtime/types"
+)
+
+func newTestVM(t *testing.T) *reqproc.VM {
+	vm, err := reqproc.New()
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	return vm
+}
+
+// the literals of values, with lists converted recursively
+func literals(values []types.ReqType) []any {
+	lits := []any{}
+	for _, v := range values {
+		if items, ok := v.Literal().([]types.ReqType); ok {
+			lits = append(lits, literals(items))
+		} else {
+			lits = append(lits, v.Literal())
+		}
+	}
+
+	return lits
+}
+
+func TestVM(t *testing.T) {
+	vm := newTestVM(t)
+
+	out := bytes.Buffer{}
+	vm.SetStdout(&out)
+
+	for name, fn := range map[string]any{
+		"greet": func(name string) string {
+			return "hello, " + name
+		},
+		"divmod": func(a, b int) (int, int, error) {
+			if b == 0 {
+				return 0, 0, errors.New("division by zero")
+			}
+
+			return a / b, a % b, nil
+		},
+		"mapList": func(items []float64, fn func(float64) float64) []float64 {
+			mapped := []float64{}
+			for _, n := range items {
+				mapped = append(mapped, fn(n))
+			}
+
+			return mapped
+		},
+		"keys": func(m map[string]any) int {
+			return len(m)
+		},
+	} {
+		if err := vm.RegisterFunc(name, fn); err != nil {
+			t.Fatal(err.Error())
+		}
+	}
+
+	if err := vm.RegisterModule("host", map[string]any{
+		"name":  "tests",
+		"twice": func(n float32) float32 { return n * 2 },
+	}); err != nil {
+		t.Fatal(err.Error())
+	} else if err = vm.SetGlobal("limit", 10); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	cases := []struct {
+		input    string
+		expected []any
+	}{
+		{`"world" greet`, []any{"hello, world"}},
+		{`drop 7 2 divmod`, []any{float32(3), float32(1)}},
+		{`drop drop [1 2 3] (|1.1 10 *) mapList`, []any{[]any{float32(10), float32(20), float32(30)}}},
+		{`drop "host" import @host.name 4 host.twice @limit`, []any{"tests", float32(8), float32(10)}},
+		{`drop drop drop "io" import ["a" 1 "b" 2] table keys "out" io.putl`, []any{float32(2)}},
+	}
+
+	for _, c := range cases {
+		result, err := vm.Execute(c.input)
+		if err != nil {
+			t.Fatal(err.Error() + " (with `" + c.input + "`)")
+		}
+
+		if got := literals(result); !reflect.DeepEqual(got, c.expected) {
+			t.Fatalf("expected %v, but found %v (with `%s`)", c.expected, got, c.input)
+		}
+	}
+
+	if out.String() != "out\n" {
+		t.Fatalf("expected io.putl to write to the VM's stdout, but found %q", out.String())
+	}
+
+	if _, err := vm.Execute(`drop 1 0 divmod`); err == nil || !strings.Contains(err.Error(), "division by zero") {
+		t.Fatalf("expected the Go function's error, but found %v", err)
+	}
+
+	if _, err := vm.Execute(`(|2.1 + ) $add (|1.1 greet) $welcome`); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if result, err := vm.Call("add", 2, 3.5); err != nil {
+		t.Fatal(err.Error())
+	} else if !reflect.DeepEqual(literals(result), []any{float32(5.5)}) {
+		t.Fatalf("unexpected results %v", literals(result))
+	}
+
+	if result, err := vm.Call("welcome", "you"); err != nil {
+		t.Fatal(err.Error())
+	} else if !reflect.DeepEqual(literals(result), []any{"hello, you"}) {
+		t.Fatalf("unexpected results %v", literals(result))
+	}
+
+	if _, err := vm.Call("add", 1); err == nil || err.Error() != "'add' takes 2 arguments, but was given 1" {
+		t.Fatalf("expected an arity error, but found %v", err)
+	} else if _, err = vm.Call("limit"); err == nil || err.Error() != "'limit' is not callable" {
+		t.Fatalf("expected a not callable error, but found %v", err)
+	}
+
+	if err := vm.SetGlobal("limit", "none"); err != nil {
+		t.Fatal(err.Error())
+	} else if v, err := vm.GetGlobal("limit"); err != nil {
+		t.Fatal(err.Error())
+	} else if v.Literal() != "none" {
+		t.Fatalf("expected the updated global, but found '%s'", v.String())
+	} else if err = vm.SetGlobal("add", 1); err == nil {
+		t.Fatal("expected reassigning a constant to fail")
+	} else if err = vm.RegisterFunc("notAFunc", 1); err == nil {
+		t.Fatal("expected registering a non-function to fail")
+	}
+}
+
+func TestVMCancel(t *testing.T) {
+	for _, input := range []string{
+		`try :loop 1 "a" + err loop`,
+		`"task" import 0 task.chan task.recv`,
+	} {
+		vm := newTestVM(t)
+
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+
+		_, err := vm.ExecuteContext(ctx, input)
+		cancel()
+
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Fatalf("expected execution to stop when the context is done, but found %v (with `%s`)", err, input)
+		}
+	}
+}
+
+func TestVMTaskOutlivesRun(t *testing.T) {
+	vm := newTestVM(t)
+	vm.SetLimits(env.Limits{Timeout: 5 * time.Second})
+
+	first, cancelFirst := context.WithCancel(context.Background())
+	defer cancelFirst()
+
+	// both tasks have started by the time the run spawning them is over, and are still waiting
+	_, err := vm.ExecuteContext(first, `"task" import 0 task.chan $ready 0 task.chan $ch 0 task.chan $never
+(|0.1 @ready 1 task.send @ch task.recv) task.spawn $a
+(|0.1 @ready 1 task.send @never task.recv) task.spawn $b
+@ready task.recv @ready task.recv drop drop`)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	// they keep the context of the run which spawned them rather than stopping with it
+	results, err := vm.Execute(`@ch 5 task.send @a task.wait`)
+	if err != nil {
+		t.Fatal(err.Error())
+	} else if lits := literals(results); !reflect.DeepEqual(lits, []any{float32(5)}) {
+		t.Fatalf("expected [5], but found %v", lits)
+	}
+
+	cancelFirst()
+
+	if _, err = vm.Execute(`@b task.wait`); !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected the task to stop with the context of its run, but found %v", err)
+	}
+}
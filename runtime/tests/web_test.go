@@ -0,0 +1,278 @@
+package test
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/voidwyrm-2/reqproc/runtime/interpreter"
+)
+
+func newWebTestServer() *httptest.Server {
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("/echo", func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+
+		w.Header().Set("X-Method", r.Method)
+		w.Header().Set("X-Token", r.Header.Get("X-Token"))
+		w.Header().Set("Content-Type", r.Header.Get("Content-Type"))
+		fmt.Fprintf(w, "%s?%s", body, r.URL.RawQuery)
+	})
+
+	mux.HandleFunc("/missing", func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "nope", http.StatusNotFound)
+	})
+
+	mux.HandleFunc("/redirect", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/echo", http.StatusFound)
+	})
+
+	mux.HandleFunc("/login", func(w http.ResponseWriter, r *http.Request) {
+		http.SetCookie(w, &http.Cookie{Name: "session", Value: "abc"})
+	})
+
+	mux.HandleFunc("/whoami", func(w http.ResponseWriter, r *http.Request) {
+		if c, err := r.Cookie("session"); err == nil {
+			w.Write([]byte(c.Value))
+		}
+	})
+
+	return httptest.NewServer(mux)
+}
+
+func TestWeb(t *testing.T) {
+	srv := newWebTestServer()
+	defer srv.Close()
+
+	cases := []valueTestCase{
+		{
+			fmt.Sprintf(`"web" import ["url" "%s/echo"] table web.request $r @r.status`, srv.URL),
+			float32(200),
+		},
+		{
+			fmt.Sprintf(`"web" import "%s/missing" web.get $r @r.status`, srv.URL),
+			float32(404),
+		},
+		{
+			fmt.Sprintf(`"web" import
+["X-Token" "secret"] table $headers
+["page" 2] table $query
+["method" "put" "url" "%s/echo" "body" "hi" "headers" @headers "query" @query] table web.request $r
+@r.headers "X-Method" @# @r.headers "X-Token" @# + @r.body +`, srv.URL),
+			"PUTsecrethi?page=2",
+		},
+		{
+			fmt.Sprintf(`"web" import "%s/echo" "text/plain" "hello" web.post "body" @#`, srv.URL),
+			"hello?",
+		},
+		{
+			fmt.Sprintf(`"web" import "%s/echo" ["a" 1] table web.postJSON $r @r.headers "Content-Type" @# @r.body +`, srv.URL),
+			`application/json{"a":1}?`,
+		},
+		{
+			fmt.Sprintf(`"web" import
+["Content-Type" "application/vnd.test+json"] table $headers
+["method" "post" "url" "%s/echo" "json" 1 "headers" @headers] table web.request "headers" @# "Content-Type" @#`, srv.URL),
+			"application/vnd.test+json",
+		},
+		{
+			fmt.Sprintf(`"web" import ["url" "%s/redirect" "redirects" 0] table web.request "status" @#`, srv.URL),
+			float32(302),
+		},
+		{
+			fmt.Sprintf(`"web" import "%s/redirect" web.get "url" @#`, srv.URL),
+			srv.URL + "/echo",
+		},
+		{
+			fmt.Sprintf(`"web" import web.jar $jar
+["url" "%s/login" "jar" @jar] table web.request drop
+["url" "%s/whoami" "jar" @jar] table web.request "body" @#`, srv.URL, srv.URL),
+			"abc",
+		},
+		{
+			fmt.Sprintf(`"web" import "%s/echo" web.download`, srv.URL),
+			"?",
+		},
+	}
+
+	testValues(t, cases, func(i *interpreter.Interpreter) {
+		i.SetTransport(srv.Client().Transport)
+	})
+
+	i := newTestInterpreter(t)
+	i.SetTransport(srv.Client().Transport)
+
+	if _, err := i.Execute(fmt.Sprintf(`"web" import "%s/missing" web.download`, srv.URL)); err == nil {
+		t.Fatal("expected web.download to fail on a 404")
+	}
+
+	testErrors(t, []valueTestCase{
+		{
+			fmt.Sprintf(`"web" import ["a" 1] table $j ["method" "post" "url" "%s/echo" "body" "hi" "json" @j] table web.request`, srv.URL),
+			"a request cannot have both a 'body' and a 'json' option",
+		},
+	}, func(i *interpreter.Interpreter) {
+		i.SetTransport(srv.Client().Transport)
+	})
+}
+
+func TestWebServe(t *testing.T) {
+	i := newTestInterpreter(t)
+
+	input := `"web" import
+"hello " $greeting
+def count
+0 !count
+
+(|1.1 $req @greeting @req.params.name +) $hello
+(|1.1 $req ["status" 201 "body" @req.body] table $resp
+ @resp "headers" ["X-Echo" "yes"] table !#) $echo
+(|1.1 drop 1 !count "unreachable") $bump
+
+["GET /hello/{name}" @hello "POST /echo" @echo "/bump" @bump] table web.handler`
+
+	t.Logf("testing `%s`\n", input)
+
+	result, err := i.Execute(input)
+	if err != nil {
+		t.Fatal(err.Error())
+	} else if len(result) != 1 {
+		t.Fatalf("expected 1 value to be on the stack, but found %d instead", len(result))
+	}
+
+	handler, ok := result[0].Literal().(http.Handler)
+	if !ok {
+		t.Fatalf("expected a web handler, but found '%s' instead", result[0].String())
+	}
+
+	srv := httptest.NewServer(handler)
+	defer srv.Close()
+
+	// handlers run concurrently, so make sure they don't trample each other's stacks
+	errs := make(chan error, 20)
+	for n := range cap(errs) {
+		go func() {
+			resp, err := http.Get(fmt.Sprintf("%s/hello/user%d", srv.URL, n))
+			if err != nil {
+				errs <- err
+				return
+			}
+
+			defer resp.Body.Close()
+
+			body, _ := io.ReadAll(resp.Body)
+			if expected := fmt.Sprintf("hello user%d", n); string(body) != expected {
+				errs <- fmt.Errorf("expected '%s', but found '%s' instead", expected, body)
+				return
+			}
+
+			errs <- nil
+		}()
+	}
+
+	for range cap(errs) {
+		if err := <-errs; err != nil {
+			t.Fatal(err.Error())
+		}
+	}
+
+	resp, err := http.Post(srv.URL+"/echo", "text/plain", strings.NewReader("ping"))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusCreated || string(body) != "ping" || resp.Header.Get("X-Echo") != "yes" {
+		t.Fatalf("unexpected response %d '%s' %v", resp.StatusCode, body, resp.Header)
+	}
+
+	if resp, err = http.Get(srv.URL + "/echo"); err != nil {
+		t.Fatal(err.Error())
+	} else if resp.StatusCode != http.StatusMethodNotAllowed {
+		t.Fatalf("expected status %d, but found %d instead", http.StatusMethodNotAllowed, resp.StatusCode)
+	}
+
+	if resp, err = http.Get(srv.URL + "/bump"); err != nil {
+		t.Fatal(err.Error())
+	} else if resp.StatusCode != http.StatusInternalServerError {
+		t.Fatalf("expected reassigning a global from a handler to fail, but got status %d", resp.StatusCode)
+	}
+
+	if v, err := i.GetScope().Read("count"); err != nil {
+		t.Fatal(err.Error())
+	} else if v.Literal() != float32(0) {
+		t.Fatalf("expected count to still be 0, but found '%s' instead", v.String())
+	}
+}
+
+// run with -race; handlers mutate copies of the lists and tables they read from outside of themselves
+func TestWebServeShared(t *testing.T) {
+	i := newTestInterpreter(t)
+
+	// read and write use the globals n times, reading them from the handlers while the main code changes them in place
+	result, err := i.Execute(`"web" import [0] $hits ["last" ""] table $state
+(|1:0 -> n ;
+	try 0 @n pick drop drop notry err readNext
+	try 1 "a" + err readEnd
+	:readNext errcl @hits 0 @# drop @state "last" @# drop @n 1 - read
+	:readEnd) $read
+(|1:0 -> n ;
+	try 0 @n pick drop drop notry err writeNext
+	try 1 "a" + err writeEnd
+	:writeNext errcl @hits 0 2 !# drop @state "last" "main" !# drop @state "n" @n !# drop @n 1 - write
+	:writeEnd) $write
+(|1.1 $req 200 read @state "last" @req.path !# drop ["status" 0] table "status" @hits 0 1 !# 0 @# 200 + !#) $poke
+["/poke" @poke] table web.handler`)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	srv := httptest.NewServer(result[0].Literal().(http.Handler))
+	defer srv.Close()
+
+	errs := make(chan error, 20)
+	for range cap(errs) {
+		go func() {
+			resp, err := http.Get(srv.URL + "/poke")
+			if err != nil {
+				errs <- err
+				return
+			}
+
+			resp.Body.Close()
+
+			// each request sees its own change to the globals, rather than the main code's
+			if resp.StatusCode != http.StatusCreated {
+				errs <- fmt.Errorf("expected status %d, but found %d instead", http.StatusCreated, resp.StatusCode)
+				return
+			}
+
+			errs <- nil
+		}()
+	}
+
+	// the globals are used while the handlers run
+	for range cap(errs) {
+		if _, err = i.Execute(`200 write`); err != nil {
+			t.Fatal(err.Error())
+		}
+	}
+
+	for range cap(errs) {
+		if err := <-errs; err != nil {
+			t.Fatal(err.Error())
+		}
+	}
+
+	if result, err = i.Execute(`@hits 0 @# @state "last" @#`); err != nil {
+		t.Fatal(err.Error())
+	} else if lits := literals(result[len(result)-2:]); lits[0] != float32(2) || lits[1] != "main" {
+		t.Fatalf("expected the handlers to leave the globals alone, but found %v", lits)
+	}
+}
@@ -0,0 +1,465 @@
+package types
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+)
+
+// Calls a ReqProc function with args as its inputs, returning the values it leaves
+type Caller func(fn ReqType, args []ReqType) ([]ReqType, error)
+
+/*
+Creates the values FromGo converts into
+
+this package can't import the packages which implement each type, so they fill these in when they're initialized
+*/
+var Constructors struct {
+	Number func(float32) ReqType
+	String func(string) ReqType
+	List   func(...ReqType) ReqType
+	Table  func(map[string]ReqType) ReqType
+	Handle func(name string, handle any) ReqType
+	// wraps a Go function in a native function
+	Func func(fn reflect.Value) (ReqType, error)
+	// used by Go functions made by ToGo when no Caller is given, set by the interpreter
+	Call Caller
+}
+
+// Implemented by native values which wrap a Go value
+type Handler interface {
+	Handle() any
+}
+
+var (
+	reqTypeType = reflect.TypeFor[ReqType]()
+	errorType   = reflect.TypeFor[error]()
+)
+
+// The ReqProc type a Go type is converted to by FromGo
+func VarTypeOf(t reflect.Type) ReqVarType {
+	if t == reqTypeType || t.Kind() == reflect.Interface {
+		return TypeAny
+	}
+
+	switch t.Kind() {
+	case reflect.Bool, reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Float32, reflect.Float64:
+		return TypeNumber
+	case reflect.String:
+		return TypeString
+	case reflect.Slice, reflect.Array:
+		return TypeList
+	case reflect.Map, reflect.Struct:
+		return TypeTable
+	case reflect.Func:
+		return TypeFunction
+	default:
+		return TypeNative
+	}
+}
+
+// Whether the last result of a Go function type is an error
+func ReturnsError(t reflect.Type) bool {
+	return t.NumOut() > 0 && t.Out(t.NumOut()-1) == errorType
+}
+
+/*
+The table key of a struct field, which is its `reqproc:"name"` tag or its name; fields tagged with "-" and unexported fields are skipped
+
+	type User struct {
+		Name  string `reqproc:"name"`
+		Admin bool   `reqproc:"-"`
+	}
+*/
+func fieldKey(f reflect.StructField) (string, bool) {
+	if !f.IsExported() {
+		return "", false
+	}
+
+	switch tag := f.Tag.Get("reqproc"); tag {
+	case "-":
+		return "", false
+	case "":
+		return f.Name, true
+	default:
+		return tag, true
+	}
+}
+
+/*
+Converts a Go value into a ReqProc value
+
+  - bools become 1 or 0, and all integer and float types become numbers
+  - slices and arrays become lists
+  - maps with string keys and structs (see fieldKey) become tables
+  - functions become native functions, see functiontype.FromGo
+  - ReqProc values are returned as-is
+  - anything else, like pointers and channels, becomes a native value wrapping it
+*/
+func FromGo(v any) (ReqType, error) {
+	return fromGo(reflect.ValueOf(v))
+}
+
+func fromGo(v reflect.Value) (ReqType, error) {
+	if !v.IsValid() {
+		return nil, errors.New("cannot convert nil to a ReqProc value")
+	} else if v.Type().Implements(reqTypeType) {
+		if v.Kind() == reflect.Interface || v.Kind() == reflect.Pointer {
+			if v.IsNil() {
+				return nil, errors.New("cannot convert nil to a ReqProc value")
+			}
+		}
+
+		return v.Interface().(ReqType), nil
+	}
+
+	c := Constructors
+
+	switch v.Kind() {
+	case reflect.Interface:
+		return fromGo(v.Elem())
+	case reflect.Bool:
+		if v.Bool() {
+			return c.Number(1), nil
+		}
+
+		return c.Number(0), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return c.Number(float32(v.Int())), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return c.Number(float32(v.Uint())), nil
+	case reflect.Float32, reflect.Float64:
+		return c.Number(float32(v.Float())), nil
+	case reflect.String:
+		return c.String(v.String()), nil
+	case reflect.Slice, reflect.Array:
+		items := make([]ReqType, 0, v.Len())
+
+		for i := range v.Len() {
+			item, err := fromGo(v.Index(i))
+			if err != nil {
+				return nil, fmt.Errorf("index %d: %s", i, err.Error())
+			}
+
+			items = append(items, item)
+		}
+
+		return c.List(items...), nil
+	case reflect.Map:
+		if v.Type().Key().Kind() != reflect.String {
+			return nil, fmt.Errorf("cannot convert %s to a table, its keys must be strings", v.Type())
+		}
+
+		m := map[string]ReqType{}
+
+		iter := v.MapRange()
+		for iter.Next() {
+			item, err := fromGo(iter.Value())
+			if err != nil {
+				return nil, fmt.Errorf("key '%s': %s", iter.Key().String(), err.Error())
+			}
+
+			m[iter.Key().String()] = item
+		}
+
+		return c.Table(m), nil
+	case reflect.Struct:
+		m := map[string]ReqType{}
+
+		for i := range v.NumField() {
+			key, ok := fieldKey(v.Type().Field(i))
+			if !ok {
+				continue
+			}
+
+			item, err := fromGo(v.Field(i))
+			if err != nil {
+				return nil, fmt.Errorf("field '%s': %s", key, err.Error())
+			}
+
+			m[key] = item
+		}
+
+		return c.Table(m), nil
+	case reflect.Func:
+		if v.IsNil() {
+			return nil, errors.New("cannot convert nil to a ReqProc value")
+		}
+
+		return c.Func(v)
+	default:
+		return c.Handle(v.Type().String(), v.Interface()), nil
+	}
+}
+
+// Converts each Go value with FromGo
+func FromGoAll(values ...any) ([]ReqType, error) {
+	converted := make([]ReqType, 0, len(values))
+
+	for _, v := range values {
+		c, err := FromGo(v)
+		if err != nil {
+			return nil, err
+		}
+
+		converted = append(converted, c)
+	}
+
+	return converted, nil
+}
+
+// the Go value a ReqProc value is converted to when the Go type doesn't say what it should be
+func naturalGo(v ReqType) any {
+	switch v.Type() {
+	case TypeList:
+		items := []any{}
+		for _, item := range v.Literal().([]ReqType) {
+			items = append(items, naturalGo(item))
+		}
+
+		return items
+	case TypeTable:
+		m := map[string]any{}
+		for k, item := range v.Literal().(map[string]ReqType) {
+			m[k] = naturalGo(item)
+		}
+
+		return m
+	case TypeFunction:
+		return v
+	default:
+		return v.Literal()
+	}
+}
+
+/*
+Converts a ReqProc value into a Go value of type t, the reverse of FromGo
+
+when t is an interface, numbers become float32s, lists become []any, tables become map[string]any, functions stay as they are
+and natives become the value they wrap
+*/
+func ToGo(v ReqType, t reflect.Type) (any, error) {
+	out, err := ToGoValue(v, t, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return out.Interface(), nil
+}
+
+/*
+Like ToGo, but returns a reflect.Value
+
+ReqProc functions become Go functions which call them with call (or Constructors.Call if it's nil, which is an error if
+the interpreter package isn't loaded to set it); if a call fails and the Go function doesn't return an error, it panics
+*/
+func ToGoValue(v ReqType, t reflect.Type, call Caller) (reflect.Value, error) {
+	mismatch := func() (reflect.Value, error) {
+		return reflect.Value{}, fmt.Errorf("cannot use '%s(type %s)' as Go type %s", v.String(), v.Type().String(), t)
+	}
+
+	if t == reqTypeType {
+		return reflect.ValueOf(&v).Elem(), nil
+	} else if h, ok := v.(Handler); ok && h.Handle() != nil {
+		if reflect.TypeOf(h.Handle()).AssignableTo(t) {
+			out := reflect.New(t).Elem()
+			out.Set(reflect.ValueOf(h.Handle()))
+
+			return out, nil
+		}
+
+		return mismatch()
+	}
+
+	out := reflect.New(t).Elem()
+
+	switch t.Kind() {
+	case reflect.Interface:
+		n := reflect.ValueOf(naturalGo(v))
+		if !n.IsValid() || !n.Type().AssignableTo(t) {
+			return mismatch()
+		}
+
+		out.Set(n)
+	case reflect.Bool, reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Float32, reflect.Float64:
+		if v.Type() != TypeNumber {
+			return mismatch()
+		}
+
+		f := v.Literal().(float32)
+		_, frac := math.Modf(float64(f))
+
+		switch t.Kind() {
+		case reflect.Bool:
+			out.SetBool(f != 0)
+		case reflect.Float32, reflect.Float64:
+			out.SetFloat(float64(f))
+		// compared as floats, since converting a float which doesn't fit into an integer type gives an arbitrary value
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			if frac != 0 || f < 0 || float64(f) >= math.Ldexp(1, t.Bits()) {
+				return reflect.Value{}, fmt.Errorf("%v cannot be used as Go type %s", f, t)
+			}
+
+			out.SetUint(uint64(f))
+		default:
+			if limit := math.Ldexp(1, t.Bits()-1); frac != 0 || float64(f) >= limit || float64(f) < -limit {
+				return reflect.Value{}, fmt.Errorf("%v cannot be used as Go type %s", f, t)
+			}
+
+			out.SetInt(int64(f))
+		}
+	case reflect.String:
+		if v.Type() != TypeString {
+			return mismatch()
+		}
+
+		out.SetString(v.Literal().(string))
+	case reflect.Slice, reflect.Array:
+		if v.Type() != TypeList {
+			return mismatch()
+		}
+
+		items := v.Literal().([]ReqType)
+
+		if t.Kind() == reflect.Slice {
+			out = reflect.MakeSlice(t, len(items), len(items))
+		} else if len(items) != t.Len() {
+			return reflect.Value{}, fmt.Errorf("expected a list of length %d for Go type %s, but found length %d", t.Len(), t, len(items))
+		}
+
+		for i, item := range items {
+			c, err := ToGoValue(item, t.Elem(), call)
+			if err != nil {
+				return reflect.Value{}, fmt.Errorf("index %d: %s", i, err.Error())
+			}
+
+			out.Index(i).Set(c)
+		}
+	case reflect.Map:
+		if v.Type() != TypeTable || t.Key().Kind() != reflect.String {
+			return mismatch()
+		}
+
+		out = reflect.MakeMap(t)
+
+		for k, item := range v.Literal().(map[string]ReqType) {
+			c, err := ToGoValue(item, t.Elem(), call)
+			if err != nil {
+				return reflect.Value{}, fmt.Errorf("key '%s': %s", k, err.Error())
+			}
+
+			out.SetMapIndex(reflect.ValueOf(k).Convert(t.Key()), c)
+		}
+	case reflect.Struct:
+		if v.Type() != TypeTable {
+			return mismatch()
+		}
+
+		m := v.Literal().(map[string]ReqType)
+
+		// keys without a matching field are ignored, and fields without a matching key are left as their zero value
+		for i := range t.NumField() {
+			key, ok := fieldKey(t.Field(i))
+			if !ok {
+				continue
+			}
+
+			item, ok := m[key]
+			if !ok {
+				continue
+			}
+
+			c, err := ToGoValue(item, t.Field(i).Type, call)
+			if err != nil {
+				return reflect.Value{}, fmt.Errorf("field '%s': %s", key, err.Error())
+			}
+
+			out.Field(i).Set(c)
+		}
+	case reflect.Pointer:
+		c, err := ToGoValue(v, t.Elem(), call)
+		if err != nil {
+			return reflect.Value{}, err
+		}
+
+		out = reflect.New(t.Elem())
+		out.Elem().Set(c)
+	case reflect.Func:
+		if v.Type() != TypeFunction {
+			return mismatch()
+		}
+
+		if call == nil {
+			call = Constructors.Call
+		}
+
+		if call == nil {
+			return reflect.Value{}, fmt.Errorf("cannot use '%s(type %s)' as Go type %s without an interpreter to call it", v.String(), v.Type().String(), t)
+		}
+
+		return goFunc(v, t, call), nil
+	default:
+		return mismatch()
+	}
+
+	return out, nil
+}
+
+// Creates a Go function of type t which calls a ReqProc function
+func goFunc(fn ReqType, t reflect.Type, call Caller) reflect.Value {
+	return reflect.MakeFunc(t, func(args []reflect.Value) []reflect.Value {
+		results := make([]reflect.Value, t.NumOut())
+		for i := range results {
+			results[i] = reflect.Zero(t.Out(i))
+		}
+
+		fail := func(err error) []reflect.Value {
+			if !ReturnsError(t) {
+				panic(err)
+			}
+
+			results[len(results)-1] = reflect.ValueOf(&err).Elem()
+
+			return results
+		}
+
+		in := make([]ReqType, 0, len(args))
+
+		for _, a := range args {
+			v, err := fromGo(a)
+			if err != nil {
+				return fail(err)
+			}
+
+			in = append(in, v)
+		}
+
+		left, err := call(fn, in)
+		if err != nil {
+			return fail(err)
+		}
+
+		want := t.NumOut()
+		if ReturnsError(t) {
+			want--
+		}
+
+		if len(left) < want {
+			return fail(fmt.Errorf("expected %d results but the function left %d", want, len(left)))
+		}
+
+		for i, v := range left[len(left)-want:] {
+			c, err := ToGoValue(v, t.Out(i), call)
+			if err != nil {
+				return fail(err)
+			}
+
+			results[i] = c
+		}
+
+		return results
+	})
+}
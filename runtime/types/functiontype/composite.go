@@ -0,0 +1,88 @@
+package functiontype
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/voidwyrm-2/reqproc/runtime/types"
+	"github.com/voidwyrm-2/reqproc/runtime/types/basetype"
+)
+
+// A step of a composite function
+type Part struct {
+	Value types.ReqType
+	// whether Value is a function that's called, instead of a value that's pushed
+	Call bool
+}
+
+func (p Part) String() string {
+	if p.Call {
+		return p.Value.String()
+	} else if p.Value.Type() == types.TypeFunction {
+		return "@" + p.Value.String()
+	} else if p.Value.Type() == types.TypeString {
+		return fmt.Sprintf("%q", p.Value.Literal())
+	}
+
+	return p.Value.String()
+}
+
+/*
+Creates a function which pushes or calls each of its parts in order, like the ones made by curry and compose
+
+its arity is worked out from its parts, and it's variadic if any of the functions it calls are
+*/
+func NewComposite(parts ...Part) ReqFunctionType {
+	arity := Arity{}
+	variadic := false
+
+	// arity.In is how many values the parts take from below the function's inputs, arity.Out how many they've left
+	for _, p := range parts {
+		if !p.Call {
+			arity.Out++
+			continue
+		}
+
+		fn := p.Value.(ReqFunctionType)
+		variadic = variadic || fn.variadic
+
+		if in := fn.arity.In; in > arity.Out {
+			arity.In += in - arity.Out
+			arity.Out = 0
+		} else {
+			arity.Out -= in
+		}
+
+		arity.Out += fn.arity.Out
+	}
+
+	return ReqFunctionType{
+		parts:       append([]Part{}, parts...),
+		arity:       arity,
+		input:       makeTypeSlice(arity.In, types.TypeAny),
+		output:      makeTypeSlice(arity.Out, types.TypeAny),
+		variadic:    variadic,
+		ReqBaseType: basetype.New(types.TypeFunction),
+	}
+}
+
+// Returns the parts of a composite function, or nil if it isn't one
+func (rft ReqFunctionType) Parts() []Part {
+	return rft.parts
+}
+
+// Returns a composite function with its parts replaced, which must push or call values of the same kinds so its arity stays right
+func (rft ReqFunctionType) SetParts(parts []Part) ReqFunctionType {
+	rft.parts = parts
+	return rft
+}
+
+func formatParts(parts []Part) string {
+	formatted := make([]string, 0, len(parts))
+
+	for _, p := range parts {
+		formatted = append(formatted, p.String())
+	}
+
+	return "(" + strings.Join(formatted, " ") + ")"
+}
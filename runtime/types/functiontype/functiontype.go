@@ -2,6 +2,7 @@ package functiontype
 
 import (
 	"fmt"
+	"slices"
 	"strconv"
 	"strings"
 
@@ -21,8 +22,14 @@ type ReqFunctionType struct {
 	doc           string
 	native        NativeFunction
 	tokens        []tokens.Token
-	signature     float32
+	arity         Arity
 	input, output []types.ReqVarType
+	typed         bool
+	variadic      bool
+	closure       *scope.Scope
+	params        []string
+	parts         []Part
+	name          string
 }
 
 /*
@@ -52,20 +59,48 @@ func parseFuncParams(fn reflect.Type) []types.ReqVarType {
 }
 */
 
-func parseSignature(signature float32) (uint32, uint32) {
-	s := fmt.Sprint(signature)
-	if !strings.Contains(s, ".") {
-		s += ".0"
+/*
+The amount of values a function takes off the stack and leaves on it
+
+it's written as `|2:1` in function literals, or `|2.1`, which was how signatures used to be written
+*/
+type Arity struct {
+	In, Out int
+}
+
+// Parses an arity like "2:1" or "2.1"; the amount of outputs can be left out, e.g. "2"
+func ParseArity(s string) (Arity, error) {
+	in, out, hasOut := strings.Cut(s, ":")
+	if !hasOut {
+		in, out, hasOut = strings.Cut(s, ".")
 	}
 
-	if n, err := strconv.ParseUint(strings.Split(s, ".")[1], 10, 32); err != nil {
-		panic(err.Error())
-	} else {
-		return uint32(signature), uint32(n)
+	a := Arity{}
+	invalid := fmt.Errorf("invalid signature '%s', expected something like '2:1'", s)
+
+	n, err := strconv.ParseUint(in, 10, 31)
+	if err != nil {
+		return Arity{}, invalid
 	}
+
+	a.In = int(n)
+
+	if hasOut {
+		if n, err = strconv.ParseUint(out, 10, 31); err != nil {
+			return Arity{}, invalid
+		}
+
+		a.Out = int(n)
+	}
+
+	return a, nil
 }
 
-func makeTypeSlice(length uint32, t types.ReqVarType) []types.ReqVarType {
+func (a Arity) String() string {
+	return fmt.Sprintf("%d:%d", a.In, a.Out)
+}
+
+func makeTypeSlice(length int, t types.ReqVarType) []types.ReqVarType {
 	sl := make([]types.ReqVarType, 0, length)
 
 	for range length {
@@ -75,43 +110,190 @@ func makeTypeSlice(length uint32, t types.ReqVarType) []types.ReqVarType {
 	return sl
 }
 
-func makeSignature(i, o int) float32 {
-	sig := float32(o)
-	for sig > 1 {
-		sig *= 0.1
-	}
-
-	return float32(i) + sig
-}
-
 func NewNative(fn func(sc *scope.Scope, st *stack.Stack, callf func(rft ReqFunctionType, sc *scope.Scope, st *stack.Stack) error) error, input, output []types.ReqVarType) ReqFunctionType {
 	return ReqFunctionType{
 		native:      fn,
-		signature:   makeSignature(len(input), len(output)),
+		arity:       Arity{In: len(input), Out: len(output)},
 		input:       input,
 		output:      output,
 		ReqBaseType: basetype.New(types.TypeFunction),
 	}
 }
 
-func NewSigNative(fn func(sc *scope.Scope, st *stack.Stack, callf func(rft ReqFunctionType, sc *scope.Scope, st *stack.Stack) error) error, signature float32) ReqFunctionType {
-	input, output := parseSignature(signature)
+func NewSigNative(fn func(sc *scope.Scope, st *stack.Stack, callf func(rft ReqFunctionType, sc *scope.Scope, st *stack.Stack) error) error, arity Arity) ReqFunctionType {
+	return NewNative(fn, makeTypeSlice(arity.In, types.TypeAny), makeTypeSlice(arity.Out, types.TypeAny))
+}
 
-	return NewNative(fn, makeTypeSlice(input, types.TypeAny), makeTypeSlice(output, types.TypeAny))
+func New(tokens []tokens.Token, arity Arity) ReqFunctionType {
+	return ReqFunctionType{
+		tokens:      tokens,
+		arity:       arity,
+		input:       makeTypeSlice(arity.In, types.TypeAny),
+		output:      makeTypeSlice(arity.Out, types.TypeAny),
+		ReqBaseType: basetype.New(types.TypeFunction),
+	}
 }
 
-func New(tokens []tokens.Token, signature float32) ReqFunctionType {
-	input, output := parseSignature(signature)
+// parses one of the types of a typed signature, joining unions like `string|number`
+func parseType(word string) (types.ReqVarType, error) {
+	var t types.ReqVarType
 
+	for _, name := range strings.Split(word, "|") {
+		member, err := types.TypeFromString(name)
+		if err != nil {
+			return types.TypeBase, fmt.Errorf("invalid type '%s' in signature", name)
+		}
+
+		t |= member
+	}
+
+	return t, nil
+}
+
+/*
+Parses the literal of a typed signature token, e.g. `string number -> list`
+
+the types are returned top of the stack first, like the input and output of native functions
+*/
+func ParseTypedSignature(signature string) (input, output []types.ReqVarType, err error) {
+	in, out, ok := strings.Cut(signature, "->")
+	if !ok {
+		return nil, nil, fmt.Errorf("expected '->' in signature '%s'", signature)
+	}
+
+	parse := func(words []string) ([]types.ReqVarType, error) {
+		parsed := make([]types.ReqVarType, len(words))
+
+		for i, word := range words {
+			t, err := parseType(word)
+			if err != nil {
+				return nil, err
+			}
+
+			parsed[len(words)-1-i] = t
+		}
+
+		return parsed, nil
+	}
+
+	if input, err = parse(strings.Fields(in)); err != nil {
+		return nil, nil, err
+	} else if output, err = parse(strings.Fields(out)); err != nil {
+		return nil, nil, err
+	}
+
+	return input, output, nil
+}
+
+// Creates a ReqProc function whose inputs are checked when it's called and outputs are checked when it returns
+func NewTyped(tokens []tokens.Token, input, output []types.ReqVarType) ReqFunctionType {
 	return ReqFunctionType{
 		tokens:      tokens,
-		signature:   signature,
-		input:       makeTypeSlice(input, types.TypeAny),
-		output:      makeTypeSlice(output, types.TypeAny),
+		arity:       Arity{In: len(input), Out: len(output)},
+		input:       input,
+		output:      output,
+		typed:       true,
 		ReqBaseType: basetype.New(types.TypeFunction),
 	}
 }
 
+// Marks a native function as leaving a varying amount of values (e.g. because it calls a function it was given), so its output isn't checked
+func (rft ReqFunctionType) SetVariadic() ReqFunctionType {
+	rft.variadic = true
+	return rft
+}
+
+/*
+Creates a ReqProc function from the tokens between the parentheses of a function literal, which start with its signature
+
+the signature can be followed by names for the inputs, e.g. `|2:1 -> a b ;`
+*/
+func Parse(content []tokens.Token) (ReqFunctionType, error) {
+	if len(content) == 0 {
+		return ReqFunctionType{}, fmt.Errorf("expected '%s' at the start of the function", tokens.Signature.PublicString())
+	}
+
+	sig, body := content[0], content[1:]
+	if !sig.Iskind(tokens.Signature) {
+		return ReqFunctionType{}, sig.Errf("expected '%s', but found '%s' instead", tokens.Signature.PublicString(), sig.Lit())
+	}
+
+	var rft ReqFunctionType
+
+	if strings.Contains(sig.Lit(), "->") {
+		input, output, err := ParseTypedSignature(sig.Lit())
+		if err != nil {
+			return ReqFunctionType{}, sig.Err(err)
+		}
+
+		rft = NewTyped(body, input, output)
+	} else {
+		arity, err := ParseArity(sig.Lit())
+		if err != nil {
+			return ReqFunctionType{}, sig.Err(err)
+		}
+
+		rft = New(body, arity)
+	}
+
+	if len(body) == 0 || !body[0].Iskind(tokens.Params) {
+		return rft, nil
+	}
+
+	params := strings.Fields(body[0].Lit())
+
+	if len(params) != rft.arity.In {
+		return ReqFunctionType{}, body[0].Errf("function takes %d values, but names %d", rft.arity.In, len(params))
+	}
+
+	for n, name := range params {
+		if _, ok := types.IllegalVariableNames[name]; ok {
+			return ReqFunctionType{}, body[0].Errf("'%s' cannot be used as a name", name)
+		} else if slices.Contains(params[:n], name) {
+			return ReqFunctionType{}, body[0].Errf("input '%s' is named more than once", name)
+		}
+	}
+
+	rft.tokens = body[1:]
+	rft.params = params
+
+	return rft, nil
+}
+
+// Names a function, which a ReqProc function can always call itself by
+func (rft ReqFunctionType) SetName(name string) ReqFunctionType {
+	rft.name = name
+	return rft
+}
+
+// Returns the name of the constant the function was first assigned to, or an empty string if it hasn't been
+func (rft ReqFunctionType) Name() string {
+	return rft.name
+}
+
+// Returns the names a ReqProc function gives its inputs, bottom to top, or nil if it doesn't name them
+func (rft ReqFunctionType) Params() []string {
+	return rft.params
+}
+
+/*
+Makes a ReqProc function run in the scope it was defined in, so it can use the variables and constants that were around it,
+even after the code that defined them has finished
+*/
+func (rft ReqFunctionType) SetScope(sc *scope.Scope) ReqFunctionType {
+	rft.closure = sc
+	return rft
+}
+
+// Returns the scope the function was defined in, or nil if it runs in the scope it's called from
+func (rft ReqFunctionType) Scope() *scope.Scope {
+	return rft.closure
+}
+
+func (rft ReqFunctionType) Variadic() bool {
+	return rft.variadic
+}
+
 func (rft ReqFunctionType) SetDoc(doc string) types.ReqType {
 	rft.doc = doc
 	return rft
@@ -125,13 +307,18 @@ func (rft ReqFunctionType) Output() []types.ReqVarType {
 	return rft.output
 }
 
-func (rft ReqFunctionType) Signature() float32 {
-	return rft.signature
+// Reports whether the function's outputs are checked after it returns, which is the case for functions with a typed signature
+func (rft ReqFunctionType) Typed() bool {
+	return rft.typed
+}
+
+func (rft ReqFunctionType) Arity() Arity {
+	return rft.arity
 }
 
-func (rft ReqFunctionType) ExpectSignature(expected float32) error {
-	if rft.signature != expected {
-		return fmt.Errorf("expected signature %f but found %f instead", expected, rft.signature)
+func (rft ReqFunctionType) ExpectArity(expected Arity) error {
+	if rft.arity != expected {
+		return fmt.Errorf("expected signature |%s but found |%s instead", expected, rft.arity)
 	}
 
 	return nil
@@ -142,7 +329,9 @@ func (rft ReqFunctionType) Doc() string {
 }
 
 func (rft ReqFunctionType) Literal() any {
-	if rft.native == nil {
+	if rft.parts != nil {
+		return rft.parts
+	} else if rft.native == nil {
 		return rft.tokens
 	}
 
@@ -172,5 +361,12 @@ func (rft ReqFunctionType) String() string {
 		output = append(output, t.String())
 	}
 
-	return fmt.Sprintf("function{%s -> %s}", strings.Join(input, ", "), strings.Join(output, ", "))
+	signature := fmt.Sprintf("function{%s -> %s}", strings.Join(input, ", "), strings.Join(output, ", "))
+
+	// composite functions show what they're made of
+	if rft.parts != nil {
+		return signature + formatParts(rft.parts)
+	}
+
+	return signature
 }
@@ -0,0 +1,102 @@
+package functiontype
+
+import (
+	"fmt"
+	"reflect"
+
+	"github.com/voidwyrm-2/reqproc/runtime/scope"
+	"github.com/voidwyrm-2/reqproc/runtime/stack"
+	"github.com/voidwyrm-2/reqproc/runtime/types"
+)
+
+func init() {
+	types.Constructors.Func = func(fn reflect.Value) (types.ReqType, error) {
+		return fromGoValue(fn)
+	}
+}
+
+/*
+Creates a native function from a Go function, converting its arguments and results with types.ToGo and types.FromGo
+
+its arguments are taken off the stack (the last argument being the top) and its results are pushed in order;
+if its last result is an error, it's returned as a ReqProc error instead of being pushed
+*/
+func FromGo(fn any) (ReqFunctionType, error) {
+	v := reflect.ValueOf(fn)
+	if v.Kind() != reflect.Func || v.IsNil() {
+		return ReqFunctionType{}, fmt.Errorf("expected a function but found %T", fn)
+	}
+
+	return fromGoValue(v)
+}
+
+func fromGoValue(fn reflect.Value) (ReqFunctionType, error) {
+	t := fn.Type()
+	if t.IsVariadic() {
+		return ReqFunctionType{}, fmt.Errorf("cannot use variadic function %s", t)
+	}
+
+	// input types are given top of the stack first, which is the last argument
+	input := make([]types.ReqVarType, t.NumIn())
+	for i := range t.NumIn() {
+		input[t.NumIn()-1-i] = types.VarTypeOf(t.In(i))
+	}
+
+	results := t.NumOut()
+	if types.ReturnsError(t) {
+		results--
+	}
+
+	output := make([]types.ReqVarType, results)
+	for i := range results {
+		output[results-1-i] = types.VarTypeOf(t.Out(i))
+	}
+
+	return NewNative(func(sc *scope.Scope, st *stack.Stack, callf func(rft ReqFunctionType, sc *scope.Scope, st *stack.Stack) error) (err error) {
+		defer func() {
+			if r := recover(); r != nil {
+				err = fmt.Errorf("Go function panicked: %v", r)
+			}
+		}()
+
+		// functions given to the Go function are called in the scope it was called from
+		call := func(f types.ReqType, args []types.ReqType) ([]types.ReqType, error) {
+			fst := stack.New(args...)
+			if err := callf(f.(ReqFunctionType), sc, &fst); err != nil {
+				return nil, err
+			}
+
+			return fst.Slice(), nil
+		}
+
+		args := st.PopN(t.NumIn())
+		in := make([]reflect.Value, len(args))
+
+		for i, a := range args {
+			if in[i], err = types.ToGoValue(a, t.In(i), call); err != nil {
+				return fmt.Errorf("argument %d: %s", i+1, err.Error())
+			}
+		}
+
+		out := fn.Call(in)
+
+		if types.ReturnsError(t) {
+			if e := out[len(out)-1]; !e.IsNil() {
+				return e.Interface().(error)
+			}
+
+			out = out[:len(out)-1]
+		}
+
+		for _, o := range out {
+			v, err := types.FromGo(o.Interface())
+			if err != nil {
+				return err
+			}
+
+			st.Push(v)
+		}
+
+		return nil
+	}, input, output), nil
+}
@@ -10,6 +10,12 @@ import (
 	"github.com/voidwyrm-2/reqproc/runtime/types/numbertype"
 )
 
+func init() {
+	types.Constructors.List = func(value ...types.ReqType) types.ReqType {
+		return New(value...)
+	}
+}
+
 type ReqListType struct {
 	basetype.ReqBaseType
 	value []types.ReqType
@@ -1,26 +1,59 @@
 package nativetype
 
 import (
-	"fmt"
-	"unsafe"
+	"reflect"
 
 	"github.com/voidwyrm-2/reqproc/runtime/types"
 	"github.com/voidwyrm-2/reqproc/runtime/types/basetype"
 )
 
+func init() {
+	types.Constructors.Handle = func(name string, handle any) types.ReqType {
+		return NewHandle(name, handle)
+	}
+}
+
+// A Go value which has no ReqProc equivalent, e.g. a cookie jar or a connection
 type ReqNativeType struct {
 	basetype.ReqBaseType
-	value unsafe.Pointer
+	handle any
+	goType reflect.Type
+	name   string
+}
+
+// Wraps a Go value; name is what's shown when the value is printed
+func NewHandle(name string, handle any) ReqNativeType {
+	return ReqNativeType{handle: handle, goType: reflect.TypeOf(handle), name: name, ReqBaseType: basetype.New(types.TypeNative)}
+}
+
+// Wraps a Go value, named after its Go type
+func New(handle any) ReqNativeType {
+	return NewHandle(reflect.TypeOf(handle).String(), handle)
 }
 
-func New(value unsafe.Pointer) ReqNativeType {
-	return ReqNativeType{value: value, ReqBaseType: basetype.New(types.TypeNative)}
+func (rnt ReqNativeType) Handle() any {
+	return rnt.handle
+}
+
+// The type of the wrapped value, which natives can check before using it
+func (rnt ReqNativeType) GoType() reflect.Type {
+	return rnt.goType
 }
 
 func (rnt ReqNativeType) String() string {
-	return "<" + fmt.Sprint(rnt.value) + ">"
+	return "<" + rnt.name + ">"
 }
 
 func (rnt ReqNativeType) Literal() any {
-	return rnt.value
+	return rnt.handle
+}
+
+// natives are equal if they wrap the same value; values which Go can't compare are never equal
+func (rnt ReqNativeType) Cmp(other types.ReqType) (bool, int) {
+	o, ok := other.(ReqNativeType)
+	if !ok || rnt.goType != o.goType || rnt.goType == nil || !rnt.goType.Comparable() {
+		return rnt.ReqBaseType.Cmp(other)
+	}
+
+	return rnt.handle == o.handle, 0
 }
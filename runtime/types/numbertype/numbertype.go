@@ -15,6 +15,12 @@ var (
 	ValueFalse = New(0)
 )
 
+func init() {
+	types.Constructors.Number = func(value float32) types.ReqType {
+		return New(value)
+	}
+}
+
 type ReqNumberType struct {
 	basetype.ReqBaseType
 	value float32
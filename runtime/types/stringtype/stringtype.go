@@ -10,6 +10,12 @@ import (
 	"github.com/voidwyrm-2/reqproc/runtime/types/numbertype"
 )
 
+func init() {
+	types.Constructors.String = func(value string) types.ReqType {
+		return New(value)
+	}
+}
+
 type ReqStringType struct {
 	basetype.ReqBaseType
 	value string
@@ -8,6 +8,12 @@ import (
 	"github.com/voidwyrm-2/reqproc/runtime/types/basetype"
 )
 
+func init() {
+	types.Constructors.Table = func(value map[string]types.ReqType) types.ReqType {
+		return New(value)
+	}
+}
+
 type ReqTableType struct {
 	basetype.ReqBaseType
 	value map[string]types.ReqType
@@ -17,6 +23,25 @@ func New(value map[string]types.ReqType) ReqTableType {
 	return ReqTableType{value: value, ReqBaseType: basetype.New(types.TypeTable)}
 }
 
+// Creates a table from a list of alternating keys and values, e.g. `["a" 1 "b" 2]`
+func FromPairs(pairs []types.ReqType) (ReqTableType, error) {
+	if len(pairs)%2 != 0 {
+		return ReqTableType{}, fmt.Errorf("expected an even amount of keys and values, but found %d items", len(pairs))
+	}
+
+	m := map[string]types.ReqType{}
+
+	for i := 0; i < len(pairs); i += 2 {
+		if pairs[i].Type() != types.TypeString {
+			return ReqTableType{}, fmt.Errorf("table keys must be strings, but found '%s(type %s)'", pairs[i].String(), pairs[i].Type().String())
+		}
+
+		m[pairs[i].Literal().(string)] = pairs[i+1]
+	}
+
+	return New(m), nil
+}
+
 func (tbt ReqTableType) String() string {
 	/*
 		if m, ok := tbt.value["__string"]; ok {
@@ -37,3 +62,26 @@ func (tbt ReqTableType) Literal() any {
 func (tbt ReqTableType) Length() (int, error) {
 	return len(tbt.value), nil
 }
+
+func (tbt ReqTableType) GetIndex(index types.ReqType) (types.ReqType, error) {
+	if index.Type() != types.TypeString {
+		return tbt.ReqBaseType.GetIndex(index)
+	}
+
+	v, ok := tbt.value[index.Literal().(string)]
+	if !ok {
+		return nil, fmt.Errorf("key '%s' does not exist", index.Literal().(string))
+	}
+
+	return v, nil
+}
+
+func (tbt ReqTableType) SetIndex(index types.ReqType, value types.ReqType) error {
+	if index.Type() != types.TypeString {
+		return tbt.ReqBaseType.SetIndex(index, value)
+	}
+
+	tbt.value[index.Literal().(string)] = value
+
+	return nil
+}
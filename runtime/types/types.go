@@ -2,6 +2,7 @@ package types
 
 import (
 	"fmt"
+	"strings"
 )
 
 type ReqVarType int8
@@ -50,6 +51,19 @@ func (rvt ReqVarType) String() string {
 		return v
 	}
 
+	// unions are shown as their members joined with '|'
+	if rvt > 0 && rvt&TypeAny == rvt {
+		names := []string{}
+
+		for t := TypeString; t <= TypeNative; t <<= 1 {
+			if rvt&t != 0 {
+				names = append(names, typeNameMapFrom[t])
+			}
+		}
+
+		return strings.Join(names, "|")
+	}
+
 	panic(fmt.Sprintf("invalid ReqVarType %d", rvt))
 }
 
@@ -58,6 +72,9 @@ var IllegalVariableNames = map[string]struct{}{
 	"false":  {},
 	"import": {},
 	"def":    {},
+	"as":     {},
+	"only":   {},
+	"export": {},
 }
 
 type ReqType interface {
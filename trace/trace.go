@@ -0,0 +1,288 @@
+/*
+Package trace logs the execution of ReqProc code, see Tracer
+
+every token executed is logged with where it's written, the word itself, and the stack before and after it, and every function
+called is logged when it's entered and when it returns, with the code it runs indented by how deep it is; stacks are truncated
+to their top values, and the values themselves to a few characters
+*/
+package trace
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/voidwyrm-2/reqproc/lexer/tokens"
+	"github.com/voidwyrm-2/reqproc/runtime/interpreter"
+	"github.com/voidwyrm-2/reqproc/runtime/types"
+)
+
+type Format int
+
+const (
+	// one line per event, indented by its depth
+	Human Format = iota
+	// one JSON object per line, see Record
+	JSON
+)
+
+func FormatFromString(s string) (Format, error) {
+	switch s {
+	case "human":
+		return Human, nil
+	case "json":
+		return JSON, nil
+	}
+
+	return 0, fmt.Errorf("invalid trace format '%s', expected 'human' or 'json'", s)
+}
+
+const (
+	// how many values from the top of a stack are logged
+	maxValues = 8
+
+	// how many characters of a value are logged
+	maxValueLen = 32
+
+	indent = "  "
+)
+
+// The top of a stack, bottom to top
+type Stack struct {
+	Values []string `json:"values"`
+
+	// how many values below them weren't logged
+	Hidden int `json:"hidden,omitempty"`
+}
+
+// A line written in the JSON format
+type Record struct {
+	// "step" for a token, "call" when a function is entered and "return" when it returns
+	Event string `json:"event"`
+
+	// how many function calls deep the token is, or the depth of the tokens of the function
+	Depth int `json:"depth"`
+
+	// where the token is written, for steps
+	File string `json:"file,omitempty"`
+	Line int    `json:"line,omitempty"`
+	Col  int    `json:"col,omitempty"`
+
+	// the token as it's written, for steps
+	Word string `json:"word,omitempty"`
+
+	// the name the function was called by, for calls and returns
+	Function string `json:"function,omitempty"`
+
+	// the stack before and after a step, or when a function is entered or returns
+	Before *Stack `json:"before,omitempty"`
+	After  *Stack `json:"after,omitempty"`
+
+	// what the token or function failed with, if it did
+	Error string `json:"error,omitempty"`
+}
+
+/*
+Tracers are interpreter.Hooks which log the code they're set on to a writer, see New
+
+they're safe to use from multiple goroutines, but the events of tasks are mixed with those of the code that spawned them
+*/
+type Tracer struct {
+	mu     sync.Mutex
+	w      io.Writer
+	format Format
+
+	// the stacks of the tokens being executed, kept until they're done
+	before map[*interpreter.Interpreter]*Stack
+
+	// the last token executed at each depth, whose word names the function it calls
+	last []tokens.Token
+
+	// the first error writing failed with, which stops the code
+	err error
+}
+
+func New(w io.Writer, format Format) *Tracer {
+	return &Tracer{w: w, format: format, before: map[*interpreter.Interpreter]*Stack{}}
+}
+
+func (t *Tracer) Before(s interpreter.Step) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	for len(t.last) <= s.Depth {
+		t.last = append(t.last, tokens.Token{})
+	}
+
+	t.last[s.Depth] = s.Token
+
+	st := s.Interpreter.GetStack()
+	t.before[s.Interpreter] = snapshot(st.Slice())
+
+	return t.err
+}
+
+func (t *Tracer) After(s interpreter.Step, err error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	st := s.Interpreter.GetStack()
+	before := t.before[s.Interpreter]
+	delete(t.before, s.Interpreter)
+
+	t.write(Record{
+		Event:  "step",
+		Depth:  s.Depth,
+		File:   s.Interpreter.GetScope().File(),
+		Line:   s.Token.Line(),
+		Col:    column(s.Token),
+		Word:   Word(s.Token),
+		Before: before,
+		After:  snapshot(st.Slice()),
+		Error:  errorString(err),
+	})
+}
+
+func (t *Tracer) Enter(c interpreter.Call) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.write(Record{Event: "call", Depth: c.Depth, Function: t.name(c), Before: snapshot(c.Stack.Slice())})
+}
+
+func (t *Tracer) Exit(c interpreter.Call, err error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.write(Record{Event: "return", Depth: c.Depth, Function: t.name(c), After: snapshot(c.Stack.Slice()), Error: errorString(err)})
+}
+
+// natives aren't named, so they're called what the token calling them was
+func (t *Tracer) name(c interpreter.Call) string {
+	if name := c.Function.Name(); name != "" {
+		return name
+	} else if caller := c.Depth - 1; caller >= 0 && caller < len(t.last) && t.last[caller].Iskind(tokens.Ident) {
+		return t.last[caller].Lit()
+	}
+
+	return "<function>"
+}
+
+func (t *Tracer) write(r Record) {
+	if t.err != nil {
+		return
+	}
+
+	if t.format == JSON {
+		t.err = json.NewEncoder(t.w).Encode(r)
+		return
+	}
+
+	var sb strings.Builder
+
+	switch r.Event {
+	case "step":
+		// a function's tokens are indented once more than the call which entered it
+		sb.WriteString(strings.Repeat(indent, r.Depth))
+		fmt.Fprintf(&sb, "%s:%d:%d %s  %s -> %s", r.File, r.Line, r.Col, r.Word, r.Before, r.After)
+	case "call":
+		sb.WriteString(strings.Repeat(indent, max(r.Depth-1, 0)))
+		fmt.Fprintf(&sb, "call %s  %s", r.Function, r.Before)
+	case "return":
+		sb.WriteString(strings.Repeat(indent, max(r.Depth-1, 0)))
+		fmt.Fprintf(&sb, "return %s  %s", r.Function, r.After)
+	}
+
+	if r.Error != "" {
+		sb.WriteString("  error: " + r.Error)
+	}
+
+	sb.WriteByte('\n')
+
+	_, t.err = io.WriteString(t.w, sb.String())
+}
+
+// Returns the error writing the trace failed with, if it did
+func (t *Tracer) Err() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return t.err
+}
+
+func snapshot(values []types.ReqType) *Stack {
+	s := &Stack{Values: []string{}, Hidden: max(len(values)-maxValues, 0)}
+
+	for _, v := range values[s.Hidden:] {
+		s.Values = append(s.Values, truncate(v.String()))
+	}
+
+	return s
+}
+
+func (s *Stack) String() string {
+	if s == nil {
+		return "[]"
+	}
+
+	values := s.Values
+	if s.Hidden > 0 {
+		values = append([]string{fmt.Sprintf("(%d more)", s.Hidden)}, values...)
+	}
+
+	return "[" + strings.Join(values, " ") + "]"
+}
+
+func truncate(s string) string {
+	s = strings.ReplaceAll(s, "\n", `\n`)
+
+	if runes := []rune(s); len(runes) > maxValueLen {
+		return string(runes[:maxValueLen-3]) + "..."
+	}
+
+	return s
+}
+
+// Returns a token the way it's written in code, except for strings, which are always written with double quotes
+func Word(t tokens.Token) string {
+	switch t.Kind() {
+	case tokens.String:
+		return truncate(strconv.Quote(t.Lit()))
+	case tokens.Signature:
+		return "|" + t.Lit()
+	case tokens.Params:
+		return "-> " + t.Lit() + " ;"
+	case tokens.Label:
+		return ":" + t.Lit()
+	case tokens.Assign:
+		return "!" + t.Lit()
+	case tokens.Const:
+		return "$" + t.Lit()
+	case tokens.GetValue:
+		return "@" + t.Lit()
+	}
+
+	return t.Lit()
+}
+
+// the column of a token, starting from 1 on every line rather than only the first one like the lexer's
+func column(t tokens.Token) int {
+	if t.Line() > 1 {
+		return t.Col() - 1
+	}
+
+	return t.Col()
+}
+
+func errorString(err error) string {
+	if err == nil {
+		return ""
+	}
+
+	return err.Error()
+}
+
+var _ interpreter.Hook = (*Tracer)(nil)